@@ -0,0 +1,86 @@
+package modbus
+
+import "io"
+
+// ASCII frame: ':', unit id, PDU and LRC in hex, CR LF
+const maxASCIILen = 1 + (1+253+1)*2 + 2
+
+// ASCIIFraming sends Cmd as Modbus ASCII frame over the connection, it's used
+// by legacy device behind terminal server. Anything before ':' is skipped.
+type ASCIIFraming struct {
+	buf [maxASCIILen]byte
+	adu [maxRTULen]byte
+}
+
+func (f *ASCIIFraming) WriteFrame(conn Conn, tx []byte) error {
+	b := append(f.buf[:0], ':')
+	b = hexs(tx[6:]).Append2(b)
+	b = hexs{lrc(tx[6:])}.Append2(b)
+	b = append(b, '\r', '\n')
+	if n, err := conn.Write(b); err != nil {
+		return err
+	} else if n != len(b) {
+		return io.ErrShortWrite
+	}
+	return nil
+}
+
+// ReadFrame reads byte by byte so it never reads past LF.
+func (f *ASCIIFraming) ReadFrame(conn Conn, tx []byte, rx *[]byte) error {
+	var c [1]byte
+	b := f.buf[:0]
+	for {
+		if err := readFull(conn, c[:]); err != nil {
+			return err
+		}
+		if c[0] == ':' {
+			// start of frame, even in the middle of one
+			b = append(f.buf[:0], ':')
+			continue
+		} else if len(b) == 0 {
+			continue
+		} else if len(b) == len(f.buf) {
+			return BadRxErr(append([]byte(nil), b...))
+		}
+		b = append(b, c[0])
+		if c[0] == '\n' {
+			break
+		}
+	}
+
+	adu, ok := f.decode(b)
+	if !ok {
+		return BadRxErr(append([]byte(nil), b...))
+	}
+	putMBAP(rx, tx, adu[:len(adu)-1])
+	return nil
+}
+
+// decode returns the unit id, PDU and LRC of frame b when it's valid.
+func (f *ASCIIFraming) decode(b []byte) ([]byte, bool) {
+	n := len(b) - 3
+	if n < 6 || n%2 != 0 || b[n+1] != '\r' {
+		return nil, false
+	}
+	adu := f.adu[:n/2]
+	var sum byte
+	for i := range adu {
+		hi, ok1 := unhex(b[1+i*2])
+		lo, ok2 := unhex(b[2+i*2])
+		if !ok1 || !ok2 {
+			return nil, false
+		}
+		adu[i] = hi<<4 | lo
+		sum += adu[i]
+	}
+	// LRC makes the sum zero
+	return adu, sum == 0
+}
+
+func lrc(b []byte) byte {
+	var x byte
+	for _, c := range b {
+		x += c
+	}
+	return -x
+}
@@ -0,0 +1,97 @@
+package modbus_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/bangzek/clock"
+	. "github.com/bangzek/modbus-tcp"
+)
+
+var _ = Describe("ASCIIFraming", func() {
+	var mc *clock.Mock
+	BeforeEach(func() {
+		mc = new(clock.Mock)
+		mc.NowScripts = []time.Duration{0, 0}
+		SetClock(mc)
+		mc.Start(time.Date(2024, time.March, 2, 10, 11, 12, 0, time.UTC))
+		NewLog()
+	})
+	AfterEach(func() {
+		mc.Stop()
+	})
+
+	send := func(cmd Cmd, conn *MockConn) error {
+		con := &Controller{
+			Dialer: &MockDialer{
+				Dials: []DialScript{
+					{conn, TIMEOUT, 0, 7, nil},
+				},
+			},
+			Framing: &ASCIIFraming{},
+		}
+		return con.Send(cmd)
+	}
+
+	It("reads registers", func() {
+		cmd := NewReadHRegsCmd(1, 0, 2)
+		conn := &MockConn{
+			Writes: []WriteScript{
+				{17, nil},
+			},
+			Reads: []ReadScript{
+				{[]byte(":01030400010002F5\r\n"), nil},
+			},
+		}
+		Expect(send(cmd, conn)).To(Succeed())
+		Expect(cmd.Reg(0)).To(Equal(uint16(1)))
+		Expect(cmd.Reg(1)).To(Equal(uint16(2)))
+		Expect(cmd.Rx()).To(Equal("0007 1->RHR 2[    1     2]"))
+		Expect(conn.Calls[1]).To(Equal("WRITE [3A 30 31 30 33 30 30 30 30 " +
+			"30 30 30 32 46 41 0D 0A]"))
+	})
+
+	It("skips garbage and accepts lower case", func() {
+		cmd := NewWriteRegsCmd(1, 2, []uint16{3, 4})
+		conn := &MockConn{
+			Writes: []WriteScript{
+				{27, nil},
+			},
+			Reads: []ReadScript{
+				{[]byte("\r\n:0110:011000020002eb\r\n"), nil},
+			},
+		}
+		Expect(send(cmd, conn)).To(Succeed())
+		Expect(cmd.Rx()).To(Equal("0007 1->WR  2:2"))
+	})
+
+	It("reads exception", func() {
+		cmd := NewReadHRegsCmd(1, 0, 2)
+		conn := &MockConn{
+			Writes: []WriteScript{
+				{17, nil},
+			},
+			Reads: []ReadScript{
+				{[]byte(":0183027A\r\n"), nil},
+			},
+		}
+		Expect(send(cmd, conn)).To(MatchError(IllegalDataAddress))
+	})
+
+	It("rejects bad LRC", func() {
+		cmd := NewReadHRegsCmd(1, 0, 2)
+		conn := &MockConn{
+			Writes: []WriteScript{
+				{17, nil},
+			},
+			Reads: []ReadScript{
+				{[]byte(":01030400010002F6\r\n"), nil},
+			},
+		}
+		Expect(send(cmd, conn)).To(MatchError("invalid response: [3A 30 31 " +
+			"30 33 30 34 30 30 30 31 30 30 30 32 46 36 0D 0A]"))
+		Expect(conn.Calls).To(ContainElement("CLOSE"))
+	})
+})
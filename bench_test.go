@@ -1802,3 +1802,569 @@ func BenchmarkWriteRegsCmd(b *testing.B) {
 		runAll(msg(rs[i], ""))
 	}
 }
+
+func BenchmarkReadExceptionStatusCmd(b *testing.B) {
+	srx := func(c *ReadExceptionStatusCmd, b []byte) {
+		r := c.RxBytes()
+		*r = (*r)[:len(b)]
+		copy(*r, b)
+	}
+
+	var cmd *ReadExceptionStatusCmd
+
+	run := func(name string, f func() string, x string) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				result = f()
+			}
+			if result != x {
+				b.Fatalf("want %q got %q", x, result)
+			} else {
+				a, l := Alloc(), len(result)
+				Debugf(b.Name(), "%d-%d %d", a, l, a-l)
+			}
+		})
+	}
+
+	runAll := func(s string, x [3][3]string) {
+		ds := [3]byte{3, 23, 123}
+		for i := 0; i < 3; i++ {
+			cmd.SetDevAddr(ds[i])
+			(*cmd.RxBytes())[6] = ds[i]
+			si := strconv.Itoa(i + 1)
+			run(" tx:"+si+s, cmd.Tx, x[i][0])
+			run(" rx:"+si+s, cmd.Rx, x[i][1])
+			run("str:"+si+s, cmd.String, x[i][2])
+		}
+	}
+
+	msg := func(rx string) (s [3][3]string) {
+		ds := [3]string{"3", "23", "123"}
+		for i := 0; i < 3; i++ {
+			s[i][0] = "0000 " + ds[i] + "<-RES"
+			s[i][1] = "0000 " + ds[i] + "->RES " + rx
+			s[i][2] = s[i][0] + "\n" + s[i][1]
+		}
+		return
+	}
+
+	cmd = NewReadExceptionStatusCmd(3)
+	srx(cmd, []byte{0, 0, 0, 0, 0, 3, 3, 0x87, 4})
+	runAll(",ERR", msg("Slave Device Failure"))
+
+	cmd = NewReadExceptionStatusCmd(3)
+	srx(cmd, []byte{0, 0, 0, 0, 0, 3, 3, 7, 0x5A})
+	runAll("", msg("01011010"))
+}
+
+func BenchmarkDiagnosticsCmd(b *testing.B) {
+	srx := func(c *DiagnosticsCmd, b []byte) {
+		r := c.RxBytes()
+		*r = (*r)[:len(b)]
+		copy(*r, b)
+	}
+
+	var cmd *DiagnosticsCmd
+
+	run := func(name string, f func() string, x string) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				result = f()
+			}
+			if result != x {
+				b.Fatalf("want %q got %q", x, result)
+			} else {
+				a, l := Alloc(), len(result)
+				Debugf(b.Name(), "%d-%d %d", a, l, a-l)
+			}
+		})
+	}
+
+	cmd = NewDiagnosticsCmd(0, RestartComm, 0)
+	run(" tx:0", cmd.Tx, "0000 0<-DIA 1 0")
+	run("str:0", cmd.String, "0000 0<-DIA 1 0")
+
+	cmd = NewDiagnosticsCmd(3, ReturnSlaveMsgCount, 0)
+	srx(cmd, []byte{0, 0, 0, 0, 0, 3, 3, 0x88, 3})
+	run(" tx:ERR", cmd.Tx, "0000 3<-DIA 14 0")
+	run(" rx:ERR", cmd.Rx, "0000 3->DIA Illegal Data Value")
+	run("str:ERR", cmd.String,
+		"0000 3<-DIA 14 0\n0000 3->DIA Illegal Data Value")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 6, 3, 8, 0, 14, 0xFF, 0xFF})
+	run(" tx", cmd.Tx, "0000 3<-DIA 14 0")
+	run(" rx", cmd.Rx, "0000 3->DIA 14 65535")
+	run("str", cmd.String, "0000 3<-DIA 14 0\n0000 3->DIA 14 65535")
+
+	cmd = NewQueryDataCmd(123, []byte{1, 2, 3, 4})
+	srx(cmd, []byte{0, 0, 0, 0, 0, 8, 123, 8, 0, 0, 1, 2, 3, 4})
+	run(" tx:Q", cmd.Tx, "0000 123<-DIA 0 [01 02 03 04]")
+	run(" rx:Q", cmd.Rx, "0000 123->DIA 0 [01 02 03 04]")
+	run("str:Q", cmd.String,
+		"0000 123<-DIA 0 [01 02 03 04]\n0000 123->DIA 0 [01 02 03 04]")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 8, 123, 8, 0, 0, 1, 2, 3, 5})
+	run("str:BAD", cmd.String, "0000 123<-DIA 0 [01 02 03 04]\n"+
+		"[00 00 00 00 00 08 7B 08 00 00 01 02 03 05]")
+}
+
+func BenchmarkGetCommEventCounterCmd(b *testing.B) {
+	srx := func(c *GetCommEventCounterCmd, b []byte) {
+		r := c.RxBytes()
+		*r = (*r)[:len(b)]
+		copy(*r, b)
+	}
+
+	run := func(name string, f func() string, x string) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				result = f()
+			}
+			if result != x {
+				b.Fatalf("want %q got %q", x, result)
+			} else {
+				a, l := Alloc(), len(result)
+				Debugf(b.Name(), "%d-%d %d", a, l, a-l)
+			}
+		})
+	}
+
+	cmd := NewGetCommEventCounterCmd(123)
+	srx(cmd, []byte{0, 0, 0, 0, 0, 3, 123, 0x8B, 4})
+	run(" tx:ERR", cmd.Tx, "0000 123<-CEC")
+	run(" rx:ERR", cmd.Rx, "0000 123->CEC Slave Device Failure")
+	run("str:ERR", cmd.String,
+		"0000 123<-CEC\n0000 123->CEC Slave Device Failure")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 6, 123, 11, 0, 0, 0xFF, 0xFF})
+	run(" rx:ready", cmd.Rx, "0000 123->CEC ready 65535")
+	run("str:ready", cmd.String, "0000 123<-CEC\n0000 123->CEC ready 65535")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 6, 123, 11, 0xFF, 0xFF, 0, 1})
+	run(" rx:busy", cmd.Rx, "0000 123->CEC busy 1")
+	run("str:busy", cmd.String, "0000 123<-CEC\n0000 123->CEC busy 1")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 6, 123, 11, 0, 1, 0, 1})
+	run(" rx:other", cmd.Rx, "0000 123->CEC 1 1")
+}
+
+func BenchmarkGetCommEventLogCmd(b *testing.B) {
+	srx := func(c *GetCommEventLogCmd, b []byte) {
+		r := c.RxBytes()
+		*r = (*r)[:len(b)]
+		copy(*r, b)
+	}
+
+	run := func(name string, f func() string, x string) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				result = f()
+			}
+			if result != x {
+				b.Fatalf("want %q got %q", x, result)
+			} else {
+				a, l := Alloc(), len(result)
+				Debugf(b.Name(), "%d-%d %d", a, l, a-l)
+			}
+		})
+	}
+
+	cmd := NewGetCommEventLogCmd(12)
+	srx(cmd, []byte{0, 0, 0, 0, 0, 3, 12, 0x8C, 4})
+	run(" tx:ERR", cmd.Tx, "0000 12<-CEL")
+	run(" rx:ERR", cmd.Rx, "0000 12->CEL Slave Device Failure")
+	run("str:ERR", cmd.String,
+		"0000 12<-CEL\n0000 12->CEL Slave Device Failure")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 9, 12, 12, 6, 0, 0, 0, 0, 0, 0})
+	run(" rx:0", cmd.Rx, "0000 12->CEL ready 0 0:0[]")
+	run("str:0", cmd.String, "0000 12<-CEL\n0000 12->CEL ready 0 0:0[]")
+
+	rx := make([]byte, 15+64)
+	copy(rx, []byte{0, 0, 0, 0, 0, 73, 12, 12, 70, 0xFF, 0xFF,
+		0x27, 0x10, 0x27, 0x10})
+	srx(cmd, rx)
+	s := "0000 12->CEL busy 10000 10000:64[" +
+		strings.Repeat("00 ", 63) + "00]"
+	run(" rx:64", cmd.Rx, s)
+	run("str:64", cmd.String, "0000 12<-CEL\n"+s)
+}
+
+func BenchmarkReportServerIDCmd(b *testing.B) {
+	srx := func(c *ReportServerIDCmd, b []byte) {
+		r := c.RxBytes()
+		*r = (*r)[:len(b)]
+		copy(*r, b)
+	}
+
+	run := func(name string, f func() string, x string) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				result = f()
+			}
+			if result != x {
+				b.Fatalf("want %q got %q", x, result)
+			} else {
+				a, l := Alloc(), len(result)
+				Debugf(b.Name(), "%d-%d %d", a, l, a-l)
+			}
+		})
+	}
+
+	cmd := NewReportServerIDCmd(42)
+	srx(cmd, []byte{0, 0, 0, 0, 0, 3, 42, 0x91, 4})
+	run(" tx:ERR", cmd.Tx, "0000 42<-RSI")
+	run(" rx:ERR", cmd.Rx, "0000 42->RSI Slave Device Failure")
+	run("str:ERR", cmd.String,
+		"0000 42<-RSI\n0000 42->RSI Slave Device Failure")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 5, 42, 17, 2, 1, 0})
+	run(" rx:off", cmd.Rx, "0000 42->RSI [01] off []")
+	run("str:off", cmd.String, "0000 42<-RSI\n0000 42->RSI [01] off []")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 7, 42, 17, 4, 1, 0xFF, 2, 3})
+	run(" rx:on", cmd.Rx, "0000 42->RSI [01] on [02 03]")
+	run("str:on", cmd.String, "0000 42<-RSI\n0000 42->RSI [01] on [02 03]")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 7, 42, 17, 5, 1, 0xFF, 2, 3})
+	run("str:BAD", cmd.String,
+		"0000 42<-RSI\n[00 00 00 00 00 07 2A 11 05 01 FF 02 03]")
+}
+
+func BenchmarkReadDeviceIDCmd(b *testing.B) {
+	srx := func(c *ReadDeviceIDCmd, b []byte) {
+		r := c.RxBytes()
+		*r = (*r)[:len(b)]
+		copy(*r, b)
+	}
+
+	run := func(name string, f func() string, x string) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				result = f()
+			}
+			if result != x {
+				b.Fatalf("want %q got %q", x, result)
+			} else {
+				a, l := Alloc(), len(result)
+				Debugf(b.Name(), "%d-%d %d", a, l, a-l)
+			}
+		})
+	}
+
+	cmd := NewReadDeviceIDCmd(42, ExtendedDevID, 128)
+	srx(cmd, []byte{0, 0, 0, 0, 0, 3, 42, 0xAB, 4})
+	run(" tx:ERR", cmd.Tx, "0000 42<-RID 3 128")
+	run(" rx:ERR", cmd.Rx, "0000 42->RID Slave Device Failure")
+	run("str:ERR", cmd.String,
+		"0000 42<-RID 3 128\n0000 42->RID Slave Device Failure")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 11, 42, 43, 14, 3, 0x83, 0, 0, 1,
+		200, 1, 9})
+	run(" rx:end", cmd.Rx, "0000 42->RID 83 200:[09]")
+	run("str:end", cmd.String,
+		"0000 42<-RID 3 128\n0000 42->RID 83 200:[09]")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 14, 42, 43, 14, 3, 0x83, 0xFF, 130, 2,
+		128, 2, 1, 2,
+		129, 0})
+	run(" rx:more", cmd.Rx, "0000 42->RID 83 128:[01 02] 129:[] next 130")
+	run("str:more", cmd.String,
+		"0000 42<-RID 3 128\n0000 42->RID 83 128:[01 02] 129:[] next 130")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 8, 42, 43, 14, 3, 0x83, 0xFF, 130, 2})
+	run("str:BAD", cmd.String,
+		"0000 42<-RID 3 128\n[00 00 00 00 00 08 2A 2B 0E 03 83 FF 82 02]")
+}
+
+func BenchmarkMaskWriteRegCmd(b *testing.B) {
+	srx := func(c *MaskWriteRegCmd, b []byte) {
+		r := c.RxBytes()
+		*r = (*r)[:len(b)]
+		copy(*r, b)
+	}
+
+	run := func(name string, f func() string, x string) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				result = f()
+			}
+			if result != x {
+				b.Fatalf("want %q got %q", x, result)
+			} else {
+				a, l := Alloc(), len(result)
+				Debugf(b.Name(), "%d-%d %d", a, l, a-l)
+			}
+		})
+	}
+
+	cmd := NewMaskWriteRegCmd(0, 12345, 0xFFF7, 0x0008)
+	run(" tx:BC", cmd.Tx, "0000 0<-MWR 12345 &FFF7 |0008")
+	run("str:BC", cmd.String, "0000 0<-MWR 12345 &FFF7 |0008")
+
+	cmd.SetDevAddr(42)
+	srx(cmd, []byte{0, 0, 0, 0, 0, 3, 42, 0x96, 4})
+	run(" rx:ERR", cmd.Rx, "0000 42->MWR Slave Device Failure")
+	run("str:ERR", cmd.String,
+		"0000 42<-MWR 12345 &FFF7 |0008\n0000 42->MWR Slave Device Failure")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 8, 42, 22, 0x30, 0x39, 0xFF, 0xF7, 0, 8})
+	run(" rx:OK", cmd.Rx, "0000 42->MWR 12345 &FFF7 |0008")
+	run("str:OK", cmd.String,
+		"0000 42<-MWR 12345 &FFF7 |0008\n0000 42->MWR 12345 &FFF7 |0008")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 8, 42, 22, 0x30, 0x39, 0xFF, 0xF7, 0, 9})
+	run("str:BAD", cmd.String, "0000 42<-MWR 12345 &FFF7 |0008\n"+
+		"[00 00 00 00 00 08 2A 16 30 39 FF F7 00 09]")
+}
+
+func BenchmarkReadWriteRegsCmd(b *testing.B) {
+	srx := func(c *ReadWriteRegsCmd, b []byte) {
+		r := c.RxBytes()
+		*r = (*r)[:len(b)]
+		copy(*r, b)
+	}
+
+	run := func(name string, f func() string, x string) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				result = f()
+			}
+			if result != x {
+				b.Fatalf("want %q got %q", x, result)
+			} else {
+				a, l := Alloc(), len(result)
+				Debugf(b.Name(), "%d-%d %d", a, l, a-l)
+			}
+		})
+	}
+
+	for _, n := range []int{1, 5, 6, 10, 11, 20, 21, 121} {
+		v := make([]uint16, n)
+		for i := range v {
+			v[i] = uint16(i * 997)
+		}
+		cmd := NewReadWriteRegsCmd(42, 12345, uint16(n), 54321, v)
+		rx := make([]byte, 9+n*2)
+		rx[5] = byte(3 + n*2)
+		rx[6] = 42
+		rx[7] = 23
+		rx[8] = byte(n * 2)
+		copy(rx[9:], cmd.WriteBytes())
+		srx(cmd, rx)
+		tx := cmd.Tx()
+		name := strconv.Itoa(n)
+		run(" tx:"+name, cmd.Tx, tx)
+		r := cmd.Rx()
+		run(" rx:"+name, cmd.Rx, r)
+		run("str:"+name, cmd.String, tx+"\n"+r)
+	}
+
+	cmd := NewReadWriteRegsCmd(42, 12345, 2, 54321, []uint16{1, 2})
+	srx(cmd, []byte{0, 0, 0, 0, 0, 3, 42, 0x97, 4})
+	run(" rx:ERR", cmd.Rx, "0000 42->RWR Slave Device Failure")
+	run("str:ERR", cmd.String, "0000 42<-RWR 12345:2 54321:2[    1     2]\n"+
+		"0000 42->RWR Slave Device Failure")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 5, 42, 23, 2, 0, 1})
+	run("str:BAD", cmd.String, "0000 42<-RWR 12345:2 54321:2[    1     2]\n"+
+		"[00 00 00 00 00 05 2A 17 02 00 01]")
+}
+
+func BenchmarkReadFIFOCmd(b *testing.B) {
+	srx := func(c *ReadFIFOCmd, b []byte) {
+		r := c.RxBytes()
+		*r = (*r)[:len(b)]
+		copy(*r, b)
+	}
+
+	run := func(name string, f func() string, x string) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				result = f()
+			}
+			if result != x {
+				b.Fatalf("want %q got %q", x, result)
+			} else {
+				a, l := Alloc(), len(result)
+				Debugf(b.Name(), "%d-%d %d", a, l, a-l)
+			}
+		})
+	}
+
+	cmd := NewReadFIFOCmd(42, 12345)
+	const tx = "0000 42<-RFQ 12345"
+	run(" tx", cmd.Tx, tx)
+
+	for _, n := range []int{0, 1, 5, 6, 11, 31} {
+		rx := make([]byte, 12+n*2)
+		rx[5] = byte(6 + n*2)
+		rx[6] = 42
+		rx[7] = 24
+		rx[9] = byte(2 + n*2)
+		rx[11] = byte(n)
+		for i := 0; i < n; i++ {
+			rx[12+i*2] = byte(i)
+			rx[13+i*2] = byte(i * 7)
+		}
+		srx(cmd, rx)
+		r := cmd.Rx()
+		name := strconv.Itoa(n)
+		run(" rx:"+name, cmd.Rx, r)
+		run("str:"+name, cmd.String, tx+"\n"+r)
+	}
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 3, 42, 0x98, 4})
+	run(" rx:ERR", cmd.Rx, "0000 42->RFQ Slave Device Failure")
+	run("str:ERR", cmd.String, tx+"\n0000 42->RFQ Slave Device Failure")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 6, 42, 24, 0, 2, 0, 1})
+	run("str:BAD", cmd.String, tx+"\n[00 00 00 00 00 06 2A 18 00 02 00 01]")
+}
+
+func BenchmarkReadFileRecordCmd(b *testing.B) {
+	srx := func(c *ReadFileRecordCmd, b []byte) {
+		r := c.RxBytes()
+		*r = (*r)[:len(b)]
+		copy(*r, b)
+	}
+
+	run := func(name string, f func() string, x string) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				result = f()
+			}
+			if result != x {
+				b.Fatalf("want %q got %q", x, result)
+			} else {
+				a, l := Alloc(), len(result)
+				Debugf(b.Name(), "%d-%d %d", a, l, a-l)
+			}
+		})
+	}
+
+	cmd := NewReadFileRecordCmd(42,
+		FileRecord{File: 4, Record: 1, Count: 2},
+		FileRecord{File: 12345, Record: 9999, Count: 11})
+	const tx = "0000 42<-RFR 4:1:2 12345:9999:11"
+	run(" tx", cmd.Tx, tx)
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 3, 42, 0x94, 4})
+	run(" rx:ERR", cmd.Rx, "0000 42->RFR Slave Device Failure")
+	run("str:ERR", cmd.String, tx+"\n0000 42->RFR Slave Device Failure")
+
+	rx := make([]byte, 9+6+24)
+	rx[5] = byte(len(rx) - 6)
+	rx[6] = 42
+	rx[7] = 20
+	rx[8] = byte(len(rx) - 9)
+	rx[9] = 5
+	rx[10] = 6
+	rx[12] = 1
+	rx[14] = 2
+	rx[15] = 23
+	rx[16] = 6
+	for i := 0; i < 11; i++ {
+		rx[18+i*2] = byte(i * 10)
+	}
+	srx(cmd, rx)
+	r := "0000 42->RFR [    1     2] [\n" +
+		"     0    10    20    30    40 :    50    60    70    80    90\n" +
+		"   100\n" +
+		"]"
+	run(" rx:OK", cmd.Rx, r)
+	run("str:OK", cmd.String, tx+"\n"+r)
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 5, 42, 20, 2, 1, 6})
+	run("str:BAD", cmd.String, tx+"\n[00 00 00 00 00 05 2A 14 02 01 06]")
+}
+
+func BenchmarkWriteFileRecordCmd(b *testing.B) {
+	srx := func(c *WriteFileRecordCmd, b []byte) {
+		r := c.RxBytes()
+		*r = (*r)[:len(b)]
+		copy(*r, b)
+	}
+
+	run := func(name string, f func() string, x string) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				result = f()
+			}
+			if result != x {
+				b.Fatalf("want %q got %q", x, result)
+			} else {
+				a, l := Alloc(), len(result)
+				Debugf(b.Name(), "%d-%d %d", a, l, a-l)
+			}
+		})
+	}
+
+	cmd := NewWriteFileRecordCmd(0,
+		FileRecord{File: 4, Record: 7, Values: []uint16{1, 2}},
+		FileRecord{File: 12345, Record: 9999, Values: []uint16{65535}})
+	const tx = "0000 0<-WFR 4:7:2[    1     2] 12345:9999:1[65535]"
+	run(" tx:BC", cmd.Tx, tx)
+	run("str:BC", cmd.String, tx)
+
+	cmd.SetDevAddr(42)
+	const tx42 = "0000 42<-WFR 4:7:2[    1     2] 12345:9999:1[65535]"
+	srx(cmd, []byte{0, 0, 0, 0, 0, 3, 42, 0x95, 4})
+	run(" rx:ERR", cmd.Rx, "0000 42->WFR Slave Device Failure")
+	run("str:ERR", cmd.String, tx42+"\n0000 42->WFR Slave Device Failure")
+
+	srx(cmd, cmd.TxBytes())
+	run(" rx:OK", cmd.Rx, "0000 42->WFR 4:7:2 12345:9999:1")
+	run("str:OK", cmd.String, tx42+"\n0000 42->WFR 4:7:2 12345:9999:1")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 3, 42, 21, 0})
+	run("str:BAD", cmd.String, tx42+"\n[00 00 00 00 00 03 2A 15 00]")
+}
+
+func BenchmarkRawCmd(b *testing.B) {
+	srx := func(c *RawCmd, b []byte) {
+		r := c.RxBytes()
+		*r = (*r)[:len(b)]
+		copy(*r, b)
+	}
+
+	run := func(name string, f func() string, x string) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				result = f()
+			}
+			if result != x {
+				b.Fatalf("want %q got %q", x, result)
+			} else {
+				a, l := Alloc(), len(result)
+				Debugf(b.Name(), "%d-%d %d", a, l, a-l)
+			}
+		})
+	}
+
+	cmd := NewRawCmd(0, 100, []byte{1, 2, 3})
+	run(" tx:BC", cmd.Tx, "0000 0<-RAW 100[01 02 03]")
+	run("str:BC", cmd.String, "0000 0<-RAW 100[01 02 03]")
+
+	cmd.SetDevAddr(42)
+	const tx = "0000 42<-RAW 100[01 02 03]"
+	srx(cmd, []byte{0, 0, 0, 0, 0, 3, 42, 0xE4, 4})
+	run(" rx:ERR", cmd.Rx, "0000 42->RAW Slave Device Failure")
+	run("str:ERR", cmd.String, tx+"\n0000 42->RAW Slave Device Failure")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 3, 42, 0xE4, 11})
+	run(" rx:GTF", cmd.Rx, "0000 42->RAW Gateway Target Failed To Respond")
+	run("str:GTF", cmd.String,
+		tx+"\n0000 42->RAW Gateway Target Failed To Respond")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 2, 42, 100})
+	run(" rx:0", cmd.Rx, "0000 42->RAW 100[]")
+	run("str:0", cmd.String, tx+"\n0000 42->RAW 100[]")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 4, 42, 100, 0xAB, 0xCD})
+	run(" rx:2", cmd.Rx, "0000 42->RAW 100[AB CD]")
+	run("str:2", cmd.String, tx+"\n0000 42->RAW 100[AB CD]")
+
+	srx(cmd, []byte{0, 0, 0, 0, 0, 2, 42, 101})
+	run("str:BAD", cmd.String, tx+"\n[00 00 00 00 00 02 2A 65]")
+}
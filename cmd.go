@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"strconv"
+	"strings"
 	"unsafe"
 )
 
@@ -25,6 +26,13 @@ type Cmd interface {
 	String() string
 }
 
+const (
+	maxReadBits  = 2000
+	maxReadRegs  = 125
+	maxWriteBits = 1968
+	maxWriteRegs = 123
+)
+
 type cmd struct {
 	tx []byte
 	rx []byte
@@ -73,7 +81,7 @@ func (c *cmd) rxLen() uint16 {
 }
 
 func (c *cmd) Err() error {
-	if len(c.rx) == 9 {
+	if len(c.rx) == 9 && c.rx[7]&0x80 != 0 {
 		return ModbusErr(c.rx[8])
 	} else {
 		return nil
@@ -99,7 +107,7 @@ func NewReadCoilsCmd(devAddr byte, addr uint16, count uint16) *ReadCoilsCmd {
 	if count == 0 {
 		panic("zero count")
 	}
-	if count > 2000 {
+	if count > maxReadBits {
 		panic(fmt.Sprintf("count too many: %d", count))
 	}
 	if addr+count-1 < addr {
@@ -166,7 +174,7 @@ func (c *ReadCoilsCmd) String() string {
 	if c.IsValidRx() {
 		l := daLen(c.DevAddr()) + aLen(c.Addr()) + cLen(c.Count()) + 13
 		if err := c.Err(); err != nil {
-			l += daLen(c.rx[6]) + 31
+			l += daLen(c.rx[6]) + 11 + len(err.Error())
 		} else {
 			l += daLen(c.rx[6]) + cLen(c.Count()) + 13 +
 				c.Count()*2 + c.Count()/5
@@ -229,10 +237,9 @@ func (c *ReadCoilsCmd) Rx() string {
 		//  ->  2
 		// RC   3
 		// ' '  1
-		// err 20
 		// ------+
-		//     31
-		l += 31
+		//     11
+		l += 11 + len(err.Error())
 	} else {
 		// ID  4
 		// ' ' 1
@@ -307,7 +314,7 @@ func NewReadDInputsCmd(
 	if count == 0 {
 		panic("zero count")
 	}
-	if count > 2000 {
+	if count > maxReadBits {
 		panic(fmt.Sprintf("count too many: %d", count))
 	}
 	if addr+count-1 < addr {
@@ -374,7 +381,7 @@ func (c *ReadDInputsCmd) String() string {
 	if c.IsValidRx() {
 		l := daLen(c.DevAddr()) + aLen(c.Addr()) + cLen(c.Count()) + 13
 		if err := c.Err(); err != nil {
-			l += daLen(c.rx[6]) + 31
+			l += daLen(c.rx[6]) + 11 + len(err.Error())
 		} else {
 			l += daLen(c.rx[6]) + cLen(c.Count()) + 13 +
 				c.Count()*2 + c.Count()/5
@@ -437,10 +444,9 @@ func (c *ReadDInputsCmd) Rx() string {
 		//  ->  2
 		// RDI  3
 		// ' '  1
-		// err 20
 		// ------+
-		//     31
-		l += 31
+		//     11
+		l += 11 + len(err.Error())
 	} else {
 		// ID  4
 		// ' ' 1
@@ -513,7 +519,7 @@ func NewReadHRegsCmd(devAddr byte, addr uint16, count uint16) *ReadHRegsCmd {
 	if count == 0 {
 		panic("zero count")
 	}
-	if count > 125 {
+	if count > maxReadRegs {
 		panic(fmt.Sprintf("count too many: %d", count))
 	}
 	if addr+count-1 < addr {
@@ -550,6 +556,43 @@ func (c *ReadHRegsCmd) Bytes() []byte {
 	return c.rx[9:]
 }
 
+// Regs is the registers read for typed decoding.
+func (c *ReadHRegsCmd) Regs() Regs {
+	return Regs(c.Bytes())
+}
+
+func (c *ReadHRegsCmd) Uint16(i int, o Order) uint16 {
+	return c.Regs().Uint16(i, o)
+}
+
+func (c *ReadHRegsCmd) Int16(i int, o Order) int16 {
+	return c.Regs().Int16(i, o)
+}
+
+func (c *ReadHRegsCmd) Uint32(i int, o Order) uint32 {
+	return c.Regs().Uint32(i, o)
+}
+
+func (c *ReadHRegsCmd) Int32(i int, o Order) int32 {
+	return c.Regs().Int32(i, o)
+}
+
+func (c *ReadHRegsCmd) Uint64(i int, o Order) uint64 {
+	return c.Regs().Uint64(i, o)
+}
+
+func (c *ReadHRegsCmd) Int64(i int, o Order) int64 {
+	return c.Regs().Int64(i, o)
+}
+
+func (c *ReadHRegsCmd) Float32(i int, o Order) float32 {
+	return c.Regs().Float32(i, o)
+}
+
+func (c *ReadHRegsCmd) Float64(i int, o Order) float64 {
+	return c.Regs().Float64(i, o)
+}
+
 func (c *ReadHRegsCmd) IsValidRx() bool {
 	return c.isValidErr() ||
 		(len(c.rx) >= 11 && c.TxId() == c.rxId() &&
@@ -565,7 +608,7 @@ func (c *ReadHRegsCmd) String() string {
 	if c.IsValidRx() {
 		l := daLen(c.DevAddr()) + aLen(c.Addr()) + cLen(c.Count()) + 13
 		if err := c.Err(); err != nil {
-			l += daLen(c.rx[6]) + 31
+			l += daLen(c.rx[6]) + 11 + len(err.Error())
 		} else {
 			l += daLen(c.rx[6]) + cLen(c.Count()) + 13 + c.Count()*6
 			if c.Count() > 5 {
@@ -631,10 +674,9 @@ func (c *ReadHRegsCmd) Rx() string {
 		//  ->  2
 		// RHR  3
 		// ' '  1
-		// err 20
 		// ------+
-		//     31
-		l += 31
+		//     11
+		l += 11 + len(err.Error())
 	} else {
 		// ID  4
 		// ' ' 1
@@ -715,7 +757,7 @@ func NewReadIRegsCmd(devAddr byte, addr uint16, count uint16) *ReadIRegsCmd {
 	if count == 0 {
 		panic("zero count")
 	}
-	if count > 125 {
+	if count > maxReadRegs {
 		panic(fmt.Sprintf("count too many: %d", count))
 	}
 	if addr+count-1 < addr {
@@ -752,6 +794,43 @@ func (c *ReadIRegsCmd) Bytes() []byte {
 	return c.rx[9:]
 }
 
+// Regs is the registers read for typed decoding.
+func (c *ReadIRegsCmd) Regs() Regs {
+	return Regs(c.Bytes())
+}
+
+func (c *ReadIRegsCmd) Uint16(i int, o Order) uint16 {
+	return c.Regs().Uint16(i, o)
+}
+
+func (c *ReadIRegsCmd) Int16(i int, o Order) int16 {
+	return c.Regs().Int16(i, o)
+}
+
+func (c *ReadIRegsCmd) Uint32(i int, o Order) uint32 {
+	return c.Regs().Uint32(i, o)
+}
+
+func (c *ReadIRegsCmd) Int32(i int, o Order) int32 {
+	return c.Regs().Int32(i, o)
+}
+
+func (c *ReadIRegsCmd) Uint64(i int, o Order) uint64 {
+	return c.Regs().Uint64(i, o)
+}
+
+func (c *ReadIRegsCmd) Int64(i int, o Order) int64 {
+	return c.Regs().Int64(i, o)
+}
+
+func (c *ReadIRegsCmd) Float32(i int, o Order) float32 {
+	return c.Regs().Float32(i, o)
+}
+
+func (c *ReadIRegsCmd) Float64(i int, o Order) float64 {
+	return c.Regs().Float64(i, o)
+}
+
 func (c *ReadIRegsCmd) IsValidRx() bool {
 	return c.isValidErr() ||
 		(len(c.rx) >= 11 && c.TxId() == c.rxId() &&
@@ -767,7 +846,7 @@ func (c *ReadIRegsCmd) String() string {
 	if c.IsValidRx() {
 		l := daLen(c.DevAddr()) + aLen(c.Addr()) + cLen(c.Count()) + 13
 		if err := c.Err(); err != nil {
-			l += daLen(c.rx[6]) + 31
+			l += daLen(c.rx[6]) + 11 + len(err.Error())
 		} else {
 			l += daLen(c.rx[6]) + cLen(c.Count()) + 13 + c.Count()*6
 			if c.Count() > 5 {
@@ -833,10 +912,9 @@ func (c *ReadIRegsCmd) Rx() string {
 		//  ->  2
 		// RIR  3
 		// ' '  1
-		// err 20
 		// ------+
-		//     31
-		l += 31
+		//     11
+		l += 11 + len(err.Error())
 	} else {
 		// ID  4
 		// ' ' 1
@@ -964,7 +1042,7 @@ func (c *WriteCoilCmd) String() string {
 		if c.IsValidRx() {
 			l := daLen(c.DevAddr()) + aLen(c.Addr()) + 18
 			if err := c.Err(); err != nil {
-				l += daLen(c.rx[6]) + 31
+				l += daLen(c.rx[6]) + 11 + len(err.Error())
 			} else {
 				l += daLen(c.rx[6]) + aLen(c.addr()) + 17
 			}
@@ -1028,10 +1106,9 @@ func (c *WriteCoilCmd) Rx() string {
 		//  ->  2
 		// W1C  3
 		// ' '  1
-		// err 20
 		// ------+
-		//     31
-		l += 31
+		//     11
+		l += 11 + len(err.Error())
 	} else {
 		// ID  4
 		// ' ' 1
@@ -1125,7 +1202,7 @@ func (c *WriteRegCmd) String() string {
 		if c.IsValidRx() {
 			l := daLen(c.DevAddr()) + aLen(c.Addr()) + aLen(c.Reg()) + 13
 			if err := c.Err(); err != nil {
-				l += daLen(c.rx[6]) + 31
+				l += daLen(c.rx[6]) + 11 + len(err.Error())
 			} else {
 				l += daLen(c.rx[6]) + aLen(c.addr()) + aLen(c.reg()) + 12
 			}
@@ -1187,10 +1264,9 @@ func (c *WriteRegCmd) Rx() string {
 		//  ->  2
 		// W1R  3
 		// ' '  1
-		// err 20
 		// ------+
-		//     31
-		l += 31
+		//     11
+		l += 11 + len(err.Error())
 	} else {
 		// ID  4
 		// ' ' 1
@@ -1239,7 +1315,7 @@ func NewWriteCoilsCmd(devAddr byte, addr uint16, values []bool) *WriteCoilsCmd {
 	if len(values) == 0 {
 		panic("empty values")
 	}
-	if len(values) > 1968 {
+	if len(values) > maxWriteBits {
 		panic(fmt.Sprintf("values too many: %d", len(values)))
 	}
 	count := uint16(len(values))
@@ -1356,7 +1432,7 @@ func (c *WriteCoilsCmd) String() string {
 				l += 2
 			}
 			if err := c.Err(); err != nil {
-				l += daLen(c.rx[6]) + 31
+				l += daLen(c.rx[6]) + 11 + len(err.Error())
 			} else {
 				l += daLen(c.rx[6]) + 12 + aLen(c.addr()) + cLen(c.count())
 			}
@@ -1454,10 +1530,9 @@ func (c *WriteCoilsCmd) Rx() string {
 		//  ->  2
 		// WC   3
 		// ' '  1
-		// err 20
 		// ------+
-		//     31
-		l += 31
+		//     11
+		l += 11 + len(err.Error())
 	} else {
 		// ID  4
 		// ' ' 1
@@ -1506,7 +1581,7 @@ func NewWriteRegsCmd(devAddr byte, addr uint16, values []uint16) *WriteRegsCmd {
 	if len(values) == 0 {
 		panic("empty values")
 	}
-	if len(values) > 123 {
+	if len(values) > maxWriteRegs {
 		panic(fmt.Sprintf("values too many: %d", len(values)))
 	}
 	count := uint16(len(values))
@@ -1595,6 +1670,43 @@ func (c *WriteRegsCmd) ModifyBytes(f func(b []byte)) {
 	f(c.Bytes())
 }
 
+// Regs is the registers written for typed encoding.
+func (c *WriteRegsCmd) Regs() Regs {
+	return Regs(c.Bytes())
+}
+
+func (c *WriteRegsCmd) SetUint16(i int, v uint16, o Order) {
+	c.Regs().PutUint16(i, v, o)
+}
+
+func (c *WriteRegsCmd) SetInt16(i int, v int16, o Order) {
+	c.Regs().PutInt16(i, v, o)
+}
+
+func (c *WriteRegsCmd) SetUint32(i int, v uint32, o Order) {
+	c.Regs().PutUint32(i, v, o)
+}
+
+func (c *WriteRegsCmd) SetInt32(i int, v int32, o Order) {
+	c.Regs().PutInt32(i, v, o)
+}
+
+func (c *WriteRegsCmd) SetUint64(i int, v uint64, o Order) {
+	c.Regs().PutUint64(i, v, o)
+}
+
+func (c *WriteRegsCmd) SetInt64(i int, v int64, o Order) {
+	c.Regs().PutInt64(i, v, o)
+}
+
+func (c *WriteRegsCmd) SetFloat32(i int, v float32, o Order) {
+	c.Regs().PutFloat32(i, v, o)
+}
+
+func (c *WriteRegsCmd) SetFloat64(i int, v float64, o Order) {
+	c.Regs().PutFloat64(i, v, o)
+}
+
 func (c *WriteRegsCmd) IsValidRx() bool {
 	return c.isValidErr() ||
 		(len(c.rx) == 12 && c.TxId() == c.rxId() &&
@@ -1612,7 +1724,7 @@ func (c *WriteRegsCmd) String() string {
 				l += 2
 			}
 			if err := c.Err(); err != nil {
-				l += daLen(c.rx[6]) + 31
+				l += daLen(c.rx[6]) + 11 + len(err.Error())
 			} else {
 				l += daLen(c.rx[6]) + 12 + aLen(c.addr()) + cLen(c.count())
 			}
@@ -1712,10 +1824,9 @@ func (c *WriteRegsCmd) Rx() string {
 		//  ->  2
 		// WR   3
 		// ' '  1
-		// err 20
 		// ------+
-		//     31
-		l += 31
+		//     11
+		l += 11 + len(err.Error())
 	} else {
 		// ID  4
 		// ' ' 1
@@ -1756,38 +1867,2729 @@ func (c *WriteRegsCmd) count() int {
 
 //----------------------------------------------------------------------
 
-func daLen(a byte) int {
-	if a < 10 {
-		return 1
-	} else if a < 100 {
-		return 2
+type ReadExceptionStatusCmd struct {
+	cmd
+}
+
+func NewReadExceptionStatusCmd(devAddr byte) *ReadExceptionStatusCmd {
+	if devAddr == 0 {
+		panic("could not broadcast ReadExceptionStatusCmd")
+	}
+
+	tx := make([]byte, 8)
+	tx[5] = 2
+	tx[6] = devAddr
+	tx[7] = 7
+
+	return &ReadExceptionStatusCmd{cmd{
+		tx: tx,
+		rx: make([]byte, 0, 9),
+	}}
+}
+
+// Addr is always 0, ReadExceptionStatusCmd has no address.
+func (c *ReadExceptionStatusCmd) Addr() uint16 {
+	return 0
+}
+
+func (c *ReadExceptionStatusCmd) SetAddr(x uint16) {
+	panic("ReadExceptionStatusCmd has no address")
+}
+
+// Status has the 8 exception status outputs, output 0 is the LSB.
+func (c *ReadExceptionStatusCmd) Status() byte {
+	return c.rx[8]
+}
+
+func (c *ReadExceptionStatusCmd) Output(i int) bool {
+	if i < 0 || i >= 8 {
+		panic(fmt.Sprintf("invalid i: %d", i))
+	}
+	b := byte(1 << i)
+	return c.rx[8]&b == b
+}
+
+func (c *ReadExceptionStatusCmd) IsValidRx() bool {
+	return c.isValidErr() ||
+		(len(c.rx) == 9 && c.TxId() == c.rxId() &&
+			c.rx[2] == 0 && c.rx[3] == 0 && c.rxLen() == 3 &&
+			c.rx[6] == c.tx[6] && c.rx[7] == c.tx[7])
+}
+
+func (c *ReadExceptionStatusCmd) String() string {
+	if c.IsValidRx() {
+		l := daLen(c.DevAddr()) + 11
+		if err := c.Err(); err != nil {
+			l += daLen(c.rx[6]) + 11 + len(err.Error())
+		} else {
+			l += daLen(c.rx[6]) + 19
+		}
+		noteAlloc(l)
+		b := make([]byte, 0, l)
+		b = c.aTx(b)
+		b = append(b, '\n')
+		b = c.aRx(b)
+		return unsafe.String(&b[0], len(b))
 	} else {
-		return 3
+		h := hexs(c.rx)
+		l := daLen(c.DevAddr()) + 13 + h.Len()
+		noteAlloc(l)
+		b := make([]byte, 0, l)
+		b = c.aTx(b)
+		b = append(b, '\n')
+		b = append(b, '[')
+		b = h.Append(b)
+		b = append(b, ']')
+		return unsafe.String(&b[0], len(b))
 	}
 }
 
-func aLen(a uint16) int {
-	if a < 10 {
-		return 1
-	} else if a < 100 {
-		return 2
-	} else if a < 1000 {
-		return 3
-	} else if a < 10000 {
-		return 4
+func (c *ReadExceptionStatusCmd) Tx() string {
+	// ID  4
+	// ' ' 1
+	//  <- 2
+	// RES 3
+	// -----+
+	//    10
+	l := daLen(c.DevAddr()) + 10
+	noteAlloc(l)
+	b := c.aTx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+func (c *ReadExceptionStatusCmd) aTx(b []byte) []byte {
+	b = hexs(c.tx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.DevAddr()), 10)
+	return append(b, "<-RES"...)
+}
+
+func (c *ReadExceptionStatusCmd) Rx() string {
+	l := daLen(c.rx[6])
+	if err := c.Err(); err != nil {
+		// ID   4
+		// ' '  1
+		//  ->  2
+		// RES  3
+		// ' '  1
+		// ------+
+		//     11
+		l += 11 + len(err.Error())
 	} else {
-		return 5
+		// ID  4
+		// ' ' 1
+		//  -> 2
+		// RES 3
+		// ' ' 1
+		// bit 8
+		// -----+
+		//    19
+		l += 19
 	}
+	noteAlloc(l)
+	b := c.aRx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
 }
 
-func cLen(c int) int {
-	if c < 10 {
-		return 1
-	} else if c < 100 {
-		return 2
-	} else if c < 1000 {
-		return 3
+func (c *ReadExceptionStatusCmd) aRx(b []byte) []byte {
+	b = hexs(c.rx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.rx[6]), 10)
+	b = append(b, "->RES "...)
+	if err := c.Err(); err != nil {
+		return append(b, err.Error()...)
 	} else {
-		return 4
+		// output 7 first like binary number
+		for i := 7; i >= 0; i-- {
+			if c.Output(i) {
+				b = append(b, '1')
+			} else {
+				b = append(b, '0')
+			}
+		}
+		return b
+	}
+}
+
+//----------------------------------------------------------------------
+
+// DiagSub is the sub-function of DiagnosticsCmd.
+type DiagSub uint16
+
+const (
+	ReturnQueryData           DiagSub = 0
+	RestartComm               DiagSub = 1
+	ReturnDiagReg             DiagSub = 2
+	ForceListenOnly           DiagSub = 4
+	ClearCounters             DiagSub = 10
+	ReturnBusMsgCount         DiagSub = 11
+	ReturnBusCommErrCount     DiagSub = 12
+	ReturnBusExceptionCount   DiagSub = 13
+	ReturnSlaveMsgCount       DiagSub = 14
+	ReturnSlaveNoRespCount    DiagSub = 15
+	ReturnSlaveNAKCount       DiagSub = 16
+	ReturnSlaveBusyCount      DiagSub = 17
+	ReturnBusCharOverrunCount DiagSub = 18
+	ClearOverrun              DiagSub = 20
+)
+
+// maxDiagData is PDU limit after function code and sub-function.
+const maxDiagData = 250
+
+type DiagnosticsCmd struct {
+	cmd
+}
+
+// NewDiagnosticsCmd makes sub-function with 2 bytes data, it's 0 for most of
+// them. RestartComm with 0xFF00 also clears the event log. ForceListenOnly
+// never get response.
+func NewDiagnosticsCmd(devAddr byte, sub DiagSub, data uint16) *DiagnosticsCmd {
+	return newDiagnosticsCmd(devAddr, sub, []byte{byte(data >> 8), byte(data)})
+}
+
+// NewQueryDataCmd makes ReturnQueryData that should be echoed back as is.
+func NewQueryDataCmd(devAddr byte, data []byte) *DiagnosticsCmd {
+	if len(data) == 0 {
+		panic("empty data")
+	}
+	if len(data) > maxDiagData {
+		panic(fmt.Sprintf("data too many: %d", len(data)))
+	}
+	return newDiagnosticsCmd(devAddr, ReturnQueryData, data)
+}
+
+func newDiagnosticsCmd(devAddr byte, sub DiagSub, data []byte) *DiagnosticsCmd {
+	tx := make([]byte, 10+len(data))
+	tx[5] = byte(4 + len(data))
+	tx[6] = devAddr
+	tx[7] = 8
+	tx[8] = byte(sub >> 8)
+	tx[9] = byte(sub)
+	copy(tx[10:], data)
+
+	var rx []byte
+	if devAddr > 0 && sub != ForceListenOnly {
+		rx = make([]byte, 0, len(tx))
+	}
+
+	return &DiagnosticsCmd{cmd{
+		tx: tx,
+		rx: rx,
+	}}
+}
+
+func (c *DiagnosticsCmd) SetDevAddr(x byte) {
+	if c.tx[6] == 0 && x != 0 && c.Sub() != ForceListenOnly {
+		c.rx = make([]byte, 0, len(c.tx))
+	} else if c.tx[6] != 0 && x == 0 {
+		c.rx = nil
+	}
+
+	c.tx[6] = x
+}
+
+// Addr is always 0, DiagnosticsCmd has no address.
+func (c *DiagnosticsCmd) Addr() uint16 {
+	return 0
+}
+
+func (c *DiagnosticsCmd) SetAddr(x uint16) {
+	panic("DiagnosticsCmd has no address")
+}
+
+func (c *DiagnosticsCmd) Sub() DiagSub {
+	return DiagSub(c.tx[8])<<8 | DiagSub(c.tx[9])
+}
+
+func (c *DiagnosticsCmd) Data() []byte {
+	return c.tx[10:]
+}
+
+// Value is the counter or diagnostic register returned.
+func (c *DiagnosticsCmd) Value() uint16 {
+	return (uint16(c.rx[10]) << 8) | uint16(c.rx[11])
+}
+
+func (c *DiagnosticsCmd) RxData() []byte {
+	return c.rx[10:]
+}
+
+func (c *DiagnosticsCmd) IsValidRx() bool {
+	return c.isValidErr() ||
+		(len(c.rx) == len(c.tx) && c.TxId() == c.rxId() &&
+			c.rx[2] == 0 && c.rx[3] == 0 &&
+			c.rxLen() == uint16(len(c.rx)-6) &&
+			bytes.Equal(c.rx[6:10], c.tx[6:10]) &&
+			(c.isReturn() || bytes.Equal(c.rx[10:], c.tx[10:])))
+}
+
+// isReturn is true when the response data is a value instead of echo.
+func (c *DiagnosticsCmd) isReturn() bool {
+	s := c.Sub()
+	return s == ReturnDiagReg ||
+		(s >= ReturnBusMsgCount && s <= ReturnBusCharOverrunCount)
+}
+
+func (c *DiagnosticsCmd) String() string {
+	if cap(c.rx) > 0 {
+		if c.IsValidRx() {
+			l := daLen(c.DevAddr()) + aLen(uint16(c.Sub())) +
+				diagLen(c.Data()) + 13
+			if err := c.Err(); err != nil {
+				l += daLen(c.rx[6]) + 11 + len(err.Error())
+			} else {
+				l += daLen(c.rx[6]) + aLen(c.sub()) + diagLen(c.RxData()) + 12
+			}
+			noteAlloc(l)
+			b := make([]byte, 0, l)
+			b = c.aTx(b)
+			b = append(b, '\n')
+			b = c.aRx(b)
+			return unsafe.String(&b[0], len(b))
+		} else {
+			h := hexs(c.rx)
+			l := daLen(c.DevAddr()) + aLen(uint16(c.Sub())) +
+				diagLen(c.Data()) + 15 + h.Len()
+			noteAlloc(l)
+			b := make([]byte, 0, l)
+			b = c.aTx(b)
+			b = append(b, '\n')
+			b = append(b, '[')
+			b = h.Append(b)
+			b = append(b, ']')
+			return unsafe.String(&b[0], len(b))
+		}
+	} else {
+		return c.Tx()
 	}
 }
+
+func (c *DiagnosticsCmd) Tx() string {
+	// ID  4
+	// ' ' 1
+	//  <- 2
+	// DIA 3
+	// ' ' 1
+	// ' ' 1
+	// -----+
+	//    12
+	l := daLen(c.DevAddr()) + aLen(uint16(c.Sub())) + diagLen(c.Data()) + 12
+	noteAlloc(l)
+	b := c.aTx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+func (c *DiagnosticsCmd) aTx(b []byte) []byte {
+	b = hexs(c.tx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.DevAddr()), 10)
+	b = append(b, "<-DIA "...)
+	b = strconv.AppendInt(b, int64(c.Sub()), 10)
+	b = append(b, ' ')
+	return appendDiag(b, c.Data())
+}
+
+func (c *DiagnosticsCmd) Rx() string {
+	l := daLen(c.rx[6])
+	if err := c.Err(); err != nil {
+		// ID   4
+		// ' '  1
+		//  ->  2
+		// DIA  3
+		// ' '  1
+		// ------+
+		//     11
+		l += 11 + len(err.Error())
+	} else {
+		// ID  4
+		// ' ' 1
+		//  -> 2
+		// DIA 3
+		// ' ' 1
+		// ' ' 1
+		// -----+
+		//    12
+		l += aLen(c.sub()) + diagLen(c.RxData()) + 12
+	}
+	noteAlloc(l)
+	b := c.aRx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+func (c *DiagnosticsCmd) aRx(b []byte) []byte {
+	b = hexs(c.rx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.rx[6]), 10)
+	b = append(b, "->DIA "...)
+	if err := c.Err(); err != nil {
+		return append(b, err.Error()...)
+	} else {
+		b = strconv.AppendInt(b, int64(c.sub()), 10)
+		b = append(b, ' ')
+		return appendDiag(b, c.RxData())
+	}
+}
+
+func (c *DiagnosticsCmd) sub() uint16 {
+	return (uint16(c.rx[8]) << 8) | uint16(c.rx[9])
+}
+
+// diagLen is the length of 2 bytes data as number, other as hex.
+func diagLen(d []byte) int {
+	if len(d) == 2 {
+		return aLen((uint16(d[0]) << 8) | uint16(d[1]))
+	} else {
+		return hexs(d).Len() + 2
+	}
+}
+
+func appendDiag(b []byte, d []byte) []byte {
+	if len(d) == 2 {
+		return strconv.AppendInt(b, int64(d[0])<<8|int64(d[1]), 10)
+	} else {
+		b = append(b, '[')
+		b = hexs(d).Append(b)
+		return append(b, ']')
+	}
+}
+
+//----------------------------------------------------------------------
+
+// CommEvent is a byte of GetCommEventLogCmd events.
+type CommEvent byte
+
+// IsReceive is true for event of a received request.
+func (e CommEvent) IsReceive() bool {
+	return e&0x80 != 0
+}
+
+// IsSend is true for event of a sent response.
+func (e CommEvent) IsSend() bool {
+	return e&0xC0 == 0x40
+}
+
+func (e CommEvent) IsListenOnly() bool {
+	return e == 0x04
+}
+
+func (e CommEvent) IsRestart() bool {
+	return e == 0
+}
+
+func (e CommEvent) String() string {
+	var a []string
+	switch {
+	case e.IsRestart():
+		return "restart"
+	case e.IsListenOnly():
+		return "listen only"
+	case e.IsReceive():
+		for i, s := range [...]string{
+			1: "comm err", 4: "overrun", 5: "listen only", 6: "broadcast",
+		} {
+			if s != "" && e&(1<<i) != 0 {
+				a = append(a, s)
+			}
+		}
+		return "rx:" + strings.Join(a, ",")
+	case e.IsSend():
+		for i, s := range [...]string{
+			"read exc", "abort exc", "busy exc", "nak exc",
+			"write timeout", "listen only",
+		} {
+			if e&(1<<i) != 0 {
+				a = append(a, s)
+			}
+		}
+		return "tx:" + strings.Join(a, ",")
+	default:
+		return fmt.Sprintf("event %02X", byte(e))
+	}
+}
+
+//----------------------------------------------------------------------
+
+type GetCommEventCounterCmd struct {
+	cmd
+}
+
+func NewGetCommEventCounterCmd(devAddr byte) *GetCommEventCounterCmd {
+	if devAddr == 0 {
+		panic("could not broadcast GetCommEventCounterCmd")
+	}
+
+	tx := make([]byte, 8)
+	tx[5] = 2
+	tx[6] = devAddr
+	tx[7] = 11
+
+	return &GetCommEventCounterCmd{cmd{
+		tx: tx,
+		rx: make([]byte, 0, 12),
+	}}
+}
+
+// Addr is always 0, GetCommEventCounterCmd has no address.
+func (c *GetCommEventCounterCmd) Addr() uint16 {
+	return 0
+}
+
+func (c *GetCommEventCounterCmd) SetAddr(x uint16) {
+	panic("GetCommEventCounterCmd has no address")
+}
+
+func (c *GetCommEventCounterCmd) Status() uint16 {
+	return (uint16(c.rx[8]) << 8) | uint16(c.rx[9])
+}
+
+// Busy is true when the device still processing previous program command.
+func (c *GetCommEventCounterCmd) Busy() bool {
+	return c.Status() == 0xFFFF
+}
+
+func (c *GetCommEventCounterCmd) EventCount() uint16 {
+	return (uint16(c.rx[10]) << 8) | uint16(c.rx[11])
+}
+
+func (c *GetCommEventCounterCmd) IsValidRx() bool {
+	return c.isValidErr() ||
+		(len(c.rx) == 12 && c.TxId() == c.rxId() &&
+			c.rx[2] == 0 && c.rx[3] == 0 && c.rxLen() == 6 &&
+			c.rx[6] == c.tx[6] && c.rx[7] == c.tx[7])
+}
+
+func (c *GetCommEventCounterCmd) String() string {
+	if c.IsValidRx() {
+		l := daLen(c.DevAddr()) + 11
+		if err := c.Err(); err != nil {
+			l += daLen(c.rx[6]) + 11 + len(err.Error())
+		} else {
+			l += daLen(c.rx[6]) + statusLen(c.Status()) +
+				aLen(c.EventCount()) + 12
+		}
+		noteAlloc(l)
+		b := make([]byte, 0, l)
+		b = c.aTx(b)
+		b = append(b, '\n')
+		b = c.aRx(b)
+		return unsafe.String(&b[0], len(b))
+	} else {
+		h := hexs(c.rx)
+		l := daLen(c.DevAddr()) + 13 + h.Len()
+		noteAlloc(l)
+		b := make([]byte, 0, l)
+		b = c.aTx(b)
+		b = append(b, '\n')
+		b = append(b, '[')
+		b = h.Append(b)
+		b = append(b, ']')
+		return unsafe.String(&b[0], len(b))
+	}
+}
+
+func (c *GetCommEventCounterCmd) Tx() string {
+	// ID  4
+	// ' ' 1
+	//  <- 2
+	// CEC 3
+	// -----+
+	//    10
+	l := daLen(c.DevAddr()) + 10
+	noteAlloc(l)
+	b := c.aTx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+func (c *GetCommEventCounterCmd) aTx(b []byte) []byte {
+	b = hexs(c.tx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.DevAddr()), 10)
+	return append(b, "<-CEC"...)
+}
+
+func (c *GetCommEventCounterCmd) Rx() string {
+	l := daLen(c.rx[6])
+	if err := c.Err(); err != nil {
+		// ID   4
+		// ' '  1
+		//  ->  2
+		// CEC  3
+		// ' '  1
+		// ------+
+		//     11
+		l += 11 + len(err.Error())
+	} else {
+		// ID  4
+		// ' ' 1
+		//  -> 2
+		// CEC 3
+		// ' ' 1
+		// ' ' 1
+		// -----+
+		//    12
+		l += statusLen(c.Status()) + aLen(c.EventCount()) + 12
+	}
+	noteAlloc(l)
+	b := c.aRx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+func (c *GetCommEventCounterCmd) aRx(b []byte) []byte {
+	b = hexs(c.rx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.rx[6]), 10)
+	b = append(b, "->CEC "...)
+	if err := c.Err(); err != nil {
+		return append(b, err.Error()...)
+	} else {
+		b = appendStatus(b, c.Status())
+		b = append(b, ' ')
+		return strconv.AppendInt(b, int64(c.EventCount()), 10)
+	}
+}
+
+//----------------------------------------------------------------------
+
+// maxCommEvents is the max events of GetCommEventLogCmd response.
+const maxCommEvents = 64
+
+type GetCommEventLogCmd struct {
+	cmd
+}
+
+func NewGetCommEventLogCmd(devAddr byte) *GetCommEventLogCmd {
+	if devAddr == 0 {
+		panic("could not broadcast GetCommEventLogCmd")
+	}
+
+	tx := make([]byte, 8)
+	tx[5] = 2
+	tx[6] = devAddr
+	tx[7] = 12
+
+	return &GetCommEventLogCmd{cmd{
+		tx: tx,
+		rx: make([]byte, 0, 15+maxCommEvents),
+	}}
+}
+
+// Addr is always 0, GetCommEventLogCmd has no address.
+func (c *GetCommEventLogCmd) Addr() uint16 {
+	return 0
+}
+
+func (c *GetCommEventLogCmd) SetAddr(x uint16) {
+	panic("GetCommEventLogCmd has no address")
+}
+
+func (c *GetCommEventLogCmd) Status() uint16 {
+	return (uint16(c.rx[9]) << 8) | uint16(c.rx[10])
+}
+
+// Busy is true when the device still processing previous program command.
+func (c *GetCommEventLogCmd) Busy() bool {
+	return c.Status() == 0xFFFF
+}
+
+func (c *GetCommEventLogCmd) EventCount() uint16 {
+	return (uint16(c.rx[11]) << 8) | uint16(c.rx[12])
+}
+
+func (c *GetCommEventLogCmd) MsgCount() uint16 {
+	return (uint16(c.rx[13]) << 8) | uint16(c.rx[14])
+}
+
+// EventLen is the number of events in the log, the newest is Event(0).
+func (c *GetCommEventLogCmd) EventLen() int {
+	return len(c.rx) - 15
+}
+
+func (c *GetCommEventLogCmd) Event(i int) CommEvent {
+	if i < 0 || i >= c.EventLen() {
+		panic(fmt.Sprintf("invalid i: %d", i))
+	}
+	return CommEvent(c.rx[15+i])
+}
+
+func (c *GetCommEventLogCmd) Bytes() []byte {
+	return c.rx[15:]
+}
+
+func (c *GetCommEventLogCmd) IsValidRx() bool {
+	return c.isValidErr() ||
+		(len(c.rx) >= 15 && c.TxId() == c.rxId() &&
+			c.rx[2] == 0 && c.rx[3] == 0 &&
+			c.rxLen() == uint16(len(c.rx)-6) &&
+			c.rx[6] == c.tx[6] && c.rx[7] == c.tx[7] &&
+			int(c.rx[8]) == len(c.rx)-9 &&
+			len(c.rx) <= 15+maxCommEvents)
+}
+
+func (c *GetCommEventLogCmd) String() string {
+	if c.IsValidRx() {
+		l := daLen(c.DevAddr()) + 11
+		if err := c.Err(); err != nil {
+			l += daLen(c.rx[6]) + 11 + len(err.Error())
+		} else {
+			l += daLen(c.rx[6]) + c.rxStrLen()
+		}
+		noteAlloc(l)
+		b := make([]byte, 0, l)
+		b = c.aTx(b)
+		b = append(b, '\n')
+		b = c.aRx(b)
+		return unsafe.String(&b[0], len(b))
+	} else {
+		h := hexs(c.rx)
+		l := daLen(c.DevAddr()) + 13 + h.Len()
+		noteAlloc(l)
+		b := make([]byte, 0, l)
+		b = c.aTx(b)
+		b = append(b, '\n')
+		b = append(b, '[')
+		b = h.Append(b)
+		b = append(b, ']')
+		return unsafe.String(&b[0], len(b))
+	}
+}
+
+func (c *GetCommEventLogCmd) Tx() string {
+	// ID  4
+	// ' ' 1
+	//  <- 2
+	// CEL 3
+	// -----+
+	//    10
+	l := daLen(c.DevAddr()) + 10
+	noteAlloc(l)
+	b := c.aTx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+func (c *GetCommEventLogCmd) aTx(b []byte) []byte {
+	b = hexs(c.tx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.DevAddr()), 10)
+	return append(b, "<-CEL"...)
+}
+
+func (c *GetCommEventLogCmd) Rx() string {
+	l := daLen(c.rx[6])
+	if err := c.Err(); err != nil {
+		// ID   4
+		// ' '  1
+		//  ->  2
+		// CEL  3
+		// ' '  1
+		// ------+
+		//     11
+		l += 11 + len(err.Error())
+	} else {
+		l += c.rxStrLen()
+	}
+	noteAlloc(l)
+	b := c.aRx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+// rxStrLen is the length of valid Rx without dev addr.
+func (c *GetCommEventLogCmd) rxStrLen() int {
+	// ID  4
+	// ' ' 1
+	//  -> 2
+	// CEL 3
+	// ' ' 1
+	// ' ' 1
+	// ' ' 1
+	// ':' 1
+	//  [] 2
+	// -----+
+	//    16
+	return statusLen(c.Status()) + aLen(c.EventCount()) +
+		aLen(c.MsgCount()) + cLen(c.EventLen()) + hexs(c.Bytes()).Len() + 16
+}
+
+func (c *GetCommEventLogCmd) aRx(b []byte) []byte {
+	b = hexs(c.rx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.rx[6]), 10)
+	b = append(b, "->CEL "...)
+	if err := c.Err(); err != nil {
+		return append(b, err.Error()...)
+	} else {
+		b = appendStatus(b, c.Status())
+		b = append(b, ' ')
+		b = strconv.AppendInt(b, int64(c.EventCount()), 10)
+		b = append(b, ' ')
+		b = strconv.AppendInt(b, int64(c.MsgCount()), 10)
+		b = append(b, ':')
+		b = strconv.AppendInt(b, int64(c.EventLen()), 10)
+		b = append(b, '[')
+		b = hexs(c.Bytes()).Append(b)
+		return append(b, ']')
+	}
+}
+
+// statusLen is the length of busy status word, 0 and 0xFFFF as text.
+func statusLen(s uint16) int {
+	switch s {
+	case 0:
+		return 5
+	case 0xFFFF:
+		return 4
+	default:
+		return aLen(s)
+	}
+}
+
+func appendStatus(b []byte, s uint16) []byte {
+	switch s {
+	case 0:
+		return append(b, "ready"...)
+	case 0xFFFF:
+		return append(b, "busy"...)
+	default:
+		return strconv.AppendInt(b, int64(s), 10)
+	}
+}
+
+//----------------------------------------------------------------------
+
+type ReportServerIDCmd struct {
+	cmd
+	idLen int
+}
+
+// NewReportServerIDCmd expects 1 byte server ID, use SetIDLen for device
+// with longer one.
+func NewReportServerIDCmd(devAddr byte) *ReportServerIDCmd {
+	if devAddr == 0 {
+		panic("could not broadcast ReportServerIDCmd")
+	}
+
+	tx := make([]byte, 8)
+	tx[5] = 2
+	tx[6] = devAddr
+	tx[7] = 17
+
+	return &ReportServerIDCmd{
+		cmd: cmd{
+			tx: tx,
+			rx: make([]byte, 0, maxADULen),
+		},
+		idLen: 1,
+	}
+}
+
+// Addr is always 0, ReportServerIDCmd has no address.
+func (c *ReportServerIDCmd) Addr() uint16 {
+	return 0
+}
+
+func (c *ReportServerIDCmd) SetAddr(x uint16) {
+	panic("ReportServerIDCmd has no address")
+}
+
+func (c *ReportServerIDCmd) IDLen() int {
+	return c.idLen
+}
+
+// SetIDLen sets the length of device specific server ID.
+func (c *ReportServerIDCmd) SetIDLen(n int) {
+	if n < 0 || n > maxADULen-10 {
+		panic(fmt.Sprintf("invalid ID length: %d", n))
+	}
+	c.idLen = n
+}
+
+// ServerID, Run and Data split the rx by IDLen, they're empty or false when
+// the response is shorter.
+func (c *ReportServerIDCmd) ServerID() []byte {
+	return c.rx[9:c.at(9+c.idLen)]
+}
+
+// Run is the run indicator status.
+func (c *ReportServerIDCmd) Run() bool {
+	i := 9 + c.idLen
+	return i < len(c.rx) && c.rx[i] == 0xFF
+}
+
+// Data is the device specific additional data.
+func (c *ReportServerIDCmd) Data() []byte {
+	return c.rx[c.at(10+c.idLen):]
+}
+
+// at limits i to the rx length.
+func (c *ReportServerIDCmd) at(i int) int {
+	return min(i, len(c.rx))
+}
+
+// IsValidRx doesn't check the layout by IDLen, it's device specific.
+func (c *ReportServerIDCmd) IsValidRx() bool {
+	return c.isValidErr() ||
+		(len(c.rx) >= 9 && c.TxId() == c.rxId() &&
+			c.rx[2] == 0 && c.rx[3] == 0 &&
+			c.rxLen() == uint16(len(c.rx)-6) &&
+			c.rx[6] == c.tx[6] && c.rx[7] == c.tx[7] &&
+			int(c.rx[8]) == len(c.rx)-9)
+}
+
+func (c *ReportServerIDCmd) String() string {
+	if c.IsValidRx() {
+		l := daLen(c.DevAddr()) + 11
+		if err := c.Err(); err != nil {
+			l += daLen(c.rx[6]) + 11 + len(err.Error())
+		} else {
+			l += daLen(c.rx[6]) + c.rxStrLen()
+		}
+		noteAlloc(l)
+		b := make([]byte, 0, l)
+		b = c.aTx(b)
+		b = append(b, '\n')
+		b = c.aRx(b)
+		return unsafe.String(&b[0], len(b))
+	} else {
+		h := hexs(c.rx)
+		l := daLen(c.DevAddr()) + 13 + h.Len()
+		noteAlloc(l)
+		b := make([]byte, 0, l)
+		b = c.aTx(b)
+		b = append(b, '\n')
+		b = append(b, '[')
+		b = h.Append(b)
+		b = append(b, ']')
+		return unsafe.String(&b[0], len(b))
+	}
+}
+
+func (c *ReportServerIDCmd) Tx() string {
+	// ID  4
+	// ' ' 1
+	//  <- 2
+	// RSI 3
+	// -----+
+	//    10
+	l := daLen(c.DevAddr()) + 10
+	noteAlloc(l)
+	b := c.aTx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+func (c *ReportServerIDCmd) aTx(b []byte) []byte {
+	b = hexs(c.tx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.DevAddr()), 10)
+	return append(b, "<-RSI"...)
+}
+
+func (c *ReportServerIDCmd) Rx() string {
+	l := daLen(c.rx[6])
+	if err := c.Err(); err != nil {
+		// ID   4
+		// ' '  1
+		//  ->  2
+		// RSI  3
+		// ' '  1
+		// ------+
+		//     11
+		l += 11 + len(err.Error())
+	} else {
+		l += c.rxStrLen()
+	}
+	noteAlloc(l)
+	b := c.aRx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+// rxStrLen is the length of valid Rx without dev addr.
+func (c *ReportServerIDCmd) rxStrLen() int {
+	// ID  4
+	// ' ' 1
+	//  -> 2
+	// RSI 3
+	// ' ' 1
+	//  [] 2
+	// ' ' 1
+	// ' ' 1
+	//  [] 2
+	// -----+
+	//    17
+	l := hexs(c.ServerID()).Len() + hexs(c.Data()).Len() + 17
+	if c.Run() {
+		l += 2
+	} else {
+		l += 3
+	}
+	return l
+}
+
+func (c *ReportServerIDCmd) aRx(b []byte) []byte {
+	b = hexs(c.rx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.rx[6]), 10)
+	b = append(b, "->RSI "...)
+	if err := c.Err(); err != nil {
+		return append(b, err.Error()...)
+	} else {
+		b = append(b, '[')
+		b = hexs(c.ServerID()).Append(b)
+		b = append(b, "] "...)
+		if c.Run() {
+			b = append(b, "on"...)
+		} else {
+			b = append(b, "off"...)
+		}
+		b = append(b, " ["...)
+		b = hexs(c.Data()).Append(b)
+		return append(b, ']')
+	}
+}
+
+const (
+	// maxFileRecord is the last record number of a file.
+	maxFileRecord = 9999
+	// maxFileData is the byte count limit of file record request and
+	// response.
+	maxFileData = 245
+	// maxFileRegs is the most registers ReadFileRecordCmd could read, all in
+	// one sub-request.
+	maxFileRegs = (maxFileData - 2) / 2
+)
+
+// FileRecord is a sub-request of ReadFileRecordCmd and WriteFileRecordCmd.
+// Record is the register number in the file, Count is registers to read and
+// Values is registers to write.
+type FileRecord struct {
+	File   uint16
+	Record uint16
+	Count  uint16
+	Values []uint16
+}
+
+func checkFileRecord(r FileRecord) {
+	if r.File == 0 {
+		panic("invalid file: 0")
+	}
+	if r.Record > maxFileRecord {
+		panic(fmt.Sprintf("invalid record: %d", r.Record))
+	}
+}
+
+type ReadFileRecordCmd struct {
+	cmd
+}
+
+func NewReadFileRecordCmd(devAddr byte, recs ...FileRecord) *ReadFileRecordCmd {
+	if devAddr == 0 {
+		panic("could not broadcast ReadFileRecordCmd")
+	}
+	if len(recs) == 0 {
+		panic("empty records")
+	}
+	if len(recs)*7 > maxFileData {
+		panic(fmt.Sprintf("records too many: %d", len(recs)))
+	}
+	n := 0
+	for _, r := range recs {
+		checkFileRecord(r)
+		if r.Count == 0 {
+			panic("zero count")
+		}
+		n += 2 + int(r.Count)*2
+	}
+	if n > maxFileData {
+		panic(fmt.Sprintf("count too many: %d", (n-len(recs)*2)/2))
+	}
+
+	l := len(recs) * 7
+	tx := make([]byte, l+9)
+	tx[5] = byte(l + 3)
+	tx[6] = devAddr
+	tx[7] = 20
+	tx[8] = byte(l)
+	for i, r := range recs {
+		b := tx[9+i*7:]
+		b[0] = 6
+		b[1] = byte(r.File >> 8)
+		b[2] = byte(r.File)
+		b[3] = byte(r.Record >> 8)
+		b[4] = byte(r.Record)
+		b[5] = byte(r.Count >> 8)
+		b[6] = byte(r.Count)
+	}
+
+	return &ReadFileRecordCmd{cmd{
+		tx: tx,
+		rx: make([]byte, 0, n+9),
+	}}
+}
+
+// Addr is always 0, ReadFileRecordCmd has no address.
+func (c *ReadFileRecordCmd) Addr() uint16 {
+	return 0
+}
+
+func (c *ReadFileRecordCmd) SetAddr(x uint16) {
+	panic("ReadFileRecordCmd has no address")
+}
+
+// RecordLen is the number of sub-requests.
+func (c *ReadFileRecordCmd) RecordLen() int {
+	return int(c.tx[8]) / 7
+}
+
+// Record is the i-th sub-request without Values.
+func (c *ReadFileRecordCmd) Record(i int) FileRecord {
+	if i < 0 || i >= c.RecordLen() {
+		panic(fmt.Sprintf("invalid i: %d", i))
+	}
+	b := c.tx[9+i*7:]
+	return FileRecord{
+		File:   uint16(b[1])<<8 | uint16(b[2]),
+		Record: uint16(b[3])<<8 | uint16(b[4]),
+		Count:  uint16(b[5])<<8 | uint16(b[6]),
+	}
+}
+
+func (c *ReadFileRecordCmd) count(i int) int {
+	return int(c.tx[14+i*7])<<8 | int(c.tx[15+i*7])
+}
+
+// Reg is the j-th register read by i-th sub-request.
+func (c *ReadFileRecordCmd) Reg(i, j int) uint16 {
+	b := c.Bytes(i)
+	if j < 0 || j >= len(b)/2 {
+		panic(fmt.Sprintf("invalid j: %d", j))
+	}
+	return uint16(b[j*2])<<8 | uint16(b[j*2+1])
+}
+
+// Bytes is the registers read by i-th sub-request.
+func (c *ReadFileRecordCmd) Bytes(i int) []byte {
+	if i < 0 || i >= c.RecordLen() {
+		panic(fmt.Sprintf("invalid i: %d", i))
+	}
+	j := 9
+	for k := 0; k < i; k++ {
+		j += 2 + c.count(k)*2
+	}
+	return c.rx[j+2 : j+2+c.count(i)*2]
+}
+
+func (c *ReadFileRecordCmd) IsValidRx() bool {
+	return c.isValidErr() ||
+		(len(c.rx) >= 9 && c.TxId() == c.rxId() &&
+			c.rx[2] == 0 && c.rx[3] == 0 &&
+			c.rxLen() == uint16(len(c.rx)-6) &&
+			c.rx[6] == c.tx[6] && c.rx[7] == c.tx[7] &&
+			int(c.rx[8]) == len(c.rx)-9 &&
+			c.isValidRecords())
+}
+
+// isValidRecords checks every sub-response has what its sub-request asked.
+func (c *ReadFileRecordCmd) isValidRecords() bool {
+	j := 9
+	for i := 0; i < c.RecordLen(); i++ {
+		n := c.count(i) * 2
+		if j+2+n > len(c.rx) || int(c.rx[j]) != n+1 || c.rx[j+1] != 6 {
+			return false
+		}
+		j += 2 + n
+	}
+	return j == len(c.rx)
+}
+
+func (c *ReadFileRecordCmd) String() string {
+	if c.IsValidRx() {
+		l := c.txStrLen() + 1
+		if err := c.Err(); err != nil {
+			l += daLen(c.rx[6]) + 11 + len(err.Error())
+		} else {
+			l += daLen(c.rx[6]) + c.rxStrLen()
+		}
+		noteAlloc(l)
+		b := make([]byte, 0, l)
+		b = c.aTx(b)
+		b = append(b, '\n')
+		b = c.aRx(b)
+		return unsafe.String(&b[0], len(b))
+	} else {
+		h := hexs(c.rx)
+		l := c.txStrLen() + 3 + h.Len()
+		noteAlloc(l)
+		b := make([]byte, 0, l)
+		b = c.aTx(b)
+		b = append(b, '\n')
+		b = append(b, '[')
+		b = h.Append(b)
+		b = append(b, ']')
+		return unsafe.String(&b[0], len(b))
+	}
+}
+
+func (c *ReadFileRecordCmd) Tx() string {
+	l := c.txStrLen()
+	noteAlloc(l)
+	b := c.aTx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+func (c *ReadFileRecordCmd) txStrLen() int {
+	// ID  4
+	// ' ' 1
+	//  <- 2
+	// RFR 3
+	// -----+
+	//    10
+	l := daLen(c.DevAddr()) + 10
+	for i := 0; i < c.RecordLen(); i++ {
+		// ' ' 1
+		//   : 1
+		//   : 1
+		// -----+
+		//     3
+		r := c.Record(i)
+		l += aLen(r.File) + aLen(r.Record) + aLen(r.Count) + 3
+	}
+	return l
+}
+
+func (c *ReadFileRecordCmd) aTx(b []byte) []byte {
+	b = hexs(c.tx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.DevAddr()), 10)
+	b = append(b, "<-RFR"...)
+	for i := 0; i < c.RecordLen(); i++ {
+		r := c.Record(i)
+		b = append(b, ' ')
+		b = strconv.AppendInt(b, int64(r.File), 10)
+		b = append(b, ':')
+		b = strconv.AppendInt(b, int64(r.Record), 10)
+		b = append(b, ':')
+		b = strconv.AppendInt(b, int64(r.Count), 10)
+	}
+	return b
+}
+
+func (c *ReadFileRecordCmd) Rx() string {
+	l := daLen(c.rx[6])
+	if err := c.Err(); err != nil {
+		// ID   4
+		// ' '  1
+		//  ->  2
+		// RFR  3
+		// ' '  1
+		// ------+
+		//     11
+		l += 11 + len(err.Error())
+	} else {
+		l += c.rxStrLen()
+	}
+	noteAlloc(l)
+	b := c.aRx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+// rxStrLen is the length of valid Rx without dev addr.
+func (c *ReadFileRecordCmd) rxStrLen() int {
+	// ID  4
+	// ' ' 1
+	//  -> 2
+	// RFR 3
+	// -----+
+	//    10
+	l := 10
+	for i := 0; i < c.RecordLen(); i++ {
+		// ' ' 1
+		//  [] 2
+		// -----+
+		//     3
+		l += regStrLen(c.count(i)) + 3
+	}
+	return l
+}
+
+func (c *ReadFileRecordCmd) aRx(b []byte) []byte {
+	b = hexs(c.rx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.rx[6]), 10)
+	b = append(b, "->RFR"...)
+	if err := c.Err(); err != nil {
+		b = append(b, ' ')
+		return append(b, err.Error()...)
+	} else {
+		for i := 0; i < c.RecordLen(); i++ {
+			b = append(b, " ["...)
+			b = appendRegStr(b, c.Bytes(i))
+			b = append(b, ']')
+		}
+		return b
+	}
+}
+
+//----------------------------------------------------------------------
+
+type WriteFileRecordCmd struct {
+	cmd
+}
+
+func NewWriteFileRecordCmd(
+	devAddr byte, recs ...FileRecord,
+) *WriteFileRecordCmd {
+	if len(recs) == 0 {
+		panic("empty records")
+	}
+	l := 0
+	n := 0
+	for _, r := range recs {
+		checkFileRecord(r)
+		if len(r.Values) == 0 {
+			panic("empty values")
+		}
+		l += 7 + len(r.Values)*2
+		n += len(r.Values)
+	}
+	if l > maxFileData {
+		panic(fmt.Sprintf("values too many: %d", n))
+	}
+
+	tx := make([]byte, l+9)
+	tx[5] = byte(l + 3)
+	tx[6] = devAddr
+	tx[7] = 21
+	tx[8] = byte(l)
+	b := tx[9:]
+	for _, r := range recs {
+		b[0] = 6
+		b[1] = byte(r.File >> 8)
+		b[2] = byte(r.File)
+		b[3] = byte(r.Record >> 8)
+		b[4] = byte(r.Record)
+		b[5] = byte(len(r.Values) >> 8)
+		b[6] = byte(len(r.Values))
+		for i, v := range r.Values {
+			b[7+i*2] = byte(v >> 8)
+			b[8+i*2] = byte(v)
+		}
+		b = b[7+len(r.Values)*2:]
+	}
+
+	var rx []byte
+	if devAddr > 0 {
+		rx = make([]byte, 0, len(tx))
+	}
+
+	return &WriteFileRecordCmd{cmd{
+		tx: tx,
+		rx: rx,
+	}}
+}
+
+func (c *WriteFileRecordCmd) SetDevAddr(x byte) {
+	if c.tx[6] == 0 && x != 0 {
+		c.rx = make([]byte, 0, len(c.tx))
+	} else if c.tx[6] != 0 && x == 0 {
+		c.rx = nil
+	}
+
+	c.tx[6] = x
+}
+
+// Addr is always 0, WriteFileRecordCmd has no address.
+func (c *WriteFileRecordCmd) Addr() uint16 {
+	return 0
+}
+
+func (c *WriteFileRecordCmd) SetAddr(x uint16) {
+	panic("WriteFileRecordCmd has no address")
+}
+
+// RecordLen is the number of sub-requests.
+func (c *WriteFileRecordCmd) RecordLen() int {
+	n := 0
+	for j := 9; j < len(c.tx); j += 7 + c.count(j)*2 {
+		n++
+	}
+	return n
+}
+
+// count is the registers of sub-request at tx[j].
+func (c *WriteFileRecordCmd) count(j int) int {
+	return int(c.tx[j+5])<<8 | int(c.tx[j+6])
+}
+
+// sub returns the offset of i-th sub-request in tx.
+func (c *WriteFileRecordCmd) sub(i int) int {
+	if i < 0 {
+		panic(fmt.Sprintf("invalid i: %d", i))
+	}
+	j := 9
+	for k := 0; k < i; k++ {
+		if j >= len(c.tx) {
+			break
+		}
+		j += 7 + c.count(j)*2
+	}
+	if j >= len(c.tx) {
+		panic(fmt.Sprintf("invalid i: %d", i))
+	}
+	return j
+}
+
+// Record is the i-th sub-request.
+func (c *WriteFileRecordCmd) Record(i int) FileRecord {
+	j := c.sub(i)
+	b := c.tx[j:]
+	r := FileRecord{
+		File:   uint16(b[1])<<8 | uint16(b[2]),
+		Record: uint16(b[3])<<8 | uint16(b[4]),
+		Count:  uint16(b[5])<<8 | uint16(b[6]),
+	}
+	r.Values = make([]uint16, r.Count)
+	for k := range r.Values {
+		r.Values[k] = uint16(b[7+k*2])<<8 | uint16(b[8+k*2])
+	}
+	return r
+}
+
+// Reg is the j-th register written by i-th sub-request.
+func (c *WriteFileRecordCmd) Reg(i, j int) uint16 {
+	b := c.Bytes(i)
+	if j < 0 || j >= len(b)/2 {
+		panic(fmt.Sprintf("invalid j: %d", j))
+	}
+	return uint16(b[j*2])<<8 | uint16(b[j*2+1])
+}
+
+func (c *WriteFileRecordCmd) SetReg(i, j int, v uint16) {
+	b := c.Bytes(i)
+	if j < 0 || j >= len(b)/2 {
+		panic(fmt.Sprintf("invalid j: %d", j))
+	}
+	b[j*2] = byte(v >> 8)
+	b[j*2+1] = byte(v)
+}
+
+// Bytes is the registers written by i-th sub-request.
+func (c *WriteFileRecordCmd) Bytes(i int) []byte {
+	j := c.sub(i)
+	return c.tx[j+7 : j+7+c.count(j)*2]
+}
+
+func (c *WriteFileRecordCmd) IsValidRx() bool {
+	return c.isValidErr() ||
+		(len(c.rx) == len(c.tx) && c.TxId() == c.rxId() &&
+			c.rx[2] == 0 && c.rx[3] == 0 &&
+			bytes.Equal(c.rx[4:], c.tx[4:]))
+}
+
+func (c *WriteFileRecordCmd) String() string {
+	if cap(c.rx) > 0 {
+		if c.IsValidRx() {
+			l := c.txStrLen() + 1
+			if err := c.Err(); err != nil {
+				l += daLen(c.rx[6]) + 11 + len(err.Error())
+			} else {
+				l += daLen(c.rx[6]) + c.rxStrLen()
+			}
+			noteAlloc(l)
+			b := make([]byte, 0, l)
+			b = c.aTx(b)
+			b = append(b, '\n')
+			b = c.aRx(b)
+			return unsafe.String(&b[0], len(b))
+		} else {
+			h := hexs(c.rx)
+			l := c.txStrLen() + 3 + h.Len()
+			noteAlloc(l)
+			b := make([]byte, 0, l)
+			b = c.aTx(b)
+			b = append(b, '\n')
+			b = append(b, '[')
+			b = h.Append(b)
+			b = append(b, ']')
+			return unsafe.String(&b[0], len(b))
+		}
+	} else {
+		return c.Tx()
+	}
+}
+
+func (c *WriteFileRecordCmd) Tx() string {
+	l := c.txStrLen()
+	noteAlloc(l)
+	b := c.aTx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+func (c *WriteFileRecordCmd) txStrLen() int {
+	// ID  4
+	// ' ' 1
+	//  <- 2
+	// WFR 3
+	// -----+
+	//    10
+	l := daLen(c.DevAddr()) + 10
+	for j := 9; j < len(c.tx); j += 7 + c.count(j)*2 {
+		// ' ' 1
+		//   : 1
+		//   : 1
+		//  [] 2
+		// -----+
+		//     5
+		n := c.count(j)
+		l += aLen(c.file(c.tx, j)) + aLen(c.record(c.tx, j)) +
+			cLen(n) + regStrLen(n) + 5
+	}
+	return l
+}
+
+func (c *WriteFileRecordCmd) file(b []byte, j int) uint16 {
+	return uint16(b[j+1])<<8 | uint16(b[j+2])
+}
+
+func (c *WriteFileRecordCmd) record(b []byte, j int) uint16 {
+	return uint16(b[j+3])<<8 | uint16(b[j+4])
+}
+
+func (c *WriteFileRecordCmd) aTx(b []byte) []byte {
+	b = hexs(c.tx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.DevAddr()), 10)
+	b = append(b, "<-WFR"...)
+	for j := 9; j < len(c.tx); j += 7 + c.count(j)*2 {
+		n := c.count(j)
+		b = append(b, ' ')
+		b = strconv.AppendInt(b, int64(c.file(c.tx, j)), 10)
+		b = append(b, ':')
+		b = strconv.AppendInt(b, int64(c.record(c.tx, j)), 10)
+		b = append(b, ':')
+		b = strconv.AppendInt(b, int64(n), 10)
+		b = append(b, '[')
+		b = appendRegStr(b, c.tx[j+7:j+7+n*2])
+		b = append(b, ']')
+	}
+	return b
+}
+
+func (c *WriteFileRecordCmd) Rx() string {
+	l := daLen(c.rx[6])
+	if err := c.Err(); err != nil {
+		// ID   4
+		// ' '  1
+		//  ->  2
+		// WFR  3
+		// ' '  1
+		// ------+
+		//     11
+		l += 11 + len(err.Error())
+	} else {
+		l += c.rxStrLen()
+	}
+	noteAlloc(l)
+	b := c.aRx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+// rxStrLen is the length of valid Rx without dev addr.
+func (c *WriteFileRecordCmd) rxStrLen() int {
+	// ID  4
+	// ' ' 1
+	//  -> 2
+	// WFR 3
+	// -----+
+	//    10
+	l := 10
+	for j := 9; j < len(c.rx); j += 7 + c.count(j)*2 {
+		// ' ' 1
+		//   : 1
+		//   : 1
+		// -----+
+		//     3
+		l += aLen(c.file(c.rx, j)) + aLen(c.record(c.rx, j)) +
+			cLen(c.count(j)) + 3
+	}
+	return l
+}
+
+func (c *WriteFileRecordCmd) aRx(b []byte) []byte {
+	b = hexs(c.rx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.rx[6]), 10)
+	b = append(b, "->WFR"...)
+	if err := c.Err(); err != nil {
+		b = append(b, ' ')
+		return append(b, err.Error()...)
+	} else {
+		// valid rx is the echo of tx
+		for j := 9; j < len(c.rx); j += 7 + c.count(j)*2 {
+			b = append(b, ' ')
+			b = strconv.AppendInt(b, int64(c.file(c.rx, j)), 10)
+			b = append(b, ':')
+			b = strconv.AppendInt(b, int64(c.record(c.rx, j)), 10)
+			b = append(b, ':')
+			b = strconv.AppendInt(b, int64(c.count(j)), 10)
+		}
+		return b
+	}
+}
+
+//----------------------------------------------------------------------
+
+// MaskWriteRegCmd changes some bits of holding register in the device, so
+// it doesn't race with the device own logic like read then write does. The
+// result is (current AND and) OR (or AND NOT and).
+type MaskWriteRegCmd struct {
+	cmd
+}
+
+func NewMaskWriteRegCmd(
+	devAddr byte, addr uint16, and uint16, or uint16,
+) *MaskWriteRegCmd {
+	tx := make([]byte, 14)
+	tx[5] = 8
+	tx[6] = devAddr
+	tx[7] = 22
+	tx[8] = byte(addr >> 8)
+	tx[9] = byte(addr)
+	tx[10] = byte(and >> 8)
+	tx[11] = byte(and)
+	tx[12] = byte(or >> 8)
+	tx[13] = byte(or)
+
+	var rx []byte
+	if devAddr > 0 {
+		rx = make([]byte, 0, len(tx))
+	}
+
+	return &MaskWriteRegCmd{cmd{
+		tx: tx,
+		rx: rx,
+	}}
+}
+
+// NewSetRegBitCmd sets bit n (0 is LSB) of the register.
+func NewSetRegBitCmd(devAddr byte, addr uint16, n int) *MaskWriteRegCmd {
+	b := regBit(n)
+	return NewMaskWriteRegCmd(devAddr, addr, ^b, b)
+}
+
+// NewClearRegBitCmd clears bit n (0 is LSB) of the register.
+func NewClearRegBitCmd(devAddr byte, addr uint16, n int) *MaskWriteRegCmd {
+	return NewMaskWriteRegCmd(devAddr, addr, ^regBit(n), 0)
+}
+
+func regBit(n int) uint16 {
+	if n < 0 || n > 15 {
+		panic(fmt.Sprintf("invalid bit: %d", n))
+	}
+	return 1 << n
+}
+
+func (c *MaskWriteRegCmd) SetDevAddr(x byte) {
+	if c.tx[6] == 0 && x != 0 {
+		c.rx = make([]byte, 0, len(c.tx))
+	} else if c.tx[6] != 0 && x == 0 {
+		c.rx = nil
+	}
+
+	c.tx[6] = x
+}
+
+func (c *MaskWriteRegCmd) AndMask() uint16 {
+	return (uint16(c.tx[10]) << 8) | uint16(c.tx[11])
+}
+
+func (c *MaskWriteRegCmd) SetAndMask(v uint16) {
+	c.tx[10] = byte(v >> 8)
+	c.tx[11] = byte(v)
+}
+
+func (c *MaskWriteRegCmd) OrMask() uint16 {
+	return (uint16(c.tx[12]) << 8) | uint16(c.tx[13])
+}
+
+func (c *MaskWriteRegCmd) SetOrMask(v uint16) {
+	c.tx[12] = byte(v >> 8)
+	c.tx[13] = byte(v)
+}
+
+// Apply returns what the device should write to register holding v.
+func (c *MaskWriteRegCmd) Apply(v uint16) uint16 {
+	and := c.AndMask()
+	return v&and | c.OrMask()&^and
+}
+
+func (c *MaskWriteRegCmd) IsValidRx() bool {
+	return c.isValidErr() || (len(c.rx) == 14 && bytes.Equal(c.rx, c.tx))
+}
+
+func (c *MaskWriteRegCmd) String() string {
+	if cap(c.rx) > 0 {
+		if c.IsValidRx() {
+			l := daLen(c.DevAddr()) + aLen(c.Addr()) + 24
+			if err := c.Err(); err != nil {
+				l += daLen(c.rx[6]) + 11 + len(err.Error())
+			} else {
+				l += daLen(c.rx[6]) + aLen(c.addr()) + 23
+			}
+			noteAlloc(l)
+			b := make([]byte, 0, l)
+			b = c.aTx(b)
+			b = append(b, '\n')
+			b = c.aRx(b)
+			return unsafe.String(&b[0], len(b))
+		} else {
+			h := hexs(c.rx)
+			l := daLen(c.DevAddr()) + aLen(c.Addr()) + 26 + h.Len()
+			noteAlloc(l)
+			b := make([]byte, 0, l)
+			b = c.aTx(b)
+			b = append(b, '\n')
+			b = append(b, '[')
+			b = h.Append(b)
+			b = append(b, ']')
+			return unsafe.String(&b[0], len(b))
+		}
+	} else {
+		return c.Tx()
+	}
+}
+
+func (c *MaskWriteRegCmd) Tx() string {
+	// ID   4
+	// ' '  1
+	//  <-  2
+	// MWR  3
+	// ' '  1
+	// ' '  1
+	// &    1
+	// and  4
+	// ' '  1
+	// |    1
+	// or   4
+	// ------+
+	//     23
+	l := daLen(c.DevAddr()) + aLen(c.Addr()) + 23
+	noteAlloc(l)
+	b := c.aTx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+func (c *MaskWriteRegCmd) aTx(b []byte) []byte {
+	b = hexs(c.tx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.DevAddr()), 10)
+	b = append(b, "<-MWR "...)
+	b = strconv.AppendInt(b, int64(c.Addr()), 10)
+	b = append(b, " &"...)
+	b = hexs(c.tx[10:12]).Append2(b)
+	b = append(b, " |"...)
+	return hexs(c.tx[12:14]).Append2(b)
+}
+
+func (c *MaskWriteRegCmd) Rx() string {
+	l := daLen(c.rx[6])
+	if err := c.Err(); err != nil {
+		// ID   4
+		// ' '  1
+		//  ->  2
+		// MWR  3
+		// ' '  1
+		// ------+
+		//     11
+		l += 11 + len(err.Error())
+	} else {
+		// ID   4
+		// ' '  1
+		//  ->  2
+		// MWR  3
+		// ' '  1
+		// ' '  1
+		// &    1
+		// and  4
+		// ' '  1
+		// |    1
+		// or   4
+		// ------+
+		//     23
+		l += aLen(c.addr()) + 23
+	}
+	noteAlloc(l)
+	b := c.aRx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+func (c *MaskWriteRegCmd) aRx(b []byte) []byte {
+	b = hexs(c.rx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.rx[6]), 10)
+	b = append(b, "->MWR "...)
+	if err := c.Err(); err != nil {
+		return append(b, err.Error()...)
+	} else {
+		b = strconv.AppendInt(b, int64(c.addr()), 10)
+		b = append(b, " &"...)
+		b = hexs(c.rx[10:12]).Append2(b)
+		b = append(b, " |"...)
+		return hexs(c.rx[12:14]).Append2(b)
+	}
+}
+
+func (c *MaskWriteRegCmd) addr() uint16 {
+	return (uint16(c.rx[8]) << 8) | uint16(c.rx[9])
+}
+
+//----------------------------------------------------------------------
+
+// maxRWWriteRegs is the write limit of ReadWriteRegsCmd, its request has 4
+// more bytes than WriteRegsCmd.
+const maxRWWriteRegs = 121
+
+// ReadWriteRegsCmd writes holding registers then reads holding registers in
+// one transaction.
+type ReadWriteRegsCmd struct {
+	cmd
+}
+
+func NewReadWriteRegsCmd(
+	devAddr byte, addr uint16, count uint16, waddr uint16, values []uint16,
+) *ReadWriteRegsCmd {
+	if devAddr == 0 {
+		panic("could not broadcast ReadWriteRegsCmd")
+	}
+	if count == 0 {
+		panic("zero count")
+	}
+	if count > maxReadRegs {
+		panic(fmt.Sprintf("count too many: %d", count))
+	}
+	if addr+count-1 < addr {
+		panic(fmt.Sprintf("address overflow: %d, %d", addr, count))
+	}
+	if len(values) == 0 {
+		panic("empty values")
+	}
+	if len(values) > maxRWWriteRegs {
+		panic(fmt.Sprintf("values too many: %d", len(values)))
+	}
+	wcount := uint16(len(values))
+	if waddr+wcount-1 < waddr {
+		panic(fmt.Sprintf("address overflow: %d, %d", waddr, wcount))
+	}
+
+	l := wcount * 2
+	tx := make([]byte, l+17)
+	tx[5] = byte(l + 11)
+	tx[6] = devAddr
+	tx[7] = 23
+	tx[8] = byte(addr >> 8)
+	tx[9] = byte(addr)
+	// tx[10] always 0
+	tx[11] = byte(count)
+	tx[12] = byte(waddr >> 8)
+	tx[13] = byte(waddr)
+	// tx[14] always 0
+	tx[15] = byte(wcount)
+	tx[16] = byte(l)
+	for i, v := range values {
+		tx[17+i*2] = byte(v >> 8)
+		tx[18+i*2] = byte(v)
+	}
+
+	return &ReadWriteRegsCmd{cmd{
+		tx: tx,
+		rx: make([]byte, 0, count*2+9),
+	}}
+}
+
+// Count is the number of registers read.
+func (c *ReadWriteRegsCmd) Count() int {
+	return int(c.tx[11])
+}
+
+// Reg is the i-th register read.
+func (c *ReadWriteRegsCmd) Reg(i int) uint16 {
+	if i < 0 || i >= c.Count() {
+		panic(fmt.Sprintf("invalid i: %d", i))
+	}
+	return (uint16(c.rx[9+i*2]) << 8) | uint16(c.rx[10+i*2])
+}
+
+// Bytes is the registers read.
+func (c *ReadWriteRegsCmd) Bytes() []byte {
+	return c.rx[9:]
+}
+
+func (c *ReadWriteRegsCmd) WriteAddr() uint16 {
+	return (uint16(c.tx[12]) << 8) | uint16(c.tx[13])
+}
+
+func (c *ReadWriteRegsCmd) SetWriteAddr(x uint16) {
+	c.tx[12] = byte(x >> 8)
+	c.tx[13] = byte(x)
+}
+
+// WriteCount is the number of registers written.
+func (c *ReadWriteRegsCmd) WriteCount() int {
+	return int(c.tx[15])
+}
+
+// WriteReg is the i-th register written.
+func (c *ReadWriteRegsCmd) WriteReg(i int) uint16 {
+	if i < 0 || i >= c.WriteCount() {
+		panic(fmt.Sprintf("invalid i: %d", i))
+	}
+	return (uint16(c.tx[17+i*2]) << 8) | uint16(c.tx[18+i*2])
+}
+
+func (c *ReadWriteRegsCmd) SetReg(i int, v uint16) {
+	if i < 0 || i >= c.WriteCount() {
+		panic(fmt.Sprintf("invalid i: %d", i))
+	}
+	c.tx[17+i*2] = byte(v >> 8)
+	c.tx[18+i*2] = byte(v)
+}
+
+// WriteBytes is the registers written.
+func (c *ReadWriteRegsCmd) WriteBytes() []byte {
+	return c.tx[17:]
+}
+
+func (c *ReadWriteRegsCmd) ModifyBytes(f func(b []byte)) {
+	f(c.WriteBytes())
+}
+
+func (c *ReadWriteRegsCmd) IsValidRx() bool {
+	return c.isValidErr() ||
+		(len(c.rx) >= 11 && c.TxId() == c.rxId() &&
+			c.rx[2] == 0 && c.rx[3] == 0 &&
+			c.rxLen() == uint16(len(c.rx)-6) &&
+			c.rx[6] == c.tx[6] &&
+			c.rx[7] == c.tx[7] &&
+			c.rx[8] == c.tx[11]*2 &&
+			len(c.rx) == int(c.rx[8])+9)
+}
+
+func (c *ReadWriteRegsCmd) String() string {
+	if c.IsValidRx() {
+		l := c.txStrLen() + 1
+		if err := c.Err(); err != nil {
+			l += daLen(c.rx[6]) + 11 + len(err.Error())
+		} else {
+			l += daLen(c.rx[6]) + cLen(c.Count()) + regStrLen(c.Count()) + 13
+		}
+		noteAlloc(l)
+		b := make([]byte, 0, l)
+		b = c.aTx(b)
+		b = append(b, '\n')
+		b = c.aRx(b)
+		return unsafe.String(&b[0], len(b))
+	} else {
+		h := hexs(c.rx)
+		l := c.txStrLen() + 3 + h.Len()
+		noteAlloc(l)
+		b := make([]byte, 0, l)
+		b = c.aTx(b)
+		b = append(b, '\n')
+		b = append(b, '[')
+		b = h.Append(b)
+		b = append(b, ']')
+		return unsafe.String(&b[0], len(b))
+	}
+}
+
+func (c *ReadWriteRegsCmd) Tx() string {
+	l := c.txStrLen()
+	noteAlloc(l)
+	b := c.aTx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+func (c *ReadWriteRegsCmd) txStrLen() int {
+	// ID  4
+	// ' ' 1
+	//  <- 2
+	// RWR 3
+	// ' ' 1
+	// ':' 1
+	// ' ' 1
+	// ':' 1
+	//  [] 2
+	// -----+
+	//    16
+	return daLen(c.DevAddr()) + aLen(c.Addr()) + cLen(c.Count()) +
+		aLen(c.WriteAddr()) + cLen(c.WriteCount()) +
+		regStrLen(c.WriteCount()) + 16
+}
+
+func (c *ReadWriteRegsCmd) aTx(b []byte) []byte {
+	b = hexs(c.tx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.DevAddr()), 10)
+	b = append(b, "<-RWR "...)
+	b = strconv.AppendInt(b, int64(c.Addr()), 10)
+	b = append(b, ':')
+	b = strconv.AppendInt(b, int64(c.Count()), 10)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.WriteAddr()), 10)
+	b = append(b, ':')
+	b = strconv.AppendInt(b, int64(c.WriteCount()), 10)
+	b = append(b, '[')
+	b = appendRegStr(b, c.WriteBytes())
+	return append(b, ']')
+}
+
+func (c *ReadWriteRegsCmd) Rx() string {
+	l := daLen(c.rx[6])
+	if err := c.Err(); err != nil {
+		// ID   4
+		// ' '  1
+		//  ->  2
+		// RWR  3
+		// ' '  1
+		// ------+
+		//     11
+		l += 11 + len(err.Error())
+	} else {
+		// ID  4
+		// ' ' 1
+		//  -> 2
+		// RWR 3
+		// ' ' 1
+		//  [] 2
+		// -----+
+		//    13
+		l += cLen(c.Count()) + regStrLen(c.Count()) + 13
+	}
+	noteAlloc(l)
+	b := c.aRx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+func (c *ReadWriteRegsCmd) aRx(b []byte) []byte {
+	b = hexs(c.rx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.rx[6]), 10)
+	b = append(b, "->RWR "...)
+	if err := c.Err(); err != nil {
+		return append(b, err.Error()...)
+	} else {
+		b = strconv.AppendInt(b, int64(c.Count()), 10)
+		b = append(b, '[')
+		b = appendRegStr(b, c.Bytes())
+		return append(b, ']')
+	}
+}
+
+//----------------------------------------------------------------------
+
+// maxFIFOCount is the max registers in the FIFO queue.
+const maxFIFOCount = 31
+
+// ReadFIFOCmd reads the FIFO queue of holding registers, the queue is not
+// cleared by reading.
+type ReadFIFOCmd struct {
+	cmd
+}
+
+// NewReadFIFOCmd reads the queue at FIFO pointer address addr.
+func NewReadFIFOCmd(devAddr byte, addr uint16) *ReadFIFOCmd {
+	if devAddr == 0 {
+		panic("could not broadcast ReadFIFOCmd")
+	}
+
+	tx := make([]byte, 10)
+	tx[5] = 4
+	tx[6] = devAddr
+	tx[7] = 24
+	tx[8] = byte(addr >> 8)
+	tx[9] = byte(addr)
+
+	return &ReadFIFOCmd{cmd{
+		tx: tx,
+		rx: make([]byte, 0, maxFIFOCount*2+12),
+	}}
+}
+
+// Count is the number of registers in the queue.
+func (c *ReadFIFOCmd) Count() int {
+	return (int(c.rx[10]) << 8) | int(c.rx[11])
+}
+
+func (c *ReadFIFOCmd) Reg(i int) uint16 {
+	if i < 0 || i >= c.Count() {
+		panic(fmt.Sprintf("invalid i: %d", i))
+	}
+	return (uint16(c.rx[12+i*2]) << 8) | uint16(c.rx[13+i*2])
+}
+
+func (c *ReadFIFOCmd) Bytes() []byte {
+	return c.rx[12:]
+}
+
+func (c *ReadFIFOCmd) IsValidRx() bool {
+	return c.isValidErr() ||
+		(len(c.rx) >= 12 && c.TxId() == c.rxId() &&
+			c.rx[2] == 0 && c.rx[3] == 0 &&
+			c.rxLen() == uint16(len(c.rx)-6) &&
+			c.rx[6] == c.tx[6] && c.rx[7] == c.tx[7] &&
+			(int(c.rx[8])<<8|int(c.rx[9])) == len(c.rx)-10 &&
+			c.Count()*2+12 == len(c.rx) &&
+			c.Count() <= maxFIFOCount)
+}
+
+func (c *ReadFIFOCmd) String() string {
+	if c.IsValidRx() {
+		l := daLen(c.DevAddr()) + aLen(c.Addr()) + 12
+		if err := c.Err(); err != nil {
+			l += daLen(c.rx[6]) + 11 + len(err.Error())
+		} else {
+			l += daLen(c.rx[6]) + cLen(c.Count()) + regStrLen(c.Count()) + 13
+		}
+		noteAlloc(l)
+		b := make([]byte, 0, l)
+		b = c.aTx(b)
+		b = append(b, '\n')
+		b = c.aRx(b)
+		return unsafe.String(&b[0], len(b))
+	} else {
+		h := hexs(c.rx)
+		l := daLen(c.DevAddr()) + aLen(c.Addr()) + 14 + h.Len()
+		noteAlloc(l)
+		b := make([]byte, 0, l)
+		b = c.aTx(b)
+		b = append(b, '\n')
+		b = append(b, '[')
+		b = h.Append(b)
+		b = append(b, ']')
+		return unsafe.String(&b[0], len(b))
+	}
+}
+
+func (c *ReadFIFOCmd) Tx() string {
+	// ID  4
+	// ' ' 1
+	//  <- 2
+	// RFQ 3
+	// ' ' 1
+	// -----+
+	//    11
+	l := daLen(c.DevAddr()) + aLen(c.Addr()) + 11
+	noteAlloc(l)
+	b := c.aTx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+func (c *ReadFIFOCmd) aTx(b []byte) []byte {
+	b = hexs(c.tx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.DevAddr()), 10)
+	b = append(b, "<-RFQ "...)
+	return strconv.AppendInt(b, int64(c.Addr()), 10)
+}
+
+func (c *ReadFIFOCmd) Rx() string {
+	l := daLen(c.rx[6])
+	if err := c.Err(); err != nil {
+		// ID   4
+		// ' '  1
+		//  ->  2
+		// RFQ  3
+		// ' '  1
+		// ------+
+		//     11
+		l += 11 + len(err.Error())
+	} else {
+		// ID  4
+		// ' ' 1
+		//  -> 2
+		// RFQ 3
+		// ' ' 1
+		//  [] 2
+		// -----+
+		//    13
+		l += cLen(c.Count()) + regStrLen(c.Count()) + 13
+	}
+	noteAlloc(l)
+	b := c.aRx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+func (c *ReadFIFOCmd) aRx(b []byte) []byte {
+	b = hexs(c.rx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.rx[6]), 10)
+	b = append(b, "->RFQ "...)
+	if err := c.Err(); err != nil {
+		return append(b, err.Error()...)
+	} else {
+		b = strconv.AppendInt(b, int64(c.Count()), 10)
+		b = append(b, '[')
+		b = appendRegStr(b, c.Bytes())
+		return append(b, ']')
+	}
+}
+
+//----------------------------------------------------------------------
+
+// DevIDCode is the read device ID code of ReadDeviceIDCmd.
+type DevIDCode byte
+
+const (
+	BasicDevID    DevIDCode = 1
+	RegularDevID  DevIDCode = 2
+	ExtendedDevID DevIDCode = 3
+	// SpecificDevID reads one object only.
+	SpecificDevID DevIDCode = 4
+)
+
+// Object ID of basic and regular device identification, regular goes up to
+// 0x7F and extended is 0x80 to 0xFF.
+const (
+	VendorName          byte = 0
+	ProductCode         byte = 1
+	MajorMinorRevision  byte = 2
+	VendorURL           byte = 3
+	ProductName         byte = 4
+	ModelName           byte = 5
+	UserApplicationName byte = 6
+)
+
+type ReadDeviceIDCmd struct {
+	cmd
+}
+
+// NewReadDeviceIDCmd reads objects of code starting from objID. The device
+// answers as many as fit, when MoreFollows set objID to NextObjID and send it
+// again, or just use Controller.ReadDeviceID.
+func NewReadDeviceIDCmd(
+	devAddr byte, code DevIDCode, objID byte,
+) *ReadDeviceIDCmd {
+	if devAddr == 0 {
+		panic("could not broadcast ReadDeviceIDCmd")
+	}
+	if code < BasicDevID || code > SpecificDevID {
+		panic(fmt.Sprintf("invalid code: %d", code))
+	}
+
+	tx := make([]byte, 11)
+	tx[5] = 5
+	tx[6] = devAddr
+	tx[7] = 43
+	tx[8] = 14
+	tx[9] = byte(code)
+	tx[10] = objID
+
+	return &ReadDeviceIDCmd{cmd{
+		tx: tx,
+		rx: make([]byte, 0, maxADULen),
+	}}
+}
+
+// Addr is always 0, ReadDeviceIDCmd has no address.
+func (c *ReadDeviceIDCmd) Addr() uint16 {
+	return 0
+}
+
+func (c *ReadDeviceIDCmd) SetAddr(x uint16) {
+	panic("ReadDeviceIDCmd has no address")
+}
+
+func (c *ReadDeviceIDCmd) Code() DevIDCode {
+	return DevIDCode(c.tx[9])
+}
+
+func (c *ReadDeviceIDCmd) ObjID() byte {
+	return c.tx[10]
+}
+
+func (c *ReadDeviceIDCmd) SetObjID(x byte) {
+	c.tx[10] = x
+}
+
+// Conformity is the highest code and access type the device supports.
+func (c *ReadDeviceIDCmd) Conformity() byte {
+	return c.rx[10]
+}
+
+func (c *ReadDeviceIDCmd) MoreFollows() bool {
+	return c.rx[11] == 0xFF
+}
+
+func (c *ReadDeviceIDCmd) NextObjID() byte {
+	return c.rx[12]
+}
+
+func (c *ReadDeviceIDCmd) ObjectLen() int {
+	return int(c.rx[13])
+}
+
+// Object returns the id and value of i-th object in the response.
+func (c *ReadDeviceIDCmd) Object(i int) (byte, []byte) {
+	if i < 0 || i >= c.ObjectLen() {
+		panic(fmt.Sprintf("invalid i: %d", i))
+	}
+	j := 14
+	for ; i > 0; i-- {
+		j += 2 + int(c.rx[j+1])
+	}
+	return c.rx[j], c.rx[j+2 : j+2+int(c.rx[j+1])]
+}
+
+func (c *ReadDeviceIDCmd) IsValidRx() bool {
+	return c.isValidErr() ||
+		(len(c.rx) >= 14 && c.TxId() == c.rxId() &&
+			c.rx[2] == 0 && c.rx[3] == 0 &&
+			c.rxLen() == uint16(len(c.rx)-6) &&
+			bytes.Equal(c.rx[6:10], c.tx[6:10]) &&
+			(c.rx[11] == 0 || c.rx[11] == 0xFF) &&
+			c.isValidObjects())
+}
+
+// isValidObjects checks the objects fill the response exactly.
+func (c *ReadDeviceIDCmd) isValidObjects() bool {
+	j := 14
+	for i := 0; i < c.ObjectLen(); i++ {
+		if j+2 > len(c.rx) {
+			return false
+		}
+		j += 2 + int(c.rx[j+1])
+	}
+	return j == len(c.rx)
+}
+
+func (c *ReadDeviceIDCmd) String() string {
+	if c.IsValidRx() {
+		l := daLen(c.DevAddr()) + daLen(c.ObjID()) + 14
+		if err := c.Err(); err != nil {
+			l += daLen(c.rx[6]) + 11 + len(err.Error())
+		} else {
+			l += daLen(c.rx[6]) + c.rxStrLen()
+		}
+		noteAlloc(l)
+		b := make([]byte, 0, l)
+		b = c.aTx(b)
+		b = append(b, '\n')
+		b = c.aRx(b)
+		return unsafe.String(&b[0], len(b))
+	} else {
+		h := hexs(c.rx)
+		l := daLen(c.DevAddr()) + daLen(c.ObjID()) + 16 + h.Len()
+		noteAlloc(l)
+		b := make([]byte, 0, l)
+		b = c.aTx(b)
+		b = append(b, '\n')
+		b = append(b, '[')
+		b = h.Append(b)
+		b = append(b, ']')
+		return unsafe.String(&b[0], len(b))
+	}
+}
+
+func (c *ReadDeviceIDCmd) Tx() string {
+	// ID   4
+	// ' '  1
+	//  <-  2
+	// RID  3
+	// ' '  1
+	// code 1
+	// ' '  1
+	// ------+
+	//     13
+	l := daLen(c.DevAddr()) + daLen(c.ObjID()) + 13
+	noteAlloc(l)
+	b := c.aTx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+func (c *ReadDeviceIDCmd) aTx(b []byte) []byte {
+	b = hexs(c.tx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.DevAddr()), 10)
+	b = append(b, "<-RID "...)
+	b = strconv.AppendInt(b, int64(c.tx[9]), 10)
+	b = append(b, ' ')
+	return strconv.AppendInt(b, int64(c.ObjID()), 10)
+}
+
+func (c *ReadDeviceIDCmd) Rx() string {
+	l := daLen(c.rx[6])
+	if err := c.Err(); err != nil {
+		// ID   4
+		// ' '  1
+		//  ->  2
+		// RID  3
+		// ' '  1
+		// ------+
+		//     11
+		l += 11 + len(err.Error())
+	} else {
+		l += c.rxStrLen()
+	}
+	noteAlloc(l)
+	b := c.aRx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+// rxStrLen is the length of valid Rx without dev addr.
+func (c *ReadDeviceIDCmd) rxStrLen() int {
+	// ID   4
+	// ' '  1
+	//  ->  2
+	// RID  3
+	// ' '  1
+	// conf 2
+	// ------+
+	//     13
+	l := 13
+	for i := 0; i < c.ObjectLen(); i++ {
+		// ' ' 1
+		//   : 1
+		//  [] 2
+		// -----+
+		//     4
+		id, v := c.Object(i)
+		l += daLen(id) + hexs(v).Len() + 4
+	}
+	if c.MoreFollows() {
+		// ' '  1
+		// next 4
+		// ' '  1
+		// ------+
+		//      6
+		l += daLen(c.NextObjID()) + 6
+	}
+	return l
+}
+
+func (c *ReadDeviceIDCmd) aRx(b []byte) []byte {
+	b = hexs(c.rx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.rx[6]), 10)
+	b = append(b, "->RID "...)
+	if err := c.Err(); err != nil {
+		return append(b, err.Error()...)
+	} else {
+		b = hexs(c.rx[10:11]).Append(b)
+		for i := 0; i < c.ObjectLen(); i++ {
+			id, v := c.Object(i)
+			b = append(b, ' ')
+			b = strconv.AppendInt(b, int64(id), 10)
+			b = append(b, ":["...)
+			b = hexs(v).Append(b)
+			b = append(b, ']')
+		}
+		if c.MoreFollows() {
+			b = append(b, " next "...)
+			b = strconv.AppendInt(b, int64(c.NextObjID()), 10)
+		}
+		return b
+	}
+}
+
+//----------------------------------------------------------------------
+
+// maxRawData is PDU limit after function code.
+const maxRawData = 252
+
+// RawCmd sends any function code with data as is, it's for user defined
+// function code that has no Cmd of its own. The response is any length with
+// the same function code unless SetRxLen or SetValidator are used.
+type RawCmd struct {
+	cmd
+	rxn      int
+	validate func(data []byte) bool
+}
+
+func NewRawCmd(devAddr byte, fc byte, data []byte) *RawCmd {
+	if fc == 0 || fc >= 0x80 {
+		panic(fmt.Sprintf("invalid function code: %d", fc))
+	}
+	if len(data) > maxRawData {
+		panic(fmt.Sprintf("data too many: %d", len(data)))
+	}
+
+	tx := make([]byte, 8+len(data))
+	tx[5] = byte(2 + len(data))
+	tx[6] = devAddr
+	tx[7] = fc
+	copy(tx[8:], data)
+
+	c := &RawCmd{
+		cmd: cmd{tx: tx},
+		rxn: -1,
+	}
+	if devAddr > 0 {
+		c.rx = make([]byte, 0, c.rxCap())
+	}
+	return c
+}
+
+// rxCap has room for an exception at least.
+func (c *RawCmd) rxCap() int {
+	if c.rxn < 0 {
+		return maxADULen
+	}
+	return max(8+c.rxn, 9)
+}
+
+func (c *RawCmd) SetDevAddr(x byte) {
+	if c.tx[6] == 0 && x != 0 {
+		c.rx = make([]byte, 0, c.rxCap())
+	} else if c.tx[6] != 0 && x == 0 {
+		c.rx = nil
+	}
+
+	c.tx[6] = x
+}
+
+// Addr is always 0, RawCmd has no address.
+func (c *RawCmd) Addr() uint16 {
+	return 0
+}
+
+func (c *RawCmd) SetAddr(x uint16) {
+	panic("RawCmd has no address")
+}
+
+func (c *RawCmd) FC() byte {
+	return c.tx[7]
+}
+
+func (c *RawCmd) Data() []byte {
+	return c.tx[8:]
+}
+
+// RxLen is the expected response data length after function code, negative
+// is unknown.
+func (c *RawCmd) RxLen() int {
+	return c.rxn
+}
+
+// SetRxLen sets the expected response data length after function code,
+// negative is unknown. RTUFraming needs it to know where the response ends
+// without waiting for silence, except 0 and 1 which it can't tell apart.
+func (c *RawCmd) SetRxLen(n int) {
+	if n > maxRawData {
+		panic(fmt.Sprintf("invalid rx length: %d", n))
+	}
+	if n < 0 {
+		n = -1
+	}
+	c.rxn = n
+	if c.tx[6] != 0 {
+		c.rx = make([]byte, 0, c.rxCap())
+	}
+}
+
+// SetValidator sets f to check the response data after function code, nil
+// accepts any.
+func (c *RawCmd) SetValidator(f func(data []byte) bool) {
+	c.validate = f
+}
+
+func (c *RawCmd) RxData() []byte {
+	return c.rx[8:]
+}
+
+func (c *RawCmd) IsValidRx() bool {
+	return c.isValidErr() ||
+		(len(c.rx) >= 8 && c.TxId() == c.rxId() &&
+			c.rx[2] == 0 && c.rx[3] == 0 &&
+			c.rxLen() == uint16(len(c.rx)-6) &&
+			c.rx[6] == c.tx[6] && c.rx[7] == c.tx[7] &&
+			(c.rxn < 0 || len(c.rx) == 8+c.rxn) &&
+			(c.validate == nil || c.validate(c.rx[8:])))
+}
+
+func (c *RawCmd) String() string {
+	if cap(c.rx) > 0 {
+		if c.IsValidRx() {
+			l := c.txStrLen() + 1
+			if err := c.Err(); err != nil {
+				l += daLen(c.rx[6]) + 11 + len(err.Error())
+			} else {
+				l += daLen(c.rx[6]) + daLen(c.FC()) +
+					hexs(c.RxData()).Len() + 13
+			}
+			noteAlloc(l)
+			b := make([]byte, 0, l)
+			b = c.aTx(b)
+			b = append(b, '\n')
+			b = c.aRx(b)
+			return unsafe.String(&b[0], len(b))
+		} else {
+			h := hexs(c.rx)
+			l := c.txStrLen() + 3 + h.Len()
+			noteAlloc(l)
+			b := make([]byte, 0, l)
+			b = c.aTx(b)
+			b = append(b, '\n')
+			b = append(b, '[')
+			b = h.Append(b)
+			b = append(b, ']')
+			return unsafe.String(&b[0], len(b))
+		}
+	} else {
+		return c.Tx()
+	}
+}
+
+func (c *RawCmd) Tx() string {
+	l := c.txStrLen()
+	noteAlloc(l)
+	b := c.aTx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+func (c *RawCmd) txStrLen() int {
+	// ID  4
+	// ' ' 1
+	//  <- 2
+	// RAW 3
+	// ' ' 1
+	//  [] 2
+	// -----+
+	//    13
+	return daLen(c.DevAddr()) + daLen(c.FC()) + hexs(c.Data()).Len() + 13
+}
+
+func (c *RawCmd) aTx(b []byte) []byte {
+	b = hexs(c.tx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.DevAddr()), 10)
+	b = append(b, "<-RAW "...)
+	b = strconv.AppendInt(b, int64(c.FC()), 10)
+	b = append(b, '[')
+	b = hexs(c.Data()).Append(b)
+	return append(b, ']')
+}
+
+func (c *RawCmd) Rx() string {
+	l := daLen(c.rx[6])
+	if err := c.Err(); err != nil {
+		// ID   4
+		// ' '  1
+		//  ->  2
+		// RAW  3
+		// ' '  1
+		// ------+
+		//     11
+		l += 11 + len(err.Error())
+	} else {
+		// ID  4
+		// ' ' 1
+		//  -> 2
+		// RAW 3
+		// ' ' 1
+		//  [] 2
+		// -----+
+		//    13
+		l += daLen(c.rx[7]) + hexs(c.RxData()).Len() + 13
+	}
+	noteAlloc(l)
+	b := c.aRx(make([]byte, 0, l))
+	return unsafe.String(&b[0], len(b))
+}
+
+func (c *RawCmd) aRx(b []byte) []byte {
+	b = hexs(c.rx[:2]).Append2(b)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(c.rx[6]), 10)
+	b = append(b, "->RAW "...)
+	if err := c.Err(); err != nil {
+		return append(b, err.Error()...)
+	} else {
+		b = strconv.AppendInt(b, int64(c.rx[7]), 10)
+		b = append(b, '[')
+		b = hexs(c.RxData()).Append(b)
+		return append(b, ']')
+	}
+}
+
+//----------------------------------------------------------------------
+
+func daLen(a byte) int {
+	if a < 10 {
+		return 1
+	} else if a < 100 {
+		return 2
+	} else {
+		return 3
+	}
+}
+
+func aLen(a uint16) int {
+	if a < 10 {
+		return 1
+	} else if a < 100 {
+		return 2
+	} else if a < 1000 {
+		return 3
+	} else if a < 10000 {
+		return 4
+	} else {
+		return 5
+	}
+}
+
+func cLen(c int) int {
+	if c < 10 {
+		return 1
+	} else if c < 100 {
+		return 2
+	} else if c < 1000 {
+		return 3
+	} else {
+		return 4
+	}
+}
+
+// regStrLen is the length of n registers by appendRegStr.
+func regStrLen(n int) int {
+	if n == 0 {
+		return 0
+	}
+	// 5 digits and ' ' between, "\n " every 10 and " : " every other 5
+	l := n*6 - 1 + (n-1)/10 + ((n-1)/5-(n-1)/10)*2
+	if n > 10 {
+		l += 3
+	}
+	return l
+}
+
+// appendRegStr appends big endian registers of p, right aligned in group of 5
+// and 10 per line like ReadHRegsCmd.
+func appendRegStr(b []byte, p []byte) []byte {
+	var x [5]byte
+	n := len(p) / 2
+	for i := 0; i < n; i++ {
+		if i == 0 && n > 10 {
+			b = append(b, '\n')
+			b = append(b, ' ')
+		}
+		if i > 0 {
+			if i%10 == 0 {
+				b = append(b, '\n')
+				b = append(b, ' ')
+			} else {
+				b = append(b, ' ')
+				if i%5 == 0 {
+					b = append(b, ':')
+					b = append(b, ' ')
+				}
+			}
+		}
+		v := uint16(p[i*2])<<8 | uint16(p[i*2+1])
+		t := strconv.AppendInt(x[:0], int64(v), 10)
+		for j := len(t); j < 5; j++ {
+			b = append(b, ' ')
+		}
+		b = append(b, t...)
+	}
+	if n > 10 {
+		b = append(b, '\n')
+	}
+	return b
+}
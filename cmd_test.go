@@ -3221,3 +3221,1544 @@ var _ = Describe("WriteRegsCmd", func() {
 		})
 	})
 })
+
+var _ = Describe("ReadExceptionStatusCmd", func() {
+	var cmd *ReadExceptionStatusCmd
+	SetRx := func(b []byte) {
+		BeforeEach(func() {
+			rx := cmd.RxBytes()
+			*rx = (*rx)[:len(b)]
+			copy(*rx, b)
+		})
+	}
+
+	String := func(x string) {
+		It("has String", func() {
+			Expect(cmd.String()).To(Equal(x))
+		})
+	}
+	OnlyTx := func(dev byte, s string, b []byte) {
+		It("has Tx Bytes", func() {
+			Expect(cmd.TxBytes()).To(Equal(b))
+		})
+		It("has Dev Addr", func() {
+			Expect(cmd.DevAddr()).To(Equal(dev))
+		})
+		It("has no Addr", func() {
+			Expect(cmd.Addr()).To(BeZero())
+			Expect(func() {
+				cmd.SetAddr(1)
+			}).Should(PanicWith("ReadExceptionStatusCmd has no address"))
+		})
+		It("has Tx String", func() {
+			Expect(cmd.Tx()).To(Equal(s))
+		})
+		String(s + "\n[]")
+	}
+
+	It("could not broadcast", func() {
+		Expect(func() {
+			NewReadExceptionStatusCmd(0)
+		}).Should(PanicWith("could not broadcast ReadExceptionStatusCmd"))
+	})
+
+	const dev = 17
+	BeforeEach(func() {
+		cmd = NewReadExceptionStatusCmd(dev)
+	})
+
+	const tx = "0000 17<-RES"
+	Context("New", func() {
+		OnlyTx(dev, tx, []byte{0, 0, 0, 0, 0, 2, dev, 7})
+	})
+
+	Context("Tx Id changed", func() {
+		BeforeEach(func() {
+			cmd.SetTxId(0xBEEF)
+		})
+
+		OnlyTx(dev, "BEEF 17<-RES", []byte{0xBE, 0xEF, 0, 0, 0, 2, dev, 7})
+	})
+
+	Context("Valid Rx", func() {
+		b := []byte{0, 0, 0, 0, 0, 3, dev, 7, 0b10000101}
+		const rx = "0000 17->RES 10000101"
+
+		SetRx(b)
+		It("is Valid Rx", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+		})
+		It("has Rx String", func() {
+			Expect(cmd.Rx()).To(Equal(rx))
+		})
+		String(tx + "\n" + rx)
+		It("has Status", func() {
+			Expect(cmd.Err()).To(Succeed())
+			Expect(cmd.Status()).To(Equal(byte(0b10000101)))
+			Expect(cmd.Output(0)).To(BeTrue())
+			Expect(cmd.Output(1)).To(BeFalse())
+			Expect(cmd.Output(2)).To(BeTrue())
+			Expect(cmd.Output(7)).To(BeTrue())
+			Expect(func() {
+				cmd.Output(8)
+			}).Should(PanicWith("invalid i: 8"))
+		})
+	})
+
+	Context("Err Rx", func() {
+		b := []byte{0, 0, 0, 0, 0, 3, dev, 0x87, 1}
+		const rx = "0000 17->RES Illegal Function"
+
+		SetRx(b)
+		It("is Valid Rx", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+		})
+		It("has Rx String", func() {
+			Expect(cmd.Rx()).To(Equal(rx))
+		})
+		String(tx + "\n" + rx)
+		It("has Err", func() {
+			Expect(cmd.Err()).To(Equal(IllegalFunction))
+		})
+	})
+
+	Context("Invalid Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 3, dev, 3, 1})
+
+		It("is not Valid Rx", func() {
+			Expect(cmd.IsValidRx()).To(BeFalse())
+		})
+		String(tx + "\n[00 00 00 00 00 03 11 03 01]")
+	})
+})
+
+var _ = Describe("DiagnosticsCmd", func() {
+	var cmd *DiagnosticsCmd
+	SetRx := func(b []byte) {
+		BeforeEach(func() {
+			rx := cmd.RxBytes()
+			*rx = (*rx)[:len(b)]
+			copy(*rx, b)
+		})
+	}
+
+	String := func(x string) {
+		It("has String", func() {
+			Expect(cmd.String()).To(Equal(x))
+		})
+	}
+	OnlyTx := func(dev byte, sub DiagSub, s string, b []byte) {
+		It("has Tx Bytes", func() {
+			Expect(cmd.TxBytes()).To(Equal(b))
+		})
+		It("has Dev Addr", func() {
+			Expect(cmd.DevAddr()).To(Equal(dev))
+		})
+		It("has no Addr", func() {
+			Expect(cmd.Addr()).To(BeZero())
+			Expect(func() {
+				cmd.SetAddr(1)
+			}).Should(PanicWith("DiagnosticsCmd has no address"))
+		})
+		It("has Sub", func() {
+			Expect(cmd.Sub()).To(Equal(sub))
+			Expect(cmd.Data()).To(Equal(b[10:]))
+		})
+		It("has Tx String", func() {
+			Expect(cmd.Tx()).To(Equal(s))
+		})
+		if dev == 0 || sub == ForceListenOnly {
+			String(s)
+		} else {
+			String(s + "\n[]")
+		}
+	}
+	GoodRx := func(b []byte, tx, rx string) {
+		It("is Valid Rx", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+		})
+		It("has Rx Bytes", func() {
+			Expect(*cmd.RxBytes()).To(Equal(b))
+		})
+		It("has Rx String", func() {
+			Expect(cmd.Rx()).To(Equal(rx))
+		})
+		String(tx + "\n" + rx)
+		It("has no Err", func() {
+			Expect(cmd.Err()).To(Succeed())
+		})
+	}
+
+	Context("restart communications", func() {
+		const dev = 1
+		const tx = "0000 1<-DIA 1 65280"
+		BeforeEach(func() {
+			cmd = NewDiagnosticsCmd(dev, RestartComm, 0xFF00)
+		})
+
+		Context("New", func() {
+			OnlyTx(dev, RestartComm, tx, []byte{
+				0, 0, 0, 0, 0, 6, dev, 8, 0, 1, 0xFF, 0,
+			})
+		})
+
+		Context("Dev Addr changed to broadcast", func() {
+			BeforeEach(func() {
+				cmd.SetDevAddr(0)
+			})
+
+			OnlyTx(0, RestartComm, "0000 0<-DIA 1 65280", []byte{
+				0, 0, 0, 0, 0, 6, 0, 8, 0, 1, 0xFF, 0,
+			})
+		})
+
+		Context("Valid Rx", func() {
+			b := []byte{0, 0, 0, 0, 0, 6, dev, 8, 0, 1, 0xFF, 0}
+
+			SetRx(b)
+			GoodRx(b, tx, "0000 1->DIA 1 65280")
+		})
+
+		Context("Not echoed Rx", func() {
+			SetRx([]byte{0, 0, 0, 0, 0, 6, dev, 8, 0, 1, 0, 0})
+
+			It("is not Valid Rx", func() {
+				Expect(cmd.IsValidRx()).To(BeFalse())
+			})
+			String(tx + "\n[00 00 00 00 00 06 01 08 00 01 00 00]")
+		})
+
+		Context("Err Rx", func() {
+			b := []byte{0, 0, 0, 0, 0, 3, dev, 0x88, 1}
+			const rx = "0000 1->DIA Illegal Function"
+
+			SetRx(b)
+			It("is Valid Rx", func() {
+				Expect(cmd.IsValidRx()).To(BeTrue())
+			})
+			It("has Rx String", func() {
+				Expect(cmd.Rx()).To(Equal(rx))
+			})
+			String(tx + "\n" + rx)
+			It("has Err", func() {
+				Expect(cmd.Err()).To(Equal(IllegalFunction))
+			})
+		})
+	})
+
+	Context("return counter", func() {
+		const dev = 2
+		const tx = "0000 2<-DIA 11 0"
+		BeforeEach(func() {
+			cmd = NewDiagnosticsCmd(dev, ReturnBusMsgCount, 0)
+		})
+
+		Context("New", func() {
+			OnlyTx(dev, ReturnBusMsgCount, tx, []byte{
+				0, 0, 0, 0, 0, 6, dev, 8, 0, 11, 0, 0,
+			})
+		})
+
+		Context("Valid Rx", func() {
+			b := []byte{0, 0, 0, 0, 0, 6, dev, 8, 0, 11, 0x12, 0x34}
+
+			SetRx(b)
+			GoodRx(b, tx, "0000 2->DIA 11 4660")
+			It("has Value", func() {
+				Expect(cmd.Value()).To(Equal(uint16(0x1234)))
+				Expect(cmd.RxData()).To(Equal([]byte{0x12, 0x34}))
+			})
+		})
+
+		Context("Other sub Rx", func() {
+			SetRx([]byte{0, 0, 0, 0, 0, 6, dev, 8, 0, 12, 0x12, 0x34})
+
+			It("is not Valid Rx", func() {
+				Expect(cmd.IsValidRx()).To(BeFalse())
+			})
+		})
+	})
+
+	Context("return query data", func() {
+		const dev = 3
+		const tx = "0000 3<-DIA 0 [A5 37 00]"
+		BeforeEach(func() {
+			cmd = NewQueryDataCmd(dev, []byte{0xA5, 0x37, 0})
+		})
+
+		Context("New", func() {
+			OnlyTx(dev, ReturnQueryData, tx, []byte{
+				0, 0, 0, 0, 0, 7, dev, 8, 0, 0, 0xA5, 0x37, 0,
+			})
+		})
+
+		Context("Valid Rx", func() {
+			b := []byte{0, 0, 0, 0, 0, 7, dev, 8, 0, 0, 0xA5, 0x37, 0}
+
+			SetRx(b)
+			GoodRx(b, tx, "0000 3->DIA 0 [A5 37 00]")
+		})
+
+		It("panics on bad data", func() {
+			Expect(func() {
+				NewQueryDataCmd(dev, nil)
+			}).Should(PanicWith("empty data"))
+			Expect(func() {
+				NewQueryDataCmd(dev, make([]byte, 251))
+			}).Should(PanicWith("data too many: 251"))
+		})
+	})
+
+	Context("force listen only", func() {
+		const tx = "0000 4<-DIA 4 0"
+		BeforeEach(func() {
+			cmd = NewDiagnosticsCmd(4, ForceListenOnly, 0)
+		})
+
+		Context("New", func() {
+			OnlyTx(4, ForceListenOnly, tx, []byte{
+				0, 0, 0, 0, 0, 6, 4, 8, 0, 4, 0, 0,
+			})
+		})
+
+		Context("Dev Addr changed", func() {
+			BeforeEach(func() {
+				cmd.SetDevAddr(0)
+				cmd.SetDevAddr(5)
+			})
+
+			OnlyTx(5, ForceListenOnly, "0000 5<-DIA 4 0", []byte{
+				0, 0, 0, 0, 0, 6, 5, 8, 0, 4, 0, 0,
+			})
+		})
+	})
+})
+
+var _ = Describe("GetCommEventCounterCmd", func() {
+	var cmd *GetCommEventCounterCmd
+	SetRx := func(b []byte) {
+		BeforeEach(func() {
+			rx := cmd.RxBytes()
+			*rx = (*rx)[:len(b)]
+			copy(*rx, b)
+		})
+	}
+
+	It("could not broadcast", func() {
+		Expect(func() {
+			NewGetCommEventCounterCmd(0)
+		}).Should(PanicWith("could not broadcast GetCommEventCounterCmd"))
+	})
+
+	const dev = 5
+	const tx = "0000 5<-CEC"
+	BeforeEach(func() {
+		cmd = NewGetCommEventCounterCmd(dev)
+	})
+
+	Context("New", func() {
+		It("has Tx", func() {
+			Expect(cmd.TxBytes()).To(Equal([]byte{0, 0, 0, 0, 0, 2, dev, 11}))
+			Expect(cmd.Tx()).To(Equal(tx))
+			Expect(cmd.String()).To(Equal(tx + "\n[]"))
+			Expect(cmd.Addr()).To(BeZero())
+			Expect(func() {
+				cmd.SetAddr(1)
+			}).Should(PanicWith("GetCommEventCounterCmd has no address"))
+		})
+	})
+
+	Context("Ready Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 6, dev, 11, 0, 0, 0x01, 0x08})
+
+		It("has counter", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.Err()).To(Succeed())
+			Expect(cmd.Status()).To(BeZero())
+			Expect(cmd.Busy()).To(BeFalse())
+			Expect(cmd.EventCount()).To(Equal(uint16(264)))
+			Expect(cmd.Rx()).To(Equal("0000 5->CEC ready 264"))
+			Expect(cmd.String()).To(Equal(tx + "\n0000 5->CEC ready 264"))
+		})
+	})
+
+	Context("Busy Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 6, dev, 11, 0xFF, 0xFF, 0, 3})
+
+		It("is busy", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.Busy()).To(BeTrue())
+			Expect(cmd.Rx()).To(Equal("0000 5->CEC busy 3"))
+		})
+	})
+
+	Context("Err Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 3, dev, 0x8B, 1})
+
+		It("has Err", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.Err()).To(Equal(IllegalFunction))
+			Expect(cmd.Rx()).To(Equal("0000 5->CEC Illegal Function"))
+		})
+	})
+
+	Context("Invalid Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 5, dev, 11, 0, 0, 1})
+
+		It("is not Valid Rx", func() {
+			Expect(cmd.IsValidRx()).To(BeFalse())
+			Expect(cmd.String()).To(Equal(
+				tx + "\n[00 00 00 00 00 05 05 0B 00 00 01]"))
+		})
+	})
+})
+
+var _ = Describe("GetCommEventLogCmd", func() {
+	var cmd *GetCommEventLogCmd
+	SetRx := func(b []byte) {
+		BeforeEach(func() {
+			rx := cmd.RxBytes()
+			*rx = (*rx)[:len(b)]
+			copy(*rx, b)
+		})
+	}
+
+	It("could not broadcast", func() {
+		Expect(func() {
+			NewGetCommEventLogCmd(0)
+		}).Should(PanicWith("could not broadcast GetCommEventLogCmd"))
+	})
+
+	const dev = 6
+	const tx = "0000 6<-CEL"
+	BeforeEach(func() {
+		cmd = NewGetCommEventLogCmd(dev)
+	})
+
+	Context("New", func() {
+		It("has Tx", func() {
+			Expect(cmd.TxBytes()).To(Equal([]byte{0, 0, 0, 0, 0, 2, dev, 12}))
+			Expect(cmd.Tx()).To(Equal(tx))
+			Expect(cmd.String()).To(Equal(tx + "\n[]"))
+			Expect(cmd.Addr()).To(BeZero())
+		})
+	})
+
+	Context("Valid Rx", func() {
+		const rx = "0000 6->CEL ready 264 289:3[20 00 C2]"
+		SetRx([]byte{0, 0, 0, 0, 0, 12, dev, 12, 9, 0, 0, 0x01, 0x08,
+			0x01, 0x21, 0x20, 0x00, 0xC2})
+
+		It("has log", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.Err()).To(Succeed())
+			Expect(cmd.Busy()).To(BeFalse())
+			Expect(cmd.EventCount()).To(Equal(uint16(264)))
+			Expect(cmd.MsgCount()).To(Equal(uint16(289)))
+			Expect(cmd.EventLen()).To(Equal(3))
+			Expect(cmd.Bytes()).To(Equal([]byte{0x20, 0x00, 0xC2}))
+			Expect(cmd.Event(0).String()).To(Equal("event 20"))
+			Expect(cmd.Event(1).IsRestart()).To(BeTrue())
+			Expect(cmd.Event(2).IsReceive()).To(BeTrue())
+			Expect(cmd.Event(2).String()).To(Equal("rx:comm err,broadcast"))
+			Expect(func() {
+				cmd.Event(3)
+			}).Should(PanicWith("invalid i: 3"))
+			Expect(cmd.Rx()).To(Equal(rx))
+			Expect(cmd.String()).To(Equal(tx + "\n" + rx))
+		})
+	})
+
+	Context("Empty log Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 9, dev, 12, 6, 0xFF, 0xFF, 0, 0, 0, 0})
+
+		It("has no events", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.Busy()).To(BeTrue())
+			Expect(cmd.EventLen()).To(BeZero())
+			Expect(cmd.Rx()).To(Equal("0000 6->CEL busy 0 0:0[]"))
+		})
+	})
+
+	Context("Bad byte count Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 10, dev, 12, 6, 0, 0, 0, 0, 0, 0, 1})
+
+		It("is not Valid Rx", func() {
+			Expect(cmd.IsValidRx()).To(BeFalse())
+		})
+	})
+})
+
+var _ = Describe("CommEvent", func() {
+	DescribeTable("String",
+		func(e byte, s string) {
+			Expect(CommEvent(e).String()).To(Equal(s))
+		},
+		Entry("restart", byte(0x00), "restart"),
+		Entry("listen only", byte(0x04), "listen only"),
+		Entry("receive", byte(0x80), "rx:"),
+		Entry("receive overrun", byte(0xB0), "rx:overrun,listen only"),
+		Entry("send", byte(0x41), "tx:read exc"),
+		Entry("send busy", byte(0x54), "tx:busy exc,write timeout"),
+		Entry("unknown", byte(0x01), "event 01"),
+	)
+})
+
+var _ = Describe("ReportServerIDCmd", func() {
+	var cmd *ReportServerIDCmd
+	SetRx := func(b []byte) {
+		BeforeEach(func() {
+			rx := cmd.RxBytes()
+			*rx = (*rx)[:len(b)]
+			copy(*rx, b)
+		})
+	}
+
+	It("could not broadcast", func() {
+		Expect(func() {
+			NewReportServerIDCmd(0)
+		}).Should(PanicWith("could not broadcast ReportServerIDCmd"))
+	})
+
+	const dev = 7
+	const tx = "0000 7<-RSI"
+	BeforeEach(func() {
+		cmd = NewReportServerIDCmd(dev)
+	})
+
+	Context("New", func() {
+		It("has Tx", func() {
+			Expect(cmd.TxBytes()).To(Equal([]byte{0, 0, 0, 0, 0, 2, dev, 17}))
+			Expect(cmd.Tx()).To(Equal(tx))
+			Expect(cmd.String()).To(Equal(tx + "\n[]"))
+			Expect(cmd.Addr()).To(BeZero())
+			Expect(cmd.IDLen()).To(Equal(1))
+			Expect(func() {
+				cmd.SetAddr(1)
+			}).Should(PanicWith("ReportServerIDCmd has no address"))
+			Expect(func() {
+				cmd.SetIDLen(-1)
+			}).Should(PanicWith("invalid ID length: -1"))
+		})
+	})
+
+	Context("Valid Rx", func() {
+		const rx = "0000 7->RSI [2A] on [41 42 43]"
+		SetRx([]byte{0, 0, 0, 0, 0, 8, dev, 17, 5, 0x2A, 0xFF, 'A', 'B', 'C'})
+
+		It("has ID", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.Err()).To(Succeed())
+			Expect(cmd.ServerID()).To(Equal([]byte{0x2A}))
+			Expect(cmd.Run()).To(BeTrue())
+			Expect(cmd.Data()).To(Equal([]byte("ABC")))
+			Expect(cmd.Rx()).To(Equal(rx))
+			Expect(cmd.String()).To(Equal(tx + "\n" + rx))
+		})
+
+		Context("longer ID", func() {
+			BeforeEach(func() {
+				cmd.SetIDLen(2)
+			})
+
+			It("is split by IDLen", func() {
+				Expect(cmd.IsValidRx()).To(BeTrue())
+				Expect(cmd.ServerID()).To(Equal([]byte{0x2A, 0xFF}))
+				Expect(cmd.Run()).To(BeFalse())
+				Expect(cmd.Data()).To(Equal([]byte("BC")))
+			})
+		})
+
+		Context("ID longer than rx", func() {
+			BeforeEach(func() {
+				cmd.SetIDLen(7)
+			})
+
+			It("is cut short", func() {
+				Expect(cmd.IsValidRx()).To(BeTrue())
+				Expect(cmd.ServerID()).
+					To(Equal([]byte{0x2A, 0xFF, 'A', 'B', 'C'}))
+				Expect(cmd.Run()).To(BeFalse())
+				Expect(cmd.Data()).To(BeEmpty())
+				Expect(cmd.Rx()).
+					To(Equal("0000 7->RSI [2A FF 41 42 43] off []"))
+			})
+		})
+	})
+
+	Context("Off Rx", func() {
+		const rx = "0000 7->RSI [2A 01] off []"
+		SetRx([]byte{0, 0, 0, 0, 0, 6, dev, 17, 3, 0x2A, 0x01, 0})
+		BeforeEach(func() {
+			cmd.SetIDLen(2)
+		})
+
+		It("has ID", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.ServerID()).To(Equal([]byte{0x2A, 0x01}))
+			Expect(cmd.Run()).To(BeFalse())
+			Expect(cmd.Data()).To(BeEmpty())
+			Expect(cmd.Rx()).To(Equal(rx))
+		})
+	})
+
+	Context("3 bytes ID Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 7, dev, 17, 4, 1, 2, 3, 0xFF})
+
+		It("is Valid Rx of any IDLen", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			cmd.SetIDLen(3)
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.ServerID()).To(Equal([]byte{1, 2, 3}))
+			Expect(cmd.Run()).To(BeTrue())
+			Expect(cmd.Data()).To(BeEmpty())
+			Expect(cmd.Rx()).To(Equal("0000 7->RSI [01 02 03] on []"))
+		})
+	})
+
+	Context("Bad byte count Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 5, dev, 17, 3, 0x2A, 0xFF})
+
+		It("is not Valid Rx", func() {
+			Expect(cmd.IsValidRx()).To(BeFalse())
+			Expect(cmd.String()).To(Equal(
+				tx + "\n[00 00 00 00 00 05 07 11 03 2A FF]"))
+		})
+	})
+
+	Context("Err Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 3, dev, 0x91, 1})
+
+		It("has Err", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.Err()).To(Equal(IllegalFunction))
+			Expect(cmd.Rx()).To(Equal("0000 7->RSI Illegal Function"))
+		})
+	})
+
+	It("is sent by Controller", func() {
+		ResetClock()
+		NewLog()
+		conn := &MockConn{
+			Writes: []WriteScript{
+				{8, nil},
+			},
+			Reads: []ReadScript{
+				{[]byte{0, 1, 0, 0, 0, 5, dev, 17, 2, 0x2A, 0}, nil},
+			},
+		}
+		con := &Controller{Dialer: &MockDialer{
+			Dials: []DialScript{
+				{conn, TIMEOUT, 0, 1, nil},
+			},
+		}}
+		Expect(con.Send(cmd)).To(Succeed())
+		Expect(cmd.ServerID()).To(Equal([]byte{0x2A}))
+		Expect(cmd.Run()).To(BeFalse())
+	})
+})
+
+var _ = Describe("ReadDeviceIDCmd", func() {
+	var cmd *ReadDeviceIDCmd
+	SetRx := func(b []byte) {
+		BeforeEach(func() {
+			rx := cmd.RxBytes()
+			*rx = (*rx)[:len(b)]
+			copy(*rx, b)
+		})
+	}
+
+	It("could not broadcast", func() {
+		Expect(func() {
+			NewReadDeviceIDCmd(0, BasicDevID, 0)
+		}).Should(PanicWith("could not broadcast ReadDeviceIDCmd"))
+	})
+
+	It("has valid code", func() {
+		Expect(func() {
+			NewReadDeviceIDCmd(1, 0, 0)
+		}).Should(PanicWith("invalid code: 0"))
+		Expect(func() {
+			NewReadDeviceIDCmd(1, 5, 0)
+		}).Should(PanicWith("invalid code: 5"))
+	})
+
+	const dev = 7
+	const tx = "0000 7<-RID 2 0"
+	BeforeEach(func() {
+		cmd = NewReadDeviceIDCmd(dev, RegularDevID, 0)
+	})
+
+	Context("New", func() {
+		It("has Tx", func() {
+			Expect(cmd.TxBytes()).To(Equal(
+				[]byte{0, 0, 0, 0, 0, 5, dev, 43, 14, 2, 0}))
+			Expect(cmd.Tx()).To(Equal(tx))
+			Expect(cmd.String()).To(Equal(tx + "\n[]"))
+			Expect(cmd.Code()).To(Equal(RegularDevID))
+			Expect(cmd.ObjID()).To(BeZero())
+			Expect(cmd.Addr()).To(BeZero())
+			Expect(func() {
+				cmd.SetAddr(1)
+			}).Should(PanicWith("ReadDeviceIDCmd has no address"))
+		})
+
+		It("could set ObjID", func() {
+			cmd.SetObjID(128)
+			Expect(cmd.ObjID()).To(Equal(byte(128)))
+			Expect(cmd.Tx()).To(Equal("0000 7<-RID 2 128"))
+		})
+	})
+
+	Context("Valid Rx", func() {
+		const rx = "0000 7->RID 82 0:[41 42] 1:[] 4:[43] next 5"
+		SetRx([]byte{0, 0, 0, 0, 0, 17, dev, 43, 14, 2, 0x82, 0xFF, 5, 3,
+			0, 2, 'A', 'B',
+			1, 0,
+			4, 1, 'C'})
+
+		It("has objects", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.Err()).To(Succeed())
+			Expect(cmd.Conformity()).To(Equal(byte(0x82)))
+			Expect(cmd.MoreFollows()).To(BeTrue())
+			Expect(cmd.NextObjID()).To(Equal(ProductName + 1))
+			Expect(cmd.ObjectLen()).To(Equal(3))
+			id, v := cmd.Object(0)
+			Expect(id).To(Equal(VendorName))
+			Expect(v).To(Equal([]byte("AB")))
+			id, v = cmd.Object(1)
+			Expect(id).To(Equal(ProductCode))
+			Expect(v).To(BeEmpty())
+			id, v = cmd.Object(2)
+			Expect(id).To(Equal(ProductName))
+			Expect(v).To(Equal([]byte("C")))
+			Expect(func() {
+				cmd.Object(3)
+			}).Should(PanicWith("invalid i: 3"))
+			Expect(cmd.Rx()).To(Equal(rx))
+			Expect(cmd.String()).To(Equal(tx + "\n" + rx))
+		})
+	})
+
+	Context("Last Rx", func() {
+		const rx = "0000 7->RID 01"
+		SetRx([]byte{0, 0, 0, 0, 0, 8, dev, 43, 14, 2, 1, 0, 0, 0})
+
+		It("has no objects", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.MoreFollows()).To(BeFalse())
+			Expect(cmd.ObjectLen()).To(BeZero())
+			Expect(cmd.Rx()).To(Equal(rx))
+		})
+	})
+
+	Context("Short object Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 11, dev, 43, 14, 2, 1, 0, 0, 1,
+			0, 2, 'A'})
+
+		It("is not Valid Rx", func() {
+			Expect(cmd.IsValidRx()).To(BeFalse())
+			Expect(cmd.String()).To(Equal(tx +
+				"\n[00 00 00 00 00 0B 07 2B 0E 02 01 00 00 01 00 02 41]"))
+		})
+	})
+
+	Context("Bad more follows Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 8, dev, 43, 14, 2, 1, 1, 0, 0})
+
+		It("is not Valid Rx", func() {
+			Expect(cmd.IsValidRx()).To(BeFalse())
+		})
+	})
+
+	Context("Other code Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 8, dev, 43, 14, 1, 1, 0, 0, 0})
+
+		It("is not Valid Rx", func() {
+			Expect(cmd.IsValidRx()).To(BeFalse())
+		})
+	})
+
+	Context("Err Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 3, dev, 0xAB, 2})
+
+		It("has Err", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.Err()).To(Equal(IllegalDataAddress))
+			Expect(cmd.Rx()).To(Equal("0000 7->RID Illegal Data Address"))
+		})
+	})
+})
+
+var _ = Describe("MaskWriteRegCmd", func() {
+	var cmd *MaskWriteRegCmd
+	SetRx := func(b []byte) {
+		BeforeEach(func() {
+			rx := cmd.RxBytes()
+			*rx = (*rx)[:len(b)]
+			copy(*rx, b)
+		})
+	}
+
+	It("sets bit", func() {
+		cmd := NewSetRegBitCmd(1, 2, 3)
+		Expect(cmd.AndMask()).To(Equal(uint16(0xFFF7)))
+		Expect(cmd.OrMask()).To(Equal(uint16(0x0008)))
+		Expect(cmd.Apply(0x0012)).To(Equal(uint16(0x001A)))
+		Expect(cmd.Apply(0x001A)).To(Equal(uint16(0x001A)))
+		Expect(cmd.Tx()).To(Equal("0000 1<-MWR 2 &FFF7 |0008"))
+	})
+
+	It("clears bit", func() {
+		cmd := NewClearRegBitCmd(1, 2, 15)
+		Expect(cmd.AndMask()).To(Equal(uint16(0x7FFF)))
+		Expect(cmd.OrMask()).To(BeZero())
+		Expect(cmd.Apply(0x8012)).To(Equal(uint16(0x0012)))
+		Expect(cmd.Apply(0x0012)).To(Equal(uint16(0x0012)))
+	})
+
+	It("has valid bit", func() {
+		Expect(func() {
+			NewSetRegBitCmd(1, 2, 16)
+		}).Should(PanicWith("invalid bit: 16"))
+		Expect(func() {
+			NewClearRegBitCmd(1, 2, -1)
+		}).Should(PanicWith("invalid bit: -1"))
+	})
+
+	It("applies the spec example", func() {
+		cmd := NewMaskWriteRegCmd(1, 4, 0x00F2, 0x0025)
+		Expect(cmd.Apply(0x0012)).To(Equal(uint16(0x0017)))
+	})
+
+	Context("broadcast", func() {
+		const tx = "0000 0<-MWR 258 &00F2 |0025"
+		BeforeEach(func() {
+			cmd = NewMaskWriteRegCmd(0, 258, 0x00F2, 0x0025)
+		})
+
+		It("has Tx", func() {
+			Expect(cmd.TxBytes()).To(Equal([]byte{
+				0, 0, 0, 0, 0, 8, 0, 22, 1, 2, 0x00, 0xF2, 0x00, 0x25,
+			}))
+			Expect(cmd.Tx()).To(Equal(tx))
+			Expect(cmd.String()).To(Equal(tx))
+			Expect(cap(*cmd.RxBytes())).To(BeZero())
+		})
+
+		It("could change masks", func() {
+			cmd.SetAndMask(0xBEEF)
+			cmd.SetOrMask(0xDEAD)
+			Expect(cmd.AndMask()).To(Equal(uint16(0xBEEF)))
+			Expect(cmd.OrMask()).To(Equal(uint16(0xDEAD)))
+			Expect(cmd.Tx()).To(Equal("0000 0<-MWR 258 &BEEF |DEAD"))
+		})
+
+		It("could change Dev Addr", func() {
+			cmd.SetDevAddr(3)
+			Expect(cap(*cmd.RxBytes())).To(Equal(14))
+			Expect(cmd.String()).To(Equal("0000 3<-MWR 258 &00F2 |0025\n[]"))
+			cmd.SetDevAddr(0)
+			Expect(cap(*cmd.RxBytes())).To(BeZero())
+		})
+	})
+
+	Context("non broadcast", func() {
+		const dev = 1
+		const tx = "0000 1<-MWR 4 &00F2 |0025"
+		BeforeEach(func() {
+			cmd = NewMaskWriteRegCmd(dev, 4, 0x00F2, 0x0025)
+		})
+
+		Context("Valid Rx", func() {
+			const rx = "0000 1->MWR 4 &00F2 |0025"
+			SetRx([]byte{
+				0, 0, 0, 0, 0, 8, dev, 22, 0, 4, 0x00, 0xF2, 0x00, 0x25,
+			})
+
+			It("is echo", func() {
+				Expect(cmd.IsValidRx()).To(BeTrue())
+				Expect(cmd.Err()).To(Succeed())
+				Expect(cmd.Rx()).To(Equal(rx))
+				Expect(cmd.String()).To(Equal(tx + "\n" + rx))
+			})
+		})
+
+		Context("Different mask Rx", func() {
+			SetRx([]byte{
+				0, 0, 0, 0, 0, 8, dev, 22, 0, 4, 0x00, 0xF2, 0x00, 0x24,
+			})
+
+			It("is not Valid Rx", func() {
+				Expect(cmd.IsValidRx()).To(BeFalse())
+				Expect(cmd.String()).To(Equal(tx +
+					"\n[00 00 00 00 00 08 01 16 00 04 00 F2 00 24]"))
+			})
+		})
+
+		Context("Err Rx", func() {
+			SetRx([]byte{0, 0, 0, 0, 0, 3, dev, 0x96, 2})
+
+			It("has Err", func() {
+				Expect(cmd.IsValidRx()).To(BeTrue())
+				Expect(cmd.Err()).To(Equal(IllegalDataAddress))
+				Expect(cmd.Rx()).To(Equal("0000 1->MWR Illegal Data Address"))
+			})
+		})
+	})
+})
+
+var _ = Describe("ReadWriteRegsCmd", func() {
+	var cmd *ReadWriteRegsCmd
+	SetRx := func(b []byte) {
+		BeforeEach(func() {
+			rx := cmd.RxBytes()
+			*rx = (*rx)[:len(b)]
+			copy(*rx, b)
+		})
+	}
+
+	It("could not broadcast", func() {
+		Expect(func() {
+			NewReadWriteRegsCmd(0, 1, 1, 1, []uint16{1})
+		}).Should(PanicWith("could not broadcast ReadWriteRegsCmd"))
+	})
+
+	It("checks limits", func() {
+		Expect(func() {
+			NewReadWriteRegsCmd(1, 1, 0, 1, []uint16{1})
+		}).Should(PanicWith("zero count"))
+		Expect(func() {
+			NewReadWriteRegsCmd(1, 1, 126, 1, []uint16{1})
+		}).Should(PanicWith("count too many: 126"))
+		Expect(func() {
+			NewReadWriteRegsCmd(1, 65535, 2, 1, []uint16{1})
+		}).Should(PanicWith("address overflow: 65535, 2"))
+		Expect(func() {
+			NewReadWriteRegsCmd(1, 1, 1, 1, nil)
+		}).Should(PanicWith("empty values"))
+		Expect(func() {
+			NewReadWriteRegsCmd(1, 1, 1, 1, make([]uint16, 122))
+		}).Should(PanicWith("values too many: 122"))
+		Expect(func() {
+			NewReadWriteRegsCmd(1, 1, 1, 65534, make([]uint16, 3))
+		}).Should(PanicWith("address overflow: 65534, 3"))
+		Expect(NewReadWriteRegsCmd(1, 1, 125, 1, make([]uint16, 121))).
+			NotTo(BeNil())
+	})
+
+	const dev = 3
+	const tx = "0000 3<-RWR 100:2 200:3[    1   515 65535]"
+	BeforeEach(func() {
+		cmd = NewReadWriteRegsCmd(dev, 100, 2, 200, []uint16{1, 0x203, 0xFFFF})
+	})
+
+	Context("New", func() {
+		It("has Tx", func() {
+			Expect(cmd.TxBytes()).To(Equal([]byte{
+				0, 0, 0, 0, 0, 17, dev, 23, 0, 100, 0, 2, 0, 200, 0, 3, 6,
+				0, 1, 2, 3, 0xFF, 0xFF,
+			}))
+			Expect(cmd.Addr()).To(Equal(uint16(100)))
+			Expect(cmd.Count()).To(Equal(2))
+			Expect(cmd.WriteAddr()).To(Equal(uint16(200)))
+			Expect(cmd.WriteCount()).To(Equal(3))
+			Expect(cmd.WriteReg(1)).To(Equal(uint16(0x203)))
+			Expect(cmd.Tx()).To(Equal(tx))
+			Expect(cmd.String()).To(Equal(tx + "\n[]"))
+			Expect(cap(*cmd.RxBytes())).To(Equal(13))
+		})
+
+		It("could change write block", func() {
+			cmd.SetWriteAddr(7)
+			cmd.SetReg(0, 42)
+			cmd.ModifyBytes(func(b []byte) {
+				b[5] = 0
+			})
+			Expect(cmd.WriteBytes()).To(Equal([]byte{0, 42, 2, 3, 0xFF, 0}))
+			Expect(cmd.Tx()).To(Equal(
+				"0000 3<-RWR 100:2 7:3[   42   515 65280]"))
+			Expect(func() {
+				cmd.SetReg(3, 0)
+			}).Should(PanicWith("invalid i: 3"))
+			Expect(func() {
+				cmd.WriteReg(-1)
+			}).Should(PanicWith("invalid i: -1"))
+		})
+	})
+
+	Context("Valid Rx", func() {
+		const rx = "0000 3->RWR 2[    7 48879]"
+		SetRx([]byte{0, 0, 0, 0, 0, 7, dev, 23, 4, 0, 7, 0xBE, 0xEF})
+
+		It("has Regs", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.Err()).To(Succeed())
+			Expect(cmd.Reg(0)).To(Equal(uint16(7)))
+			Expect(cmd.Reg(1)).To(Equal(uint16(0xBEEF)))
+			Expect(cmd.Bytes()).To(Equal([]byte{0, 7, 0xBE, 0xEF}))
+			Expect(func() {
+				cmd.Reg(2)
+			}).Should(PanicWith("invalid i: 2"))
+			Expect(cmd.Rx()).To(Equal(rx))
+			Expect(cmd.String()).To(Equal(tx + "\n" + rx))
+		})
+	})
+
+	Context("Short Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 5, dev, 23, 2, 0, 7})
+
+		It("is not Valid Rx", func() {
+			Expect(cmd.IsValidRx()).To(BeFalse())
+			Expect(cmd.String()).To(Equal(tx +
+				"\n[00 00 00 00 00 05 03 17 02 00 07]"))
+		})
+	})
+
+	Context("Err Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 3, dev, 0x97, 3})
+
+		It("has Err", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.Err()).To(Equal(IllegalDataValue))
+			Expect(cmd.Rx()).To(Equal("0000 3->RWR Illegal Data Value"))
+		})
+	})
+
+	Context("many regs", func() {
+		BeforeEach(func() {
+			v := make([]uint16, 12)
+			for i := range v {
+				v[i] = uint16(i)
+			}
+			cmd = NewReadWriteRegsCmd(dev, 0, 1, 0, v)
+		})
+
+		It("has lines of Tx", func() {
+			Expect(cmd.Tx()).To(Equal("0000 3<-RWR 0:1 0:12[\n" +
+				"     0     1     2     3     4 :" +
+				"     5     6     7     8     9\n" +
+				"    10    11\n" +
+				"]"))
+		})
+	})
+})
+
+var _ = Describe("ReadFIFOCmd", func() {
+	var cmd *ReadFIFOCmd
+	SetRx := func(b []byte) {
+		BeforeEach(func() {
+			rx := cmd.RxBytes()
+			*rx = (*rx)[:len(b)]
+			copy(*rx, b)
+		})
+	}
+
+	It("could not broadcast", func() {
+		Expect(func() {
+			NewReadFIFOCmd(0, 1)
+		}).Should(PanicWith("could not broadcast ReadFIFOCmd"))
+	})
+
+	const dev = 3
+	const tx = "0000 3<-RFQ 1246"
+	BeforeEach(func() {
+		cmd = NewReadFIFOCmd(dev, 0x04DE)
+	})
+
+	Context("New", func() {
+		It("has Tx", func() {
+			Expect(cmd.TxBytes()).To(Equal(
+				[]byte{0, 0, 0, 0, 0, 4, dev, 24, 0x04, 0xDE}))
+			Expect(cmd.Addr()).To(Equal(uint16(0x04DE)))
+			Expect(cmd.Tx()).To(Equal(tx))
+			Expect(cmd.String()).To(Equal(tx + "\n[]"))
+			Expect(cap(*cmd.RxBytes())).To(Equal(74))
+		})
+
+		It("could change Addr", func() {
+			cmd.SetAddr(7)
+			Expect(cmd.Tx()).To(Equal("0000 3<-RFQ 7"))
+		})
+	})
+
+	Context("Valid Rx", func() {
+		const rx = "0000 3->RFQ 2[  440   284]"
+		SetRx([]byte{0, 0, 0, 0, 0, 10, dev, 24, 0, 6, 0, 2,
+			0x01, 0xB8, 0x01, 0x1C})
+
+		It("has Regs", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.Err()).To(Succeed())
+			Expect(cmd.Count()).To(Equal(2))
+			Expect(cmd.Reg(0)).To(Equal(uint16(440)))
+			Expect(cmd.Reg(1)).To(Equal(uint16(284)))
+			Expect(cmd.Bytes()).To(Equal([]byte{0x01, 0xB8, 0x01, 0x1C}))
+			Expect(func() {
+				cmd.Reg(2)
+			}).Should(PanicWith("invalid i: 2"))
+			Expect(cmd.Rx()).To(Equal(rx))
+			Expect(cmd.String()).To(Equal(tx + "\n" + rx))
+		})
+	})
+
+	Context("Empty Rx", func() {
+		const rx = "0000 3->RFQ 0[]"
+		SetRx([]byte{0, 0, 0, 0, 0, 6, dev, 24, 0, 2, 0, 0})
+
+		It("has no Regs", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.Count()).To(BeZero())
+			Expect(cmd.Rx()).To(Equal(rx))
+		})
+	})
+
+	Context("Bad count Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 10, dev, 24, 0, 6, 0, 1,
+			0x01, 0xB8, 0x01, 0x1C})
+
+		It("is not Valid Rx", func() {
+			Expect(cmd.IsValidRx()).To(BeFalse())
+			Expect(cmd.String()).To(Equal(tx +
+				"\n[00 00 00 00 00 0A 03 18 00 06 00 01 01 B8 01 1C]"))
+		})
+	})
+
+	Context("Too many Rx", func() {
+		BeforeEach(func() {
+			rx := make([]byte, 12+32*2)
+			rx[5] = byte(len(rx) - 6)
+			rx[6] = dev
+			rx[7] = 24
+			rx[9] = 2 + 32*2
+			rx[11] = 32
+			*cmd.RxBytes() = rx
+		})
+
+		It("is not Valid Rx", func() {
+			Expect(cmd.IsValidRx()).To(BeFalse())
+		})
+	})
+
+	Context("Err Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 3, dev, 0x98, 3})
+
+		It("has Err", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.Err()).To(Equal(IllegalDataValue))
+			Expect(cmd.Rx()).To(Equal("0000 3->RFQ Illegal Data Value"))
+		})
+	})
+})
+
+var _ = Describe("ReadFileRecordCmd", func() {
+	var cmd *ReadFileRecordCmd
+	SetRx := func(b []byte) {
+		BeforeEach(func() {
+			rx := cmd.RxBytes()
+			*rx = (*rx)[:len(b)]
+			copy(*rx, b)
+		})
+	}
+
+	It("could not broadcast", func() {
+		Expect(func() {
+			NewReadFileRecordCmd(0, FileRecord{File: 1, Count: 1})
+		}).Should(PanicWith("could not broadcast ReadFileRecordCmd"))
+	})
+
+	It("checks records", func() {
+		Expect(func() {
+			NewReadFileRecordCmd(1)
+		}).Should(PanicWith("empty records"))
+		Expect(func() {
+			NewReadFileRecordCmd(1, FileRecord{Count: 1})
+		}).Should(PanicWith("invalid file: 0"))
+		Expect(func() {
+			NewReadFileRecordCmd(1,
+				FileRecord{File: 1, Record: 10000, Count: 1})
+		}).Should(PanicWith("invalid record: 10000"))
+		Expect(func() {
+			NewReadFileRecordCmd(1, FileRecord{File: 1})
+		}).Should(PanicWith("zero count"))
+		Expect(func() {
+			NewReadFileRecordCmd(1, FileRecord{File: 1, Count: 122})
+		}).Should(PanicWith("count too many: 122"))
+		Expect(func() {
+			NewReadFileRecordCmd(1,
+				FileRecord{File: 1, Count: 60},
+				FileRecord{File: 2, Count: 61})
+		}).Should(PanicWith("count too many: 121"))
+		Expect(func() {
+			NewReadFileRecordCmd(1, make([]FileRecord, 36)...)
+		}).Should(PanicWith("records too many: 36"))
+		Expect(NewReadFileRecordCmd(1, FileRecord{File: 1, Count: 121})).
+			NotTo(BeNil())
+	})
+
+	const dev = 3
+	const tx = "0000 3<-RFR 4:1:2 3:9:1"
+	BeforeEach(func() {
+		cmd = NewReadFileRecordCmd(dev,
+			FileRecord{File: 4, Record: 1, Count: 2},
+			FileRecord{File: 3, Record: 9, Count: 1})
+	})
+
+	Context("New", func() {
+		It("has Tx", func() {
+			Expect(cmd.TxBytes()).To(Equal([]byte{
+				0, 0, 0, 0, 0, 17, dev, 20, 14,
+				6, 0, 4, 0, 1, 0, 2,
+				6, 0, 3, 0, 9, 0, 1,
+			}))
+			Expect(cmd.Tx()).To(Equal(tx))
+			Expect(cmd.String()).To(Equal(tx + "\n[]"))
+			Expect(cmd.RecordLen()).To(Equal(2))
+			Expect(cmd.Record(1)).To(Equal(
+				FileRecord{File: 3, Record: 9, Count: 1}))
+			Expect(cap(*cmd.RxBytes())).To(Equal(9 + 6 + 4))
+			Expect(cmd.Addr()).To(BeZero())
+			Expect(func() {
+				cmd.SetAddr(1)
+			}).Should(PanicWith("ReadFileRecordCmd has no address"))
+			Expect(func() {
+				cmd.Record(2)
+			}).Should(PanicWith("invalid i: 2"))
+		})
+	})
+
+	Context("Valid Rx", func() {
+		const rx = "0000 3->RFR [ 3460  1196] [ 4353]"
+		SetRx([]byte{0, 0, 0, 0, 0, 13, dev, 20, 10,
+			5, 6, 0x0D, 0x84, 0x04, 0xAC,
+			3, 6, 0x11, 0x01})
+
+		It("has Regs", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.Err()).To(Succeed())
+			Expect(cmd.Bytes(0)).To(Equal([]byte{0x0D, 0x84, 0x04, 0xAC}))
+			Expect(cmd.Reg(0, 1)).To(Equal(uint16(0x04AC)))
+			Expect(cmd.Reg(1, 0)).To(Equal(uint16(0x1101)))
+			Expect(func() {
+				cmd.Reg(1, 1)
+			}).Should(PanicWith("invalid j: 1"))
+			Expect(cmd.Rx()).To(Equal(rx))
+			Expect(cmd.String()).To(Equal(tx + "\n" + rx))
+		})
+	})
+
+	Context("Short sub-response Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 13, dev, 20, 10,
+			3, 6, 0x0D, 0x84,
+			5, 6, 0x04, 0xAC, 0x11, 0x01})
+
+		It("is not Valid Rx", func() {
+			Expect(cmd.IsValidRx()).To(BeFalse())
+			Expect(cmd.String()).To(Equal(tx + "\n[00 00 00 00 00 0D 03 14 0A" +
+				" 03 06 0D 84 05 06 04 AC 11 01]"))
+		})
+	})
+
+	Context("Bad reference type Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 13, dev, 20, 10,
+			5, 7, 0x0D, 0x84, 0x04, 0xAC,
+			3, 6, 0x11, 0x01})
+
+		It("is not Valid Rx", func() {
+			Expect(cmd.IsValidRx()).To(BeFalse())
+		})
+	})
+
+	Context("Err Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 3, dev, 0x94, 2})
+
+		It("has Err", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.Err()).To(Equal(IllegalDataAddress))
+			Expect(cmd.Rx()).To(Equal("0000 3->RFR Illegal Data Address"))
+		})
+	})
+})
+
+var _ = Describe("WriteFileRecordCmd", func() {
+	var cmd *WriteFileRecordCmd
+	SetRx := func(b []byte) {
+		BeforeEach(func() {
+			rx := cmd.RxBytes()
+			*rx = (*rx)[:len(b)]
+			copy(*rx, b)
+		})
+	}
+
+	It("checks records", func() {
+		Expect(func() {
+			NewWriteFileRecordCmd(1)
+		}).Should(PanicWith("empty records"))
+		Expect(func() {
+			NewWriteFileRecordCmd(1, FileRecord{Values: []uint16{1}})
+		}).Should(PanicWith("invalid file: 0"))
+		Expect(func() {
+			NewWriteFileRecordCmd(1, FileRecord{File: 1})
+		}).Should(PanicWith("empty values"))
+		Expect(func() {
+			NewWriteFileRecordCmd(1, FileRecord{
+				File: 1, Values: make([]uint16, 120),
+			})
+		}).Should(PanicWith("values too many: 120"))
+		Expect(NewWriteFileRecordCmd(1, FileRecord{
+			File: 1, Values: make([]uint16, 119),
+		})).NotTo(BeNil())
+	})
+
+	const dev = 3
+	const tx = "0000 3<-WFR 4:7:3[ 1711  1214  4109]"
+	var bytes []byte
+	BeforeEach(func() {
+		cmd = NewWriteFileRecordCmd(dev, FileRecord{
+			File: 4, Record: 7, Values: []uint16{0x06AF, 0x04BE, 0x100D},
+		})
+		bytes = []byte{
+			0, 0, 0, 0, 0, 16, dev, 21, 13,
+			6, 0, 4, 0, 7, 0, 3, 0x06, 0xAF, 0x04, 0xBE, 0x10, 0x0D,
+		}
+	})
+
+	Context("New", func() {
+		It("has Tx", func() {
+			Expect(cmd.TxBytes()).To(Equal(bytes))
+			Expect(cmd.Tx()).To(Equal(tx))
+			Expect(cmd.String()).To(Equal(tx + "\n[]"))
+			Expect(cmd.RecordLen()).To(Equal(1))
+			Expect(cmd.Record(0)).To(Equal(FileRecord{
+				File: 4, Record: 7, Count: 3,
+				Values: []uint16{0x06AF, 0x04BE, 0x100D},
+			}))
+			Expect(cmd.Reg(0, 2)).To(Equal(uint16(0x100D)))
+			Expect(cmd.Addr()).To(BeZero())
+			Expect(func() {
+				cmd.SetAddr(1)
+			}).Should(PanicWith("WriteFileRecordCmd has no address"))
+			Expect(func() {
+				cmd.Record(1)
+			}).Should(PanicWith("invalid i: 1"))
+			Expect(func() {
+				cmd.Reg(0, 3)
+			}).Should(PanicWith("invalid j: 3"))
+		})
+
+		It("could change Reg", func() {
+			cmd.SetReg(0, 1, 1)
+			Expect(cmd.Bytes(0)).To(Equal([]byte{0x06, 0xAF, 0, 1, 0x10, 0x0D}))
+		})
+
+		It("could broadcast", func() {
+			cmd.SetDevAddr(0)
+			Expect(cap(*cmd.RxBytes())).To(BeZero())
+			Expect(cmd.String()).To(Equal(
+				"0000 0<-WFR 4:7:3[ 1711  1214  4109]"))
+			cmd.SetDevAddr(1)
+			Expect(cap(*cmd.RxBytes())).To(Equal(len(bytes)))
+		})
+	})
+
+	Context("many records", func() {
+		BeforeEach(func() {
+			cmd = NewWriteFileRecordCmd(dev,
+				FileRecord{File: 1, Record: 2, Values: []uint16{3}},
+				FileRecord{File: 4, Record: 5, Values: []uint16{6, 7}})
+		})
+
+		It("has records", func() {
+			Expect(cmd.RecordLen()).To(Equal(2))
+			Expect(cmd.Record(1).Values).To(Equal([]uint16{6, 7}))
+			Expect(cmd.Bytes(1)).To(Equal([]byte{0, 6, 0, 7}))
+			Expect(cmd.Tx()).To(Equal(
+				"0000 3<-WFR 1:2:1[    3] 4:5:2[    6     7]"))
+		})
+	})
+
+	Context("Valid Rx", func() {
+		const rx = "0000 3->WFR 4:7:3"
+		BeforeEach(func() {
+			rx := cmd.RxBytes()
+			*rx = append((*rx)[:0], bytes...)
+		})
+
+		It("is echo", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.Err()).To(Succeed())
+			Expect(cmd.Rx()).To(Equal(rx))
+			Expect(cmd.String()).To(Equal(tx + "\n" + rx))
+		})
+	})
+
+	Context("Different Rx", func() {
+		BeforeEach(func() {
+			rx := cmd.RxBytes()
+			*rx = append((*rx)[:0], bytes...)
+			(*rx)[len(bytes)-1] = 0
+		})
+
+		It("is not Valid Rx", func() {
+			Expect(cmd.IsValidRx()).To(BeFalse())
+		})
+	})
+
+	Context("Err Rx", func() {
+		SetRx([]byte{0, 0, 0, 0, 0, 3, dev, 0x95, 4})
+
+		It("has Err", func() {
+			Expect(cmd.IsValidRx()).To(BeTrue())
+			Expect(cmd.Err()).To(Equal(SlaveDeviceFail))
+			Expect(cmd.Rx()).To(Equal("0000 3->WFR Slave Device Failure"))
+		})
+	})
+})
+
+var _ = Describe("RawCmd", func() {
+	var cmd *RawCmd
+	SetRx := func(b []byte) {
+		BeforeEach(func() {
+			rx := cmd.RxBytes()
+			*rx = (*rx)[:len(b)]
+			copy(*rx, b)
+		})
+	}
+
+	It("has valid function code", func() {
+		Expect(func() {
+			NewRawCmd(1, 0, nil)
+		}).Should(PanicWith("invalid function code: 0"))
+		Expect(func() {
+			NewRawCmd(1, 0x80, nil)
+		}).Should(PanicWith("invalid function code: 128"))
+		Expect(func() {
+			NewRawCmd(1, 65, make([]byte, 253))
+		}).Should(PanicWith("data too many: 253"))
+	})
+
+	Context("broadcast", func() {
+		const tx = "0000 0<-RAW 100[01 02]"
+		BeforeEach(func() {
+			cmd = NewRawCmd(0, 100, []byte{1, 2})
+		})
+
+		It("has Tx", func() {
+			Expect(cmd.TxBytes()).To(Equal(
+				[]byte{0, 0, 0, 0, 0, 4, 0, 100, 1, 2}))
+			Expect(cmd.Tx()).To(Equal(tx))
+			Expect(cmd.String()).To(Equal(tx))
+			Expect(cap(*cmd.RxBytes())).To(BeZero())
+		})
+
+		It("could change Dev Addr", func() {
+			cmd.SetRxLen(3)
+			Expect(cap(*cmd.RxBytes())).To(BeZero())
+			cmd.SetDevAddr(1)
+			Expect(cap(*cmd.RxBytes())).To(Equal(11))
+			cmd.SetDevAddr(0)
+			Expect(cap(*cmd.RxBytes())).To(BeZero())
+		})
+	})
+
+	Context("non broadcast", func() {
+		const dev = 3
+		const tx = "0000 3<-RAW 65[]"
+		BeforeEach(func() {
+			cmd = NewRawCmd(dev, 65, nil)
+		})
+
+		It("has Tx", func() {
+			Expect(cmd.TxBytes()).To(Equal([]byte{0, 0, 0, 0, 0, 2, dev, 65}))
+			Expect(cmd.FC()).To(Equal(byte(65)))
+			Expect(cmd.Data()).To(BeEmpty())
+			Expect(cmd.RxLen()).To(Equal(-1))
+			Expect(cmd.Tx()).To(Equal(tx))
+			Expect(cmd.String()).To(Equal(tx + "\n[]"))
+			Expect(cap(*cmd.RxBytes())).To(Equal(260))
+			Expect(cmd.Addr()).To(BeZero())
+			Expect(func() {
+				cmd.SetAddr(1)
+			}).Should(PanicWith("RawCmd has no address"))
+			Expect(func() {
+				cmd.SetRxLen(253)
+			}).Should(PanicWith("invalid rx length: 253"))
+		})
+
+		Context("Valid Rx", func() {
+			const rx = "0000 3->RAW 65[0A 0B 0C]"
+			SetRx([]byte{0, 0, 0, 0, 0, 5, dev, 65, 10, 11, 12})
+
+			It("has data", func() {
+				Expect(cmd.IsValidRx()).To(BeTrue())
+				Expect(cmd.Err()).To(Succeed())
+				Expect(cmd.RxData()).To(Equal([]byte{10, 11, 12}))
+				Expect(cmd.Rx()).To(Equal(rx))
+				Expect(cmd.String()).To(Equal(tx + "\n" + rx))
+			})
+
+			It("checks rx length", func() {
+				cmd.SetRxLen(3)
+				Expect(cmd.RxLen()).To(Equal(3))
+				Expect(cap(*cmd.RxBytes())).To(Equal(11))
+				rx := cmd.RxBytes()
+				*rx = append(*rx, 0, 0, 0, 0, 0, 5, dev, 65, 10, 11, 12)
+				Expect(cmd.IsValidRx()).To(BeTrue())
+				*rx = (*rx)[:10]
+				(*rx)[5] = 4
+				Expect(cmd.IsValidRx()).To(BeFalse())
+				cmd.SetRxLen(-5)
+				Expect(cmd.RxLen()).To(Equal(-1))
+			})
+
+			It("has room for exception", func() {
+				cmd.SetRxLen(0)
+				Expect(cap(*cmd.RxBytes())).To(Equal(9))
+			})
+
+			It("uses validator", func() {
+				var got []byte
+				cmd.SetValidator(func(data []byte) bool {
+					got = data
+					return data[0] == 11
+				})
+				Expect(cmd.IsValidRx()).To(BeFalse())
+				Expect(got).To(Equal([]byte{10, 11, 12}))
+				Expect(cmd.String()).To(Equal(tx +
+					"\n[00 00 00 00 00 05 03 41 0A 0B 0C]"))
+				cmd.SetValidator(nil)
+				Expect(cmd.IsValidRx()).To(BeTrue())
+			})
+		})
+
+		Context("Other function Rx", func() {
+			SetRx([]byte{0, 0, 0, 0, 0, 3, dev, 66, 10})
+
+			It("is not Valid Rx", func() {
+				Expect(cmd.IsValidRx()).To(BeFalse())
+			})
+		})
+
+		Context("Err Rx", func() {
+			SetRx([]byte{0, 0, 0, 0, 0, 3, dev, 0xC1, 1})
+
+			It("has Err", func() {
+				Expect(cmd.IsValidRx()).To(BeTrue())
+				Expect(cmd.Err()).To(Equal(IllegalFunction))
+				Expect(cmd.Rx()).To(Equal("0000 3->RAW Illegal Function"))
+			})
+		})
+
+		It("gets exception of no data cmd", func() {
+			ResetClock()
+			NewLog()
+			srv := &Server{Handler: &DataStore{}}
+			defer srv.Close()
+			con := &Controller{Dialer: &PipeDialer{Server: srv}}
+			defer con.Close()
+			cmd.SetRxLen(0)
+			Expect(con.Send(cmd)).To(MatchError(IllegalFunction))
+		})
+	})
+})
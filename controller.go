@@ -1,7 +1,11 @@
 package modbus
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"net"
 	"time"
 
 	"github.com/bangzek/clock"
@@ -9,6 +13,8 @@ import (
 
 var (
 	ctime = clock.New()
+
+	aLongTimeAgo = time.Unix(1, 0)
 )
 
 type Conn interface {
@@ -21,14 +27,43 @@ type ConnDialer interface {
 	Dial(bool) (Conn, time.Duration, time.Duration, uint16, error)
 }
 
+// ContextDialer is ConnDialer that could be cancelled, Controller use it
+// instead of Dial when available.
+type ContextDialer interface {
+	ConnDialer
+	DialContext(
+		context.Context, bool,
+	) (Conn, time.Duration, time.Duration, uint16, error)
+}
+
+// Framing puts Cmd on the wire other than MBAP. Cmd always keeps its tx and rx
+// in MBAP layout, so Framing translates both ways.
+type Framing interface {
+	// WriteFrame writes MBAP tx to conn in this framing.
+	WriteFrame(conn Conn, tx []byte) error
+	// ReadFrame reads the response of tx from conn into rx in MBAP layout.
+	// cap(*rx) is the expected length of the response, or 9 when shorter to
+	// hold an exception.
+	ReadFrame(conn Conn, tx []byte, rx *[]byte) error
+}
+
 type Controller struct {
 	Dialer ConnDialer
+	// Window is the max outstanding transactions of SendAll, less than 2
+	// means SendAll just Send one by one.
+	Window int
+	// Framing is nil for MBAP, or UDPFraming on datagram conn. Other than
+	// MBAP can't be pipelined.
+	Framing Framing
 
 	conn    Conn
 	timeout time.Duration
 	wait    time.Duration
 	txId    uint16
 	repeat  bool
+	hdr     [mbapLen]byte
+	junk    [maxADULen]byte
+	udp     UDPFraming
 }
 
 func (c *Controller) Close() {
@@ -39,61 +74,444 @@ func (c *Controller) Close() {
 }
 
 func (c *Controller) Send(cmd Cmd) error {
+	return c.SendContext(context.Background(), cmd)
+}
+
+// SendContext is Send that stops when ctx is done, the ctx deadline is used
+// when it's sooner than the connection timeout.
+func (c *Controller) SendContext(ctx context.Context, cmd Cmd) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := c.dial(ctx); err != nil {
+		return err
+	}
+	defer c.watch(ctx)()
+
+	err := c.send(ctx, cmd)
+	return c.ctxErr(ctx, timeoutErr(err))
+}
+
+func (c *Controller) send(ctx context.Context, cmd Cmd) error {
+	if err := c.write(ctx, cmd); err != nil {
+		c.Close()
+		return err
+	}
+
+	if ctx.Done() == nil {
+		time.Sleep(c.wait)
+	} else {
+		t := time.NewTimer(c.wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			c.Close()
+			return ctx.Err()
+		}
+	}
+
+	if cap(*cmd.RxBytes()) == 0 {
+		return nil
+	}
+
+	err := c.read(ctx, cmd)
+	for i := 0; err != nil && i < c.retries() && isTimeout(err); i++ {
+		debugLog("retry %d: % X", i+1, cmd.TxBytes())
+		if err = c.resend(ctx, cmd); err == nil {
+			err = c.read(ctx, cmd)
+		}
+	}
+	if err != nil {
+		c.Close()
+		return err
+	}
+	return c.checkRx(cmd)
+}
+
+// SendAll pipelines cmds with up to Window outstanding transactions and
+// matches the responses by TxId, so the device may answer in any order.
+// There is no wait between transactions. It returns the error of each cmd.
+// When the connection broke, every unfinished cmd get the error that broke it.
+func (c *Controller) SendAll(cmds ...Cmd) []error {
+	return c.SendAllContext(context.Background(), cmds...)
+}
+
+// SendAllContext is SendAll that stops when ctx is done.
+func (c *Controller) SendAllContext(ctx context.Context, cmds ...Cmd) []error {
+	errs := make([]error, len(cmds))
+	each := func() []error {
+		for i, cmd := range cmds {
+			errs[i] = c.SendContext(ctx, cmd)
+		}
+		return errs
+	}
+	if c.Window < 2 || c.Framing != nil {
+		return each()
+	}
+
+	fail := func(err error, pending []int, next int) []error {
+		c.Close()
+		err = c.ctxErr(ctx, timeoutErr(err))
+		for _, i := range pending {
+			errs[i] = err
+		}
+		for i := next; i < len(cmds); i++ {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fail(err, nil, 0)
+	}
+	if err := c.dial(ctx); err != nil {
+		return fail(err, nil, 0)
+	}
+	if c.framing() != nil {
+		return each()
+	}
+	defer c.watch(ctx)()
+
+	pending := make([]int, 0, c.Window)
+	for next := 0; next < len(cmds) || len(pending) > 0; {
+		for next < len(cmds) && len(pending) < c.Window {
+			if err := c.write(ctx, cmds[next]); err != nil {
+				return fail(err, pending, next)
+			}
+			if cap(*cmds[next].RxBytes()) > 0 {
+				pending = append(pending, next)
+			}
+			next++
+		}
+		if len(pending) == 0 {
+			continue
+		}
+
+		if err := c.conn.SetReadDeadline(c.deadline(ctx)); err != nil {
+			return fail(err, pending, next)
+		}
+		if err := c.readHeader(); err != nil {
+			return fail(err, pending, next)
+		}
+		j := -1
+		for k, i := range pending {
+			if cmds[i].TxId() == mbapId(c.hdr[:]) {
+				j = k
+				break
+			}
+		}
+		if j < 0 {
+			debugLog("stale: % X", c.hdr[:])
+			if err := c.discard(); err != nil {
+				return fail(err, pending, next)
+			}
+			continue
+		}
+
+		i := pending[j]
+		pending = append(pending[:j], pending[j+1:]...)
+		if err := c.readBody(cmds[i]); err != nil {
+			errs[i] = c.ctxErr(ctx, timeoutErr(err))
+			return fail(err, pending, next)
+		}
+		if err := c.checkRx(cmds[i]); err != nil {
+			errs[i] = err
+			if errors.As(err, new(BadRxErr)) {
+				return fail(err, pending, next)
+			}
+		}
+	}
+	return errs
+}
+
+// ReadDeviceID reads every object of code from devAddr, sending
+// ReadDeviceIDCmd again while the device has more. It returns the object
+// values by object ID.
+func (c *Controller) ReadDeviceID(
+	devAddr byte, code DevIDCode,
+) (map[byte]string, error) {
+	return c.ReadDeviceIDContext(context.Background(), devAddr, code)
+}
+
+// ReadDeviceIDContext is ReadDeviceID that stops when ctx is done.
+func (c *Controller) ReadDeviceIDContext(
+	ctx context.Context, devAddr byte, code DevIDCode,
+) (map[byte]string, error) {
+	cmd := NewReadDeviceIDCmd(devAddr, code, 0)
+	objs := make(map[byte]string)
+	for {
+		if err := c.SendContext(ctx, cmd); err != nil {
+			return nil, err
+		}
+		for i := 0; i < cmd.ObjectLen(); i++ {
+			id, v := cmd.Object(i)
+			objs[id] = string(v)
+		}
+		if !cmd.MoreFollows() {
+			return objs, nil
+		}
+		// next must go forward or we never stop
+		if cmd.NextObjID() <= cmd.ObjID() {
+			rx := cmd.RxBytes()
+			return nil, BadRxErr(append([]byte(nil), *rx...))
+		}
+		cmd.SetObjID(cmd.NextObjID())
+	}
+}
+
+// ReadFile reads the first n records of file from devAddr, as many
+// ReadFileRecordCmd as needed are sent with SendAll.
+func (c *Controller) ReadFile(
+	devAddr byte, file uint16, n int,
+) ([]uint16, error) {
+	return c.ReadFileContext(context.Background(), devAddr, file, n)
+}
+
+// ReadFileContext is ReadFile that stops when ctx is done.
+func (c *Controller) ReadFileContext(
+	ctx context.Context, devAddr byte, file uint16, n int,
+) ([]uint16, error) {
+	if n <= 0 || n > maxFileRecord+1 {
+		panic(fmt.Sprintf("invalid records: %d", n))
+	}
+
+	cmds := make([]Cmd, 0, (n+maxFileRegs-1)/maxFileRegs)
+	for i := 0; i < n; i += maxFileRegs {
+		cmds = append(cmds, NewReadFileRecordCmd(devAddr, FileRecord{
+			File:   file,
+			Record: uint16(i),
+			Count:  uint16(min(n-i, maxFileRegs)),
+		}))
+	}
+	for _, err := range c.SendAllContext(ctx, cmds...) {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	regs := make([]uint16, 0, n)
+	for _, cmd := range cmds {
+		b := cmd.(*ReadFileRecordCmd).Bytes(0)
+		for i := 0; i < len(b); i += 2 {
+			regs = append(regs, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+	}
+	return regs, nil
+}
+
+func (c *Controller) dial(ctx context.Context) error {
 	if c.conn == nil {
 		var err error
-		c.conn, c.timeout, c.wait, c.txId, err = c.Dialer.Dial(c.repeat)
+		if d, ok := c.Dialer.(ContextDialer); ok {
+			c.conn, c.timeout, c.wait, c.txId, err = d.DialContext(
+				ctx, c.repeat)
+		} else {
+			c.conn, c.timeout, c.wait, c.txId, err = c.Dialer.Dial(c.repeat)
+		}
 		if err != nil {
 			c.repeat = true
 			return err
 		}
 		c.repeat = false
 	}
+	return nil
+}
 
-	if err := c.conn.SetWriteDeadline(ctime.Now().Add(c.timeout)); err != nil {
-		c.Close()
+// watch makes blocked I/O return once ctx is done, call the returned func
+// to stop watching. When ctx is done too late to stop, it waits for the
+// deadlines to be set so they can't spoil the next I/O.
+func (c *Controller) watch(ctx context.Context) func() {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	conn := c.conn
+	done := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetWriteDeadline(aLongTimeAgo)
+		conn.SetReadDeadline(aLongTimeAgo)
+		close(done)
+	})
+	return func() {
+		if !stop() {
+			<-done
+		}
+	}
+}
+
+// ctxErr returns ctx error instead of the I/O error it caused.
+func (c *Controller) ctxErr(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	var me ModbusErr
+	if errors.As(err, &me) {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (c *Controller) deadline(ctx context.Context) time.Time {
+	t := ctime.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(t) {
+		return d
+	}
+	return t
+}
+
+func (c *Controller) write(ctx context.Context, cmd Cmd) error {
+	if err := c.conn.SetWriteDeadline(c.deadline(ctx)); err != nil {
 		return err
 	}
 
 	cmd.SetTxId(c.txId)
 	c.txId++
-	tx := cmd.TxBytes()
-	debugLog("tx: % X", tx)
+	debugLog("tx: % X", cmd.TxBytes())
 	debugLog("TX: %s", cmd.Tx())
+	return c.writeTx(cmd)
+}
+
+// resend writes cmd again with the same TxId.
+func (c *Controller) resend(ctx context.Context, cmd Cmd) error {
+	if err := c.conn.SetWriteDeadline(c.deadline(ctx)); err != nil {
+		return err
+	}
+	return c.writeTx(cmd)
+}
+
+func (c *Controller) writeTx(cmd Cmd) error {
+	tx := cmd.TxBytes()
+	if f := c.framing(); f != nil {
+		return f.WriteFrame(c.conn, tx)
+	}
 	if n, err := c.conn.Write(tx); err != nil {
-		c.Close()
 		return err
 	} else if n != len(tx) {
-		c.Close()
 		return io.ErrShortWrite
 	}
+	return nil
+}
 
-	time.Sleep(c.wait)
-
-	rx := cmd.RxBytes()
-	if cap(*rx) == 0 {
-		return nil
-	}
-	*rx = (*rx)[:cap(*rx)]
-
-	if err := c.conn.SetReadDeadline(ctime.Now().Add(c.timeout)); err != nil {
-		c.Close()
+func (c *Controller) read(ctx context.Context, cmd Cmd) error {
+	if err := c.conn.SetReadDeadline(c.deadline(ctx)); err != nil {
 		return err
 	}
-	if n, err := c.conn.Read(*rx); err != nil {
-		c.Close()
+	// ctx could be done before the deadline above replaced watch's deadline
+	if err := ctx.Err(); err != nil {
 		return err
-	} else if n == 0 {
-		c.Close()
-		return io.ErrNoProgress
-	} else {
-		*rx = (*rx)[:n]
 	}
+	if f := c.framing(); f != nil {
+		return f.ReadFrame(c.conn, cmd.TxBytes(), cmd.RxBytes())
+	}
+	return c.readFrame(cmd)
+}
+
+// framing returns Framing, a datagram conn can't be read as MBAP stream so
+// it defaults to UDPFraming with UDP_RETRIES.
+func (c *Controller) framing() Framing {
+	if c.Framing != nil {
+		return c.Framing
+	}
+	if _, ok := c.conn.(net.PacketConn); ok {
+		c.udp.Retries = UDP_RETRIES
+		return &c.udp
+	}
+	return nil
+}
+
+// retries returns how many times a timed out cmd is sent again, only Framing
+// that matches response by TxId like UDPFraming could do it.
+func (c *Controller) retries() int {
+	if r, ok := c.framing().(interface{ retries() int }); ok {
+		return r.retries()
+	}
+	return 0
+}
+
+// checkRx closes the connection on invalid rx since it's out of sync.
+func (c *Controller) checkRx(cmd Cmd) error {
+	rx := cmd.RxBytes()
 	debugLog("rx: % X", *rx)
 	if cmd.IsValidRx() {
 		debugLog("RX: %s", cmd.Rx())
 	} else {
 		c.Close()
-		return BadRxErr(*rx)
+		return rxErr(cmd.TxBytes(), *rx)
 	}
 	return cmd.Err()
 }
+
+// readFrame reads MBAP header then exactly the length it announced into
+// cmd.RxBytes. Stale frame of other transaction is discarded.
+func (c *Controller) readFrame(cmd Cmd) error {
+	rx := cmd.RxBytes()
+	*rx = (*rx)[:0]
+	for {
+		if err := c.readHeader(); err != nil {
+			return err
+		}
+		if mbapId(c.hdr[:]) == cmd.TxId() {
+			return c.readBody(cmd)
+		}
+		debugLog("stale: % X", c.hdr[:])
+		if err := c.discard(); err != nil {
+			return err
+		}
+	}
+}
+
+// readHeader reads MBAP header into c.hdr, garbage before it is skipped byte
+// by byte.
+func (c *Controller) readHeader() error {
+	h := c.hdr[:]
+	if err := readFull(c.conn, h); err != nil {
+		return err
+	}
+
+	for skip := 0; ; {
+		l := mbapLength(h)
+		if mbapProto(h) == 0 && l >= 2 && l <= maxADULen-6 {
+			if skip > 0 {
+				debugLog("skipped %d bytes", skip)
+			}
+			return nil
+		}
+		if skip++; skip > maxADULen {
+			return BadRxErr(append([]byte(nil), h...))
+		}
+		copy(h, h[1:])
+		if err := readFull(c.conn, h[mbapLen-1:]); err != nil {
+			return err
+		}
+	}
+}
+
+// readBody reads the rest of frame announced by c.hdr into cmd.RxBytes.
+func (c *Controller) readBody(cmd Cmd) error {
+	rx := cmd.RxBytes()
+	b := (*rx)[:cap(*rx)]
+	*rx = b[:0]
+	copy(b, c.hdr[:])
+	n := 6 + int(mbapLength(c.hdr[:]))
+	if n > len(b) {
+		// keep what fit for BadRxErr, discard the rest
+		if err := readFull(c.conn, b[mbapLen:]); err != nil {
+			return err
+		}
+		if err := readFull(c.conn, c.junk[:n-len(b)]); err != nil {
+			return err
+		}
+		*rx = b
+		return nil
+	}
+	if err := readFull(c.conn, b[mbapLen:n]); err != nil {
+		return err
+	}
+	*rx = b[:n]
+	return nil
+}
+
+// discard reads the rest of frame announced by c.hdr.
+func (c *Controller) discard() error {
+	return readFull(c.conn, c.junk[:mbapLength(c.hdr[:])-1])
+}
@@ -1,9 +1,13 @@
 package modbus_test
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"net"
+	"os"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -49,6 +53,7 @@ var _ = Describe("Controller", func() {
 				"WRITE [04 D2 00 00 00 06 03 01 00 02 00 01]",
 				"SRD 2024-03-02T10:11:16.001Z",
 				"READ",
+				"READ",
 				"CLOSE",
 			}))
 			mc.Stop()
@@ -105,6 +110,7 @@ var _ = Describe("Controller", func() {
 				"WRITE [09 29 00 00 00 06 03 02 00 02 00 01]",
 				"SRD 2024-03-02T10:11:16.001Z",
 				"READ",
+				"READ",
 				"SWD 2024-03-02T10:11:17.001Z",
 				"WRITE [09 2A 00 00 00 06 00 05 01 02 FF 00]",
 			}))
@@ -385,6 +391,7 @@ var _ = Describe("Controller", func() {
 				"WRITE [DD D5 00 00 00 06 03 01 00 02 00 01]",
 				"SRD 2024-03-02T10:11:16.001Z",
 				"READ",
+				"READ",
 				"CLOSE",
 			}))
 			mc.Stop()
@@ -404,6 +411,227 @@ var _ = Describe("Controller", func() {
 		})
 	})
 
+	Context("split rx", func() {
+		It("reads until complete", func() {
+			var tid uint16 = 1234
+			t := time.Date(2024, time.March, 2, 10, 11, 12, 0, time.UTC)
+			mc := new(clock.Mock)
+			mc.NowScripts = []time.Duration{0, time.Second}
+			SetClock(mc)
+			mc.Start(t)
+			cmd := NewReadHRegsCmd(3, 2, 2)
+			conn := &MockConn{
+				Writes: []WriteScript{
+					{12, nil},
+				},
+				Reads: []ReadScript{
+					{[]byte{4, 210, 0}, nil},
+					{[]byte{0, 0, 7, 3, 3}, nil},
+					{[]byte{4, 0, 1, 0, 2}, nil},
+				},
+			}
+			dialer := &MockDialer{
+				Dials: []DialScript{
+					{conn, TIMEOUT, WAIT, tid, nil},
+				},
+			}
+			con := &Controller{
+				Dialer: dialer,
+			}
+			log := NewLog()
+			Expect(con.Send(cmd)).To(Succeed())
+			Expect(cmd.Reg(1)).To(Equal(uint16(2)))
+			Expect(conn.Calls).To(Equal([]string{
+				"SWD 2024-03-02T10:11:15.001Z",
+				"WRITE [04 D2 00 00 00 06 03 03 00 02 00 02]",
+				"SRD 2024-03-02T10:11:16.001Z",
+				"READ",
+				"READ",
+				"READ",
+				"READ",
+			}))
+			mc.Stop()
+			Expect(log.Msgs).To(Equal([]string{
+				"D:tx: 04 D2 00 00 00 06 03 03 00 02 00 02",
+				"D:TX: 04D2 3<-RHR 2:2",
+				"D:rx: 04 D2 00 00 00 07 03 03 04 00 01 00 02",
+				"D:RX: 04D2 3->RHR 2[    1     2]",
+			}))
+		})
+	})
+
+	Context("garbage before rx", func() {
+		It("skips the garbage", func() {
+			var tid uint16 = 1234
+			t := time.Date(2024, time.March, 2, 10, 11, 12, 0, time.UTC)
+			mc := new(clock.Mock)
+			mc.NowScripts = []time.Duration{0, time.Second}
+			SetClock(mc)
+			mc.Start(t)
+			cmd := NewReadCoilsCmd(3, 2, 1)
+			conn := &MockConn{
+				Writes: []WriteScript{
+					{12, nil},
+				},
+				Reads: []ReadScript{
+					{[]byte{0xFF, 4, 210, 0, 0, 0, 4, 3, 1, 1, 0b1}, nil},
+				},
+			}
+			dialer := &MockDialer{
+				Dials: []DialScript{
+					{conn, TIMEOUT, WAIT, tid, nil},
+				},
+			}
+			con := &Controller{
+				Dialer: dialer,
+			}
+			log := NewLog()
+			Expect(con.Send(cmd)).To(Succeed())
+			Expect(conn.Calls).To(Equal([]string{
+				"SWD 2024-03-02T10:11:15.001Z",
+				"WRITE [04 D2 00 00 00 06 03 01 00 02 00 01]",
+				"SRD 2024-03-02T10:11:16.001Z",
+				"READ",
+				"READ",
+				"READ",
+			}))
+			mc.Stop()
+			Expect(log.Msgs).To(Equal([]string{
+				"D:tx: 04 D2 00 00 00 06 03 01 00 02 00 01",
+				"D:TX: 04D2 3<-RC  2:1",
+				"D:skipped 1 bytes",
+				"D:rx: 04 D2 00 00 00 04 03 01 01 01",
+				"D:RX: 04D2 3->RC  1[1]",
+			}))
+		})
+
+		It("gives up after too many garbage", func() {
+			var tid uint16 = 1234
+			t := time.Date(2024, time.March, 2, 10, 11, 12, 0, time.UTC)
+			mc := new(clock.Mock)
+			mc.NowScripts = []time.Duration{0, time.Second}
+			SetClock(mc)
+			mc.Start(t)
+			cmd := NewReadCoilsCmd(3, 2, 1)
+			conn := &MockConn{
+				Writes: []WriteScript{
+					{12, nil},
+				},
+				Reads: []ReadScript{
+					{bytes.Repeat([]byte{0xFF}, 300), nil},
+				},
+			}
+			dialer := &MockDialer{
+				Dials: []DialScript{
+					{conn, TIMEOUT, WAIT, tid, nil},
+				},
+			}
+			con := &Controller{
+				Dialer: dialer,
+			}
+			NewLog()
+			Expect(con.Send(cmd)).To(MatchError(
+				"invalid response: [FF FF FF FF FF FF FF]"))
+			Expect(conn.Calls[len(conn.Calls)-1]).To(Equal("CLOSE"))
+			mc.Stop()
+		})
+	})
+
+	Context("stale rx", func() {
+		It("discards it", func() {
+			var tid uint16 = 1234
+			t := time.Date(2024, time.March, 2, 10, 11, 12, 0, time.UTC)
+			mc := new(clock.Mock)
+			mc.NowScripts = []time.Duration{0, time.Second}
+			SetClock(mc)
+			mc.Start(t)
+			cmd := NewReadCoilsCmd(3, 2, 1)
+			conn := &MockConn{
+				Writes: []WriteScript{
+					{12, nil},
+				},
+				Reads: []ReadScript{
+					{[]byte{4, 209, 0, 0, 0, 4, 3, 1, 1, 0b0}, nil},
+					{[]byte{4, 210, 0, 0, 0, 4, 3, 1, 1, 0b1}, nil},
+				},
+			}
+			dialer := &MockDialer{
+				Dials: []DialScript{
+					{conn, TIMEOUT, WAIT, tid, nil},
+				},
+			}
+			con := &Controller{
+				Dialer: dialer,
+			}
+			log := NewLog()
+			Expect(con.Send(cmd)).To(Succeed())
+			Expect(cmd.Coil(0)).To(BeTrue())
+			Expect(conn.Calls).To(Equal([]string{
+				"SWD 2024-03-02T10:11:15.001Z",
+				"WRITE [04 D2 00 00 00 06 03 01 00 02 00 01]",
+				"SRD 2024-03-02T10:11:16.001Z",
+				"READ",
+				"READ",
+				"READ",
+				"READ",
+			}))
+			mc.Stop()
+			Expect(log.Msgs).To(Equal([]string{
+				"D:tx: 04 D2 00 00 00 06 03 01 00 02 00 01",
+				"D:TX: 04D2 3<-RC  2:1",
+				"D:stale: 04 D1 00 00 00 04 03",
+				"D:rx: 04 D2 00 00 00 04 03 01 01 01",
+				"D:RX: 04D2 3->RC  1[1]",
+			}))
+		})
+	})
+
+	Context("oversize rx", func() {
+		It("returns BadRxErr", func() {
+			var tid uint16 = 1234
+			t := time.Date(2024, time.March, 2, 10, 11, 12, 0, time.UTC)
+			mc := new(clock.Mock)
+			mc.NowScripts = []time.Duration{0, time.Second}
+			SetClock(mc)
+			mc.Start(t)
+			cmd := NewReadCoilsCmd(3, 2, 1)
+			conn := &MockConn{
+				Writes: []WriteScript{
+					{12, nil},
+				},
+				Reads: []ReadScript{
+					{[]byte{4, 210, 0, 0, 0, 6, 3, 1, 1, 0b1, 0xFF, 0xFF}, nil},
+				},
+			}
+			dialer := &MockDialer{
+				Dials: []DialScript{
+					{conn, TIMEOUT, WAIT, tid, nil},
+				},
+			}
+			con := &Controller{
+				Dialer: dialer,
+			}
+			log := NewLog()
+			Expect(con.Send(cmd)).To(MatchError(
+				"invalid response: [04 D2 00 00 00 06 03 01 01 01]"))
+			Expect(conn.Calls).To(Equal([]string{
+				"SWD 2024-03-02T10:11:15.001Z",
+				"WRITE [04 D2 00 00 00 06 03 01 00 02 00 01]",
+				"SRD 2024-03-02T10:11:16.001Z",
+				"READ",
+				"READ",
+				"READ",
+				"CLOSE",
+			}))
+			mc.Stop()
+			Expect(log.Msgs).To(Equal([]string{
+				"D:tx: 04 D2 00 00 00 06 03 01 00 02 00 01",
+				"D:TX: 04D2 3<-RC  2:1",
+				"D:rx: 04 D2 00 00 00 06 03 01 01 01",
+			}))
+		})
+	})
+
 	Context("bad io.Reader", func() {
 		It("returns ErrNoProgress", func() {
 			var tid uint16 = 56789
@@ -451,6 +679,539 @@ var _ = Describe("Controller", func() {
 			}))
 		})
 	})
+
+	Context("pipelined", func() {
+		var t time.Time
+		var mc *clock.Mock
+		var cmd1, cmd4 *ReadCoilsCmd
+		var cmd2 *ReadHRegsCmd
+		var cmd3 *WriteCoilCmd
+		var cmds []Cmd
+		BeforeEach(func() {
+			t = time.Date(2024, time.March, 2, 10, 11, 12, 0, time.UTC)
+			mc = new(clock.Mock)
+			mc.NowScripts = []time.Duration{0, 0, 0, 0, 0, 0, 0}
+			SetClock(mc)
+			mc.Start(t)
+			cmd1 = NewReadCoilsCmd(3, 2, 1)
+			cmd2 = NewReadHRegsCmd(3, 0, 1)
+			cmd3 = NewWriteCoilCmd(0, 258, true)
+			cmd4 = NewReadCoilsCmd(3, 5, 1)
+			cmds = []Cmd{cmd1, cmd2, cmd3, cmd4}
+		})
+		AfterEach(func() {
+			mc.Stop()
+		})
+
+		It("matches rx in any order", func() {
+			conn := &MockConn{
+				Writes: []WriteScript{
+					{12, nil},
+					{12, nil},
+					{12, nil},
+					{12, nil},
+				},
+				Reads: []ReadScript{
+					{[]byte{0, 101, 0, 0, 0, 5, 3, 3, 2, 0, 7}, nil},
+					{[]byte{0, 100, 0, 0, 0, 4, 3, 1, 1, 0b1}, nil},
+					{[]byte{0, 103, 0, 0, 0, 3, 3, 0x81, 2}, nil},
+				},
+			}
+			dialer := &MockDialer{
+				Dials: []DialScript{
+					{conn, TIMEOUT, WAIT, 100, nil},
+				},
+			}
+			con := &Controller{
+				Dialer: dialer,
+				Window: 2,
+			}
+			NewLog()
+			Expect(con.SendAll(cmds...)).To(Equal([]error{
+				nil, nil, nil, IllegalDataAddress,
+			}))
+			Expect(cmd1.Coil(0)).To(BeTrue())
+			Expect(cmd2.Reg(0)).To(Equal(uint16(7)))
+			Expect(dialer.Calls).To(Equal([]bool{false}))
+			Expect(conn.Calls).To(Equal([]string{
+				"SWD 2024-03-02T10:11:15.001Z",
+				"WRITE [00 64 00 00 00 06 03 01 00 02 00 01]",
+				"SWD 2024-03-02T10:11:15.002Z",
+				"WRITE [00 65 00 00 00 06 03 03 00 00 00 01]",
+				"SRD 2024-03-02T10:11:15.003Z",
+				"READ",
+				"READ",
+				"SWD 2024-03-02T10:11:15.004Z",
+				"WRITE [00 66 00 00 00 06 00 05 01 02 FF 00]",
+				"SWD 2024-03-02T10:11:15.005Z",
+				"WRITE [00 67 00 00 00 06 03 01 00 05 00 01]",
+				"SRD 2024-03-02T10:11:15.006Z",
+				"READ",
+				"READ",
+				"SRD 2024-03-02T10:11:15.007Z",
+				"READ",
+				"READ",
+			}))
+		})
+
+		It("discards stale rx", func() {
+			conn := &MockConn{
+				Writes: []WriteScript{
+					{12, nil},
+					{12, nil},
+					{12, nil},
+					{12, nil},
+				},
+				Reads: []ReadScript{
+					{[]byte{0, 99, 0, 0, 0, 4, 3, 1, 1, 0b1}, nil},
+					{[]byte{0, 100, 0, 0, 0, 4, 3, 1, 1, 0b1}, nil},
+					{[]byte{0, 101, 0, 0, 0, 5, 3, 3, 2, 0, 7}, nil},
+					{[]byte{0, 103, 0, 0, 0, 4, 3, 1, 1, 0b0}, nil},
+				},
+			}
+			dialer := &MockDialer{
+				Dials: []DialScript{
+					{conn, TIMEOUT, WAIT, 100, nil},
+				},
+			}
+			con := &Controller{
+				Dialer: dialer,
+				Window: 4,
+			}
+			log := NewLog()
+			Expect(con.SendAll(cmds...)).To(Equal([]error{nil, nil, nil, nil}))
+			Expect(cmd4.Coil(0)).To(BeFalse())
+			Expect(log.Msgs).To(ContainElement(
+				"D:stale: 00 63 00 00 00 04 03"))
+		})
+
+		It("fails unfinished cmds on broken connection", func() {
+			err := errors.New("broken")
+			conn := &MockConn{
+				Writes: []WriteScript{
+					{12, nil},
+					{12, nil},
+					{12, nil},
+					{12, nil},
+				},
+				Reads: []ReadScript{
+					{[]byte{0, 101, 0, 0, 0, 5, 3, 3, 2, 0, 7}, nil},
+					{nil, err},
+				},
+			}
+			dialer := &MockDialer{
+				Dials: []DialScript{
+					{conn, TIMEOUT, WAIT, 100, nil},
+				},
+			}
+			con := &Controller{
+				Dialer: dialer,
+				Window: 2,
+			}
+			NewLog()
+			Expect(con.SendAll(cmds...)).To(Equal([]error{
+				err, nil, nil, err,
+			}))
+			Expect(conn.Calls[len(conn.Calls)-1]).To(Equal("CLOSE"))
+		})
+
+		It("fails every cmd on dial error", func() {
+			err := errors.New("dial")
+			dialer := &MockDialer{
+				Dials: []DialScript{
+					{nil, TIMEOUT, WAIT, 0, err},
+				},
+			}
+			con := &Controller{
+				Dialer: dialer,
+				Window: 2,
+			}
+			Expect(con.SendAll(cmd1, cmd2)).To(Equal([]error{err, err}))
+		})
+
+		It("sends one by one without Window", func() {
+			conn := &MockConn{
+				Writes: []WriteScript{
+					{12, nil},
+					{12, nil},
+				},
+				Reads: []ReadScript{
+					{[]byte{0, 100, 0, 0, 0, 4, 3, 1, 1, 0b1}, nil},
+					{[]byte{0, 101, 0, 0, 0, 5, 3, 3, 2, 0, 7}, nil},
+				},
+			}
+			dialer := &MockDialer{
+				Dials: []DialScript{
+					{conn, TIMEOUT, 0, 100, nil},
+				},
+			}
+			con := &Controller{
+				Dialer: dialer,
+			}
+			NewLog()
+			Expect(con.SendAll(cmd1, cmd2)).To(Equal([]error{nil, nil}))
+			Expect(conn.Calls).To(Equal([]string{
+				"SWD 2024-03-02T10:11:15.001Z",
+				"WRITE [00 64 00 00 00 06 03 01 00 02 00 01]",
+				"SRD 2024-03-02T10:11:15.002Z",
+				"READ",
+				"READ",
+				"SWD 2024-03-02T10:11:15.003Z",
+				"WRITE [00 65 00 00 00 06 03 03 00 00 00 01]",
+				"SRD 2024-03-02T10:11:15.004Z",
+				"READ",
+				"READ",
+			}))
+		})
+	})
+
+	Context("with context", func() {
+		It("doesn't dial when ctx is done", func() {
+			dialer := &MockDialer{}
+			con := &Controller{
+				Dialer: dialer,
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			Expect(con.SendContext(ctx, NewReadCoilsCmd(3, 2, 1))).
+				To(MatchError(context.Canceled))
+			Expect(con.SendAllContext(ctx, NewReadCoilsCmd(3, 2, 1))).
+				To(Equal([]error{context.Canceled}))
+			Expect(dialer.Calls).To(BeEmpty())
+		})
+
+		It("uses sooner ctx deadline", func() {
+			t := time.Now().Truncate(time.Second)
+			d := t.Add(time.Hour)
+			mc := new(clock.Mock)
+			mc.NowScripts = []time.Duration{0, time.Hour}
+			SetClock(mc)
+			mc.Start(t)
+			cmd := NewReadCoilsCmd(3, 2, 1)
+			conn := &MockConn{
+				Writes: []WriteScript{
+					{12, nil},
+				},
+				Reads: []ReadScript{
+					{[]byte{0, 1, 0, 0, 0, 4, 3, 1, 1, 0b1}, nil},
+				},
+			}
+			dialer := &MockDialer{
+				Dials: []DialScript{
+					{conn, 30 * time.Minute, 0, 1, nil},
+				},
+			}
+			con := &Controller{
+				Dialer: dialer,
+			}
+			ctx, cancel := context.WithDeadline(context.Background(), d)
+			defer cancel()
+			NewLog()
+			Expect(con.SendContext(ctx, cmd)).To(Succeed())
+			Expect(conn.Calls).To(Equal([]string{
+				"SWD " + t.Add(dsn+30*time.Minute).Format(time.RFC3339Nano),
+				"WRITE [00 01 00 00 00 06 03 01 00 02 00 01]",
+				"SRD " + d.Format(time.RFC3339Nano),
+				"READ",
+				"READ",
+			}))
+			mc.Stop()
+		})
+
+		It("waits for late cancel before returning", func() {
+			ResetClock()
+			ctx, cancel := context.WithCancel(context.Background())
+			conn := &CancelConn{MockConn: MockConn{
+				Writes: []WriteScript{
+					{12, nil},
+				},
+				Reads: []ReadScript{
+					{[]byte{0, 1, 0, 0, 0, 4, 3, 1, 1, 0b1}, nil},
+				},
+			}, Cancel: cancel}
+			dialer := &MockDialer{
+				Dials: []DialScript{
+					{conn, TIMEOUT, 0, 1, nil},
+				},
+			}
+			con := &Controller{
+				Dialer: dialer,
+			}
+			NewLog()
+			con.SendContext(ctx, NewReadCoilsCmd(3, 2, 1))
+			ago := time.Unix(1, 0).Format(time.RFC3339Nano)
+			Expect(conn.Calls[len(conn.Calls)-2:]).To(Equal([]string{
+				"SWD " + ago,
+				"SRD " + ago,
+			}))
+		})
+
+		It("stops blocked read on cancel", func() {
+			ResetClock()
+			c1, c2 := net.Pipe()
+			defer c2.Close()
+			dialer := &MockDialer{
+				Dials: []DialScript{
+					{c1, TIMEOUT, 0, 1, nil},
+				},
+			}
+			con := &Controller{
+				Dialer: dialer,
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				io.ReadFull(c2, make([]byte, 12))
+				cancel()
+			}()
+			NewLog()
+			Expect(con.SendContext(ctx, NewReadCoilsCmd(3, 2, 1))).
+				To(MatchError(context.Canceled))
+		})
+
+		It("keeps ModbusErr", func() {
+			ResetClock()
+			con := &Controller{
+				Dialer: &MockDialer{
+					Dials: []DialScript{
+						{&MockConn{
+							Writes: []WriteScript{
+								{12, nil},
+							},
+							Reads: []ReadScript{
+								{[]byte{0, 1, 0, 0, 0, 3, 3, 0x81, 2}, nil},
+							},
+						}, TIMEOUT, 0, 1, nil},
+					},
+				},
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			NewLog()
+			Expect(con.SendContext(ctx, NewReadCoilsCmd(3, 2, 1))).
+				To(MatchError(IllegalDataAddress))
+		})
+
+		It("stops Dialer", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			d := &Dialer{Host: "127.0.0.1", Port: 1}
+			NewLog()
+			_, _, _, _, err := d.DialContext(ctx, false)
+			Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+			var de DialErr
+			Expect(errors.As(err, &de)).To(BeTrue())
+			Expect(de.Addr).To(Equal("127.0.0.1:1"))
+		})
+	})
+
+	Context("typed errors", func() {
+		send := func(reads ...ReadScript) error {
+			ResetClock()
+			con := &Controller{
+				Dialer: &MockDialer{
+					Dials: []DialScript{
+						{&MockConn{
+							Writes: []WriteScript{
+								{12, nil},
+							},
+							Reads: reads,
+						}, TIMEOUT, 0, 1, nil},
+					},
+				},
+			}
+			NewLog()
+			return con.Send(NewReadCoilsCmd(3, 2, 1))
+		}
+
+		It("returns ErrUnitMismatch", func() {
+			err := send(ReadScript{[]byte{0, 1, 0, 0, 0, 4, 4, 1, 1, 1}, nil})
+			Expect(err).To(MatchError(ErrUnitMismatch))
+			Expect(errors.As(err, new(BadRxErr))).To(BeTrue())
+			Expect(IsRetryable(err)).To(BeTrue())
+		})
+
+		It("returns ErrShortFrame", func() {
+			err := send(ReadScript{[]byte{0, 1, 0, 0, 0, 2, 3, 1}, nil})
+			Expect(err).To(MatchError(ErrShortFrame))
+		})
+
+		It("returns ErrTimeout", func() {
+			err := send(ReadScript{nil, os.ErrDeadlineExceeded})
+			Expect(err).To(MatchError(ErrTimeout))
+			Expect(err).To(MatchError(os.ErrDeadlineExceeded))
+			Expect(IsRetryable(err)).To(BeTrue())
+		})
+
+		It("returns ModbusErr", func() {
+			err := send(ReadScript{[]byte{0, 1, 0, 0, 0, 3, 3, 0x81, 6}, nil})
+			Expect(err).To(Equal(SlaveDeviceBusy))
+			Expect(IsRetryable(err)).To(BeTrue())
+		})
+	})
+
+	Context("read device ID", func() {
+		It("follows continuation", func() {
+			ResetClock()
+			conn := &MockConn{
+				Writes: []WriteScript{
+					{11, nil},
+					{11, nil},
+				},
+				Reads: []ReadScript{
+					{[]byte{0, 1, 0, 0, 0, 16, 3, 43, 14, 1, 0x81, 0xFF, 2, 2,
+						0, 3, 'A', 'C', 'M',
+						1, 1, 'X'}, nil},
+					{[]byte{0, 2, 0, 0, 0, 13, 3, 43, 14, 1, 0x81, 0, 0, 1,
+						2, 3, 'v', '1', '0'}, nil},
+				},
+			}
+			con := &Controller{
+				Dialer: &MockDialer{
+					Dials: []DialScript{
+						{conn, TIMEOUT, 0, 1, nil},
+					},
+				},
+			}
+			NewLog()
+			Expect(con.ReadDeviceID(3, BasicDevID)).To(Equal(map[byte]string{
+				VendorName:         "ACM",
+				ProductCode:        "X",
+				MajorMinorRevision: "v10",
+			}))
+			Expect(conn.Calls).To(ContainElements(
+				"WRITE [00 01 00 00 00 05 03 2B 0E 01 00]",
+				"WRITE [00 02 00 00 00 05 03 2B 0E 01 02]",
+			))
+		})
+
+		It("stops when next doesn't go forward", func() {
+			ResetClock()
+			rx := []byte{0, 1, 0, 0, 0, 8, 3, 43, 14, 3, 0x83, 0xFF, 0, 0}
+			con := &Controller{
+				Dialer: &MockDialer{
+					Dials: []DialScript{
+						{&MockConn{
+							Writes: []WriteScript{
+								{11, nil},
+							},
+							Reads: []ReadScript{
+								{rx, nil},
+							},
+						}, TIMEOUT, 0, 1, nil},
+					},
+				},
+			}
+			NewLog()
+			objs, err := con.ReadDeviceID(3, ExtendedDevID)
+			Expect(objs).To(BeNil())
+			Expect(err).To(MatchError(BadRxErr(rx)))
+		})
+
+		It("returns ModbusErr", func() {
+			ResetClock()
+			con := &Controller{
+				Dialer: &MockDialer{
+					Dials: []DialScript{
+						{&MockConn{
+							Writes: []WriteScript{
+								{11, nil},
+							},
+							Reads: []ReadScript{
+								{[]byte{0, 1, 0, 0, 0, 3, 3, 0xAB, 1}, nil},
+							},
+						}, TIMEOUT, 0, 1, nil},
+					},
+				},
+			}
+			NewLog()
+			_, err := con.ReadDeviceID(3, RegularDevID)
+			Expect(err).To(MatchError(IllegalFunction))
+		})
+	})
+
+	Context("read file", func() {
+		rx := func(id uint16, n int) []byte {
+			b := make([]byte, 11+n*2)
+			b[0] = byte(id >> 8)
+			b[1] = byte(id)
+			b[5] = byte(len(b) - 6)
+			b[6] = 3
+			b[7] = 20
+			b[8] = byte(len(b) - 9)
+			b[9] = byte(1 + n*2)
+			b[10] = 6
+			for i := 0; i < n; i++ {
+				b[12+i*2] = byte(i)
+			}
+			return b
+		}
+
+		It("reads in chunks", func() {
+			ResetClock()
+			conn := &MockConn{
+				Writes: []WriteScript{
+					{16, nil},
+					{16, nil},
+				},
+				Reads: []ReadScript{
+					{rx(1, 121), nil},
+					{rx(2, 9), nil},
+				},
+			}
+			con := &Controller{
+				Dialer: &MockDialer{
+					Dials: []DialScript{
+						{conn, TIMEOUT, 0, 1, nil},
+					},
+				},
+			}
+			NewLog()
+			regs, err := con.ReadFile(3, 4, 130)
+			Expect(err).To(Succeed())
+			Expect(regs).To(HaveLen(130))
+			Expect(regs[120]).To(Equal(uint16(120)))
+			Expect(regs[121]).To(BeZero())
+			Expect(regs[129]).To(Equal(uint16(8)))
+			Expect(conn.Calls).To(ContainElements(
+				"WRITE [00 01 00 00 00 0A 03 14 07 06 00 04 00 00 00 79]",
+				"WRITE [00 02 00 00 00 0A 03 14 07 06 00 04 00 79 00 09]",
+			))
+		})
+
+		It("returns the error", func() {
+			ResetClock()
+			con := &Controller{
+				Dialer: &MockDialer{
+					Dials: []DialScript{
+						{&MockConn{
+							Writes: []WriteScript{
+								{16, nil},
+								{16, nil},
+							},
+							Reads: []ReadScript{
+								{rx(1, 121), nil},
+								{[]byte{0, 2, 0, 0, 0, 3, 3, 0x94, 2}, nil},
+							},
+						}, TIMEOUT, 0, 1, nil},
+					},
+				},
+			}
+			NewLog()
+			regs, err := con.ReadFile(3, 4, 200)
+			Expect(regs).To(BeNil())
+			Expect(err).To(MatchError(IllegalDataAddress))
+		})
+
+		It("has valid records", func() {
+			con := &Controller{}
+			Expect(func() {
+				con.ReadFile(3, 4, 0)
+			}).Should(PanicWith("invalid records: 0"))
+			Expect(func() {
+				con.ReadFile(3, 4, 10001)
+			}).Should(PanicWith("invalid records: 10001"))
+		})
+	})
 })
 
 type MockDialer struct {
@@ -495,6 +1256,22 @@ type MockConn struct {
 	iWrite     int
 	iRDeadline int
 	iRead      int
+	iByte      int
+}
+
+// CancelConn is MockConn that calls Cancel once all Reads are done, like ctx
+// that is cancelled right after the I/O.
+type CancelConn struct {
+	MockConn
+	Cancel func()
+}
+
+func (m *CancelConn) Read(b []byte) (int, error) {
+	n, err := m.MockConn.Read(b)
+	if m.iRead >= len(m.Reads) {
+		m.Cancel()
+	}
+	return n, err
 }
 
 type WriteScript struct {
@@ -535,21 +1312,20 @@ func (m *MockConn) SetReadDeadline(t time.Time) (err error) {
 	return
 }
 
+// Read works like a stream, ReadScript.Bytes could be read in several Read
+// and ReadScript.Err is returned along with its last bytes.
 func (m *MockConn) Read(b []byte) (n int, err error) {
 	if m.iRead < len(m.Reads) {
 		s := m.Reads[m.iRead]
-		if len(b) < len(s.Bytes) {
-			panic(fmt.Sprintf("Invalid MockConn.ReadScript[%d].Bytes %d>%d",
-				m.iRead, len(s.Bytes), len(b)))
-		}
-		if len(s.Bytes) > 0 {
-			copy(b, s.Bytes)
-			n = len(s.Bytes)
+		n = copy(b, s.Bytes[m.iByte:])
+		m.iByte += n
+		if m.iByte == len(s.Bytes) {
+			err = s.Err
+			m.iRead++
+			m.iByte = 0
 		}
-		err = s.Err
 	}
 	m.Calls = append(m.Calls, "READ")
-	m.iRead++
 	return
 }
 
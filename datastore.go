@@ -0,0 +1,414 @@
+package modbus
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+type Table byte
+
+const (
+	CoilTable Table = iota + 1
+	DInputTable
+	HRegTable
+	IRegTable
+)
+
+func (t Table) String() string {
+	switch t {
+	case CoilTable:
+		return "coil"
+	case DInputTable:
+		return "dinput"
+	case HRegTable:
+		return "hreg"
+	case IRegTable:
+		return "ireg"
+	default:
+		return fmt.Sprintf("table %d", byte(t))
+	}
+}
+
+func (t Table) IsBit() bool {
+	return t == CoilTable || t == DInputTable
+}
+
+// DataStore is a Handler that keeps the four tables of every unit in memory.
+// Only address defined by Unit.Define is accessible, the rest is answered
+// with IllegalDataAddress like a real device.
+type DataStore struct {
+	mu    sync.RWMutex
+	units map[byte]*Unit
+}
+
+// AddUnit returns the Unit of devAddr, creating it when needed.
+func (d *DataStore) AddUnit(devAddr byte) *Unit {
+	if devAddr == 0 {
+		panic("could not add broadcast Unit")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if u, ok := d.units[devAddr]; ok {
+		return u
+	}
+	if d.units == nil {
+		d.units = make(map[byte]*Unit)
+	}
+	u := &Unit{devAddr: devAddr}
+	d.units[devAddr] = u
+	return u
+}
+
+// Unit returns nil when devAddr is never added.
+func (d *DataStore) Unit(devAddr byte) *Unit {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.units[devAddr]
+}
+
+func (d *DataStore) targets(devAddr byte) ([]*Unit, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if devAddr != 0 {
+		if u, ok := d.units[devAddr]; ok {
+			return []*Unit{u}, nil
+		}
+		return nil, IllegalDataAddress
+	}
+
+	a := make([]byte, 0, len(d.units))
+	for x := range d.units {
+		a = append(a, x)
+	}
+	sort.Slice(a, func(i, j int) bool { return a[i] < a[j] })
+	units := make([]*Unit, len(a))
+	for i, x := range a {
+		units[i] = d.units[x]
+	}
+	return units, nil
+}
+
+func (d *DataStore) ReadCoils(
+	devAddr byte, addr uint16, count uint16,
+) ([]bool, error) {
+	return d.readBits(devAddr, CoilTable, addr, count)
+}
+
+func (d *DataStore) ReadDInputs(
+	devAddr byte, addr uint16, count uint16,
+) ([]bool, error) {
+	return d.readBits(devAddr, DInputTable, addr, count)
+}
+
+func (d *DataStore) ReadHRegs(
+	devAddr byte, addr uint16, count uint16,
+) ([]uint16, error) {
+	return d.readRegs(devAddr, HRegTable, addr, count)
+}
+
+func (d *DataStore) ReadIRegs(
+	devAddr byte, addr uint16, count uint16,
+) ([]uint16, error) {
+	return d.readRegs(devAddr, IRegTable, addr, count)
+}
+
+func (d *DataStore) WriteCoil(devAddr byte, addr uint16, val bool) error {
+	return d.WriteCoils(devAddr, addr, []bool{val})
+}
+
+func (d *DataStore) WriteReg(devAddr byte, addr uint16, val uint16) error {
+	return d.WriteRegs(devAddr, addr, []uint16{val})
+}
+
+func (d *DataStore) WriteCoils(
+	devAddr byte, addr uint16, values []bool,
+) error {
+	units, err := d.targets(devAddr)
+	if err != nil {
+		return err
+	}
+	// broadcast still write to the rest of units
+	for _, u := range units {
+		if e := u.writeBits(addr, values); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (d *DataStore) WriteRegs(
+	devAddr byte, addr uint16, values []uint16,
+) error {
+	units, err := d.targets(devAddr)
+	if err != nil {
+		return err
+	}
+	// broadcast still write to the rest of units
+	for _, u := range units {
+		if e := u.writeRegs(addr, values); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (d *DataStore) readBits(
+	devAddr byte, t Table, addr uint16, count uint16,
+) ([]bool, error) {
+	u := d.Unit(devAddr)
+	if u == nil {
+		return nil, IllegalDataAddress
+	}
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	m := u.bits(t)
+	v := make([]bool, count)
+	for i := range v {
+		x, ok := m[addr+uint16(i)]
+		if !ok {
+			return nil, IllegalDataAddress
+		}
+		v[i] = x
+	}
+	return v, nil
+}
+
+func (d *DataStore) readRegs(
+	devAddr byte, t Table, addr uint16, count uint16,
+) ([]uint16, error) {
+	u := d.Unit(devAddr)
+	if u == nil {
+		return nil, IllegalDataAddress
+	}
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	m := u.regs(t)
+	v := make([]uint16, count)
+	for i := range v {
+		x, ok := m[addr+uint16(i)]
+		if !ok {
+			return nil, IllegalDataAddress
+		}
+		v[i] = x
+	}
+	return v, nil
+}
+
+//----------------------------------------------------------------------
+
+type Limit struct {
+	Min uint16
+	Max uint16
+}
+
+// Unit is the tables of one device address inside DataStore.
+type Unit struct {
+	// OnWrite is called after master changed count values of table t
+	// starting at addr. It's called outside the lock so it can access Unit.
+	// Set it before the Unit get served.
+	OnWrite func(t Table, addr uint16, count uint16)
+
+	devAddr byte
+	mu      sync.RWMutex
+	coils   map[uint16]bool
+	dInputs map[uint16]bool
+	hRegs   map[uint16]uint16
+	iRegs   map[uint16]uint16
+	limits  map[uint16]Limit
+}
+
+func (u *Unit) DevAddr() byte {
+	return u.devAddr
+}
+
+// Define makes count addresses of table t starting at addr accessible.
+// Call it several times to make sparse address map.
+func (u *Unit) Define(t Table, addr uint16, count uint16) {
+	if count == 0 {
+		panic("zero count")
+	}
+	if addr+count-1 < addr {
+		panic(fmt.Sprintf("address overflow: %d, %d", addr, count))
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if t.IsBit() {
+		m := u.bits(t)
+		if m == nil {
+			m = make(map[uint16]bool, count)
+			if t == CoilTable {
+				u.coils = m
+			} else {
+				u.dInputs = m
+			}
+		}
+		for i := uint16(0); i < count; i++ {
+			if _, ok := m[addr+i]; !ok {
+				m[addr+i] = false
+			}
+		}
+	} else {
+		m := u.regs(t)
+		if m == nil {
+			m = make(map[uint16]uint16, count)
+			if t == HRegTable {
+				u.hRegs = m
+			} else {
+				u.iRegs = m
+			}
+		}
+		for i := uint16(0); i < count; i++ {
+			if _, ok := m[addr+i]; !ok {
+				m[addr+i] = 0
+			}
+		}
+	}
+}
+
+// SetLimit makes master write outside l to holding register at addr answered
+// with IllegalDataValue.
+func (u *Unit) SetLimit(addr uint16, l Limit) {
+	if l.Min > l.Max {
+		panic(fmt.Sprintf("invalid limit: %d > %d", l.Min, l.Max))
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.limits == nil {
+		u.limits = make(map[uint16]Limit)
+	}
+	u.limits[addr] = l
+}
+
+func (u *Unit) Bit(t Table, addr uint16) (bool, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	v, ok := u.bits(t)[addr]
+	if !ok {
+		return false, IllegalDataAddress
+	}
+	return v, nil
+}
+
+// SetBit changes the value without calling OnWrite.
+func (u *Unit) SetBit(t Table, addr uint16, v bool) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	m := u.bits(t)
+	if _, ok := m[addr]; !ok {
+		return IllegalDataAddress
+	}
+	m[addr] = v
+	return nil
+}
+
+func (u *Unit) Reg(t Table, addr uint16) (uint16, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	v, ok := u.regs(t)[addr]
+	if !ok {
+		return 0, IllegalDataAddress
+	}
+	return v, nil
+}
+
+// SetReg changes the value without calling OnWrite nor checking limit.
+func (u *Unit) SetReg(t Table, addr uint16, v uint16) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	m := u.regs(t)
+	if _, ok := m[addr]; !ok {
+		return IllegalDataAddress
+	}
+	m[addr] = v
+	return nil
+}
+
+func (u *Unit) bits(t Table) map[uint16]bool {
+	switch t {
+	case CoilTable:
+		return u.coils
+	case DInputTable:
+		return u.dInputs
+	default:
+		panic(fmt.Sprintf("invalid bit table: %s", t))
+	}
+}
+
+func (u *Unit) regs(t Table) map[uint16]uint16 {
+	switch t {
+	case HRegTable:
+		return u.hRegs
+	case IRegTable:
+		return u.iRegs
+	default:
+		panic(fmt.Sprintf("invalid register table: %s", t))
+	}
+}
+
+func (u *Unit) writeBits(addr uint16, values []bool) error {
+	if err := func() error {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+
+		for i := range values {
+			if _, ok := u.coils[addr+uint16(i)]; !ok {
+				return IllegalDataAddress
+			}
+		}
+		for i, v := range values {
+			u.coils[addr+uint16(i)] = v
+		}
+		return nil
+	}(); err != nil {
+		return err
+	}
+
+	if u.OnWrite != nil {
+		u.OnWrite(CoilTable, addr, uint16(len(values)))
+	}
+	return nil
+}
+
+func (u *Unit) writeRegs(addr uint16, values []uint16) error {
+	if err := func() error {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+
+		for i := range values {
+			if _, ok := u.hRegs[addr+uint16(i)]; !ok {
+				return IllegalDataAddress
+			}
+		}
+		for i, v := range values {
+			l, ok := u.limits[addr+uint16(i)]
+			if ok && (v < l.Min || v > l.Max) {
+				return IllegalDataValue
+			}
+		}
+		for i, v := range values {
+			u.hRegs[addr+uint16(i)] = v
+		}
+		return nil
+	}(); err != nil {
+		return err
+	}
+
+	if u.OnWrite != nil {
+		u.OnWrite(HRegTable, addr, uint16(len(values)))
+	}
+	return nil
+}
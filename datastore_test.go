@@ -0,0 +1,142 @@
+package modbus_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bangzek/modbus-tcp"
+)
+
+var _ = Describe("DataStore", func() {
+	var ds *DataStore
+	var u1, u2 *Unit
+	var writes []string
+	BeforeEach(func() {
+		ds = &DataStore{}
+		u1 = ds.AddUnit(1)
+		u1.Define(CoilTable, 0, 10)
+		u1.Define(DInputTable, 100, 2)
+		u1.Define(HRegTable, 0, 4)
+		u1.Define(HRegTable, 10, 2)
+		u1.Define(IRegTable, 30000, 3)
+		u2 = ds.AddUnit(2)
+		u2.Define(HRegTable, 0, 4)
+		writes = nil
+		u1.OnWrite = func(t Table, addr uint16, count uint16) {
+			writes = append(writes, fmt.Sprintf("1 %s %d %d", t, addr, count))
+		}
+		u2.OnWrite = func(t Table, addr uint16, count uint16) {
+			writes = append(writes, fmt.Sprintf("2 %s %d %d", t, addr, count))
+		}
+	})
+
+	It("returns the same Unit", func() {
+		Expect(ds.AddUnit(1)).To(BeIdenticalTo(u1))
+		Expect(ds.Unit(2)).To(BeIdenticalTo(u2))
+		Expect(ds.Unit(3)).To(BeNil())
+		Expect(u2.DevAddr()).To(Equal(byte(2)))
+	})
+
+	It("can't add broadcast Unit", func() {
+		Expect(func() {
+			ds.AddUnit(0)
+		}).Should(PanicWith("could not add broadcast Unit"))
+	})
+
+	It("reads defined address", func() {
+		Expect(u1.SetBit(CoilTable, 1, true)).To(Succeed())
+		Expect(u1.SetBit(DInputTable, 101, true)).To(Succeed())
+		Expect(u1.SetReg(HRegTable, 11, 1234)).To(Succeed())
+		Expect(u1.SetReg(IRegTable, 30002, 4321)).To(Succeed())
+
+		Expect(ds.ReadCoils(1, 0, 3)).To(Equal([]bool{false, true, false}))
+		Expect(ds.ReadDInputs(1, 100, 2)).To(Equal([]bool{false, true}))
+		Expect(ds.ReadHRegs(1, 10, 2)).To(Equal([]uint16{0, 1234}))
+		Expect(ds.ReadIRegs(1, 30001, 2)).To(Equal([]uint16{0, 4321}))
+		Expect(writes).To(BeEmpty())
+	})
+
+	It("rejects undefined address", func() {
+		_, err := ds.ReadCoils(1, 8, 3)
+		Expect(err).To(MatchError(IllegalDataAddress))
+		_, err = ds.ReadHRegs(1, 3, 2)
+		Expect(err).To(MatchError(IllegalDataAddress))
+		_, err = ds.ReadIRegs(2, 0, 1)
+		Expect(err).To(MatchError(IllegalDataAddress))
+		_, err = ds.ReadHRegs(3, 0, 1)
+		Expect(err).To(MatchError(IllegalDataAddress))
+		Expect(ds.WriteRegs(1, 2, []uint16{1, 2, 3})).
+			To(MatchError(IllegalDataAddress))
+		Expect(ds.WriteCoil(1, 10, true)).To(MatchError(IllegalDataAddress))
+		Expect(u1.SetReg(HRegTable, 4, 1)).To(MatchError(IllegalDataAddress))
+		_, err = u1.Bit(DInputTable, 0)
+		Expect(err).To(MatchError(IllegalDataAddress))
+
+		Expect(u1.Reg(HRegTable, 2)).To(BeZero())
+		Expect(writes).To(BeEmpty())
+	})
+
+	It("writes and calls OnWrite", func() {
+		Expect(ds.WriteCoil(1, 9, true)).To(Succeed())
+		Expect(ds.WriteCoils(1, 0, []bool{true, true})).To(Succeed())
+		Expect(ds.WriteReg(1, 3, 33)).To(Succeed())
+		Expect(ds.WriteRegs(1, 10, []uint16{7, 8})).To(Succeed())
+
+		Expect(u1.Bit(CoilTable, 9)).To(BeTrue())
+		Expect(u1.Bit(CoilTable, 1)).To(BeTrue())
+		Expect(u1.Reg(HRegTable, 3)).To(Equal(uint16(33)))
+		Expect(u1.Reg(HRegTable, 11)).To(Equal(uint16(8)))
+		Expect(writes).To(Equal([]string{
+			"1 coil 9 1",
+			"1 coil 0 2",
+			"1 hreg 3 1",
+			"1 hreg 10 2",
+		}))
+	})
+
+	It("rejects value outside limit", func() {
+		u1.SetLimit(1, Limit{10, 20})
+		Expect(ds.WriteRegs(1, 0, []uint16{5, 21})).
+			To(MatchError(IllegalDataValue))
+		Expect(ds.WriteReg(1, 1, 9)).To(MatchError(IllegalDataValue))
+		Expect(ds.WriteRegs(1, 0, []uint16{5, 20})).To(Succeed())
+		Expect(ds.ReadHRegs(1, 0, 2)).To(Equal([]uint16{5, 20}))
+		Expect(writes).To(Equal([]string{"1 hreg 0 2"}))
+	})
+
+	It("broadcasts write to every unit", func() {
+		Expect(ds.WriteReg(0, 2, 99)).To(Succeed())
+		Expect(u1.Reg(HRegTable, 2)).To(Equal(uint16(99)))
+		Expect(u2.Reg(HRegTable, 2)).To(Equal(uint16(99)))
+		Expect(writes).To(Equal([]string{"1 hreg 2 1", "2 hreg 2 1"}))
+	})
+
+	It("serves through Server", func() {
+		ResetClock()
+		srv := &Server{Handler: ds}
+		con := &Controller{Dialer: &PipeDialer{Server: srv}}
+		defer srv.Close()
+		defer con.Close()
+
+		Expect(con.Send(NewWriteRegsCmd(1, 0, []uint16{1, 2, 3}))).
+			To(Succeed())
+		cmd := NewReadHRegsCmd(1, 1, 2)
+		Expect(con.Send(cmd)).To(Succeed())
+		Expect(cmd.Reg(0)).To(Equal(uint16(2)))
+		Expect(cmd.Reg(1)).To(Equal(uint16(3)))
+		Expect(con.Send(NewReadHRegsCmd(1, 3, 2))).
+			To(MatchError(IllegalDataAddress))
+		Expect(writes).To(Equal([]string{"1 hreg 0 3"}))
+	})
+
+	It("panics on invalid table", func() {
+		Expect(func() {
+			u1.Reg(CoilTable, 0)
+		}).Should(PanicWith("invalid register table: coil"))
+		Expect(func() {
+			u1.Define(HRegTable, 65535, 2)
+		}).Should(PanicWith("address overflow: 65535, 2"))
+	})
+})
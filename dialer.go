@@ -1,9 +1,10 @@
 package modbus
 
 import (
-	"fmt"
+	"context"
 	"math/rand"
 	"net"
+	"strconv"
 	"time"
 )
 
@@ -35,6 +36,12 @@ type Dialer struct {
 
 func (p *Dialer) Dial(
 	repeat bool,
+) (Conn, time.Duration, time.Duration, uint16, error) {
+	return p.DialContext(context.Background(), repeat)
+}
+
+func (p *Dialer) DialContext(
+	ctx context.Context, repeat bool,
 ) (Conn, time.Duration, time.Duration, uint16, error) {
 	if p.Host == "" {
 		panic("empty Dialer.Host")
@@ -49,28 +56,43 @@ func (p *Dialer) Dial(
 		p.Wait = WAIT
 	}
 
-	a := fmt.Sprintf("%s:%d", p.Host, p.Port)
+	a := net.JoinHostPort(p.Host, strconv.Itoa(p.Port))
 	if repeat {
 		debugLog("Dialing %s", a)
 	} else {
 		log("Dialing %s", a)
 	}
-	conn, err := net.DialTimeout("tcp", a, p.Timeout)
+	d := net.Dialer{Timeout: p.Timeout}
+	conn, err := d.DialContext(ctx, "tcp", a)
 
 	if err != nil {
 		return nil, p.Timeout, p.Wait, 0, DialErr{a, err}
 	}
 	log("%s opened", a)
+	return conn, p.Timeout, p.Wait, randTxId(conn), nil
+}
+
+// randTxId makes different connections unlikely to start with the same TxId.
+func randTxId(conn net.Conn) uint16 {
 	t := time.Now().UnixNano()
-	la := conn.LocalAddr().(*net.TCPAddr)
-	ra := conn.RemoteAddr().(*net.TCPAddr)
+	lp := addrPort(conn.LocalAddr())
+	rp := addrPort(conn.RemoteAddr())
 	var mask int64
 	if t%2 == 0 {
-		mask = int64(la.Port)<<48 | int64(ra.Port)<<32
+		mask = int64(lp)<<48 | int64(rp)<<32
 	} else {
-		mask = int64(ra.Port)<<48 | int64(la.Port)<<32
+		mask = int64(rp)<<48 | int64(lp)<<32
 	}
-	txId := uint16(rand.New(rand.NewSource(t ^ mask)).Int31n(0xFFFF))
+	return uint16(rand.New(rand.NewSource(t ^ mask)).Int31n(0xFFFF))
+}
 
-	return conn, p.Timeout, p.Wait, txId, nil
+func addrPort(a net.Addr) int {
+	switch a := a.(type) {
+	case *net.TCPAddr:
+		return a.Port
+	case *net.UDPAddr:
+		return a.Port
+	default:
+		return 0
+	}
 }
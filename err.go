@@ -1,6 +1,8 @@
 package modbus
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"unsafe"
 )
@@ -12,6 +14,14 @@ const (
 	IllegalDataAddress
 	IllegalDataValue
 	SlaveDeviceFail
+	Acknowledge
+	SlaveDeviceBusy
+)
+
+const (
+	MemoryParityErr        ModbusErr = 8
+	GatewayPathUnavailable ModbusErr = 10
+	GatewayTargetFailed    ModbusErr = 11
 )
 
 func (e ModbusErr) Error() string {
@@ -23,13 +33,34 @@ func (e ModbusErr) Error() string {
 	case IllegalDataValue:
 		return "Illegal Data Value"
 	case SlaveDeviceFail:
-		// len:20
 		return "Slave Device Failure"
+	case Acknowledge:
+		return "Acknowledge"
+	case SlaveDeviceBusy:
+		return "Slave Device Busy"
+	case MemoryParityErr:
+		return "Memory Parity Error"
+	case GatewayPathUnavailable:
+		return "Gateway Path Unavailable"
+	case GatewayTargetFailed:
+		return "Gateway Target Failed To Respond"
 	default:
 		return fmt.Sprintf("Err: %d", e)
 	}
 }
 
+// Retryable is true when sending the same request later may succeed.
+// Acknowledge isn't, the device accepted a long running request so sending it
+// again would start another one, poll for its result instead.
+func (e ModbusErr) Retryable() bool {
+	switch e {
+	case SlaveDeviceBusy, MemoryParityErr, GatewayTargetFailed:
+		return true
+	default:
+		return false
+	}
+}
+
 type BadRxErr []byte
 
 func (e BadRxErr) Error() string {
@@ -44,3 +75,92 @@ func (e BadRxErr) Error() string {
 	b = append(b, ']')
 	return unsafe.String(&b[0], len(b))
 }
+
+var (
+	// ErrTimeout matches TimeoutErr with errors.Is.
+	ErrTimeout = errors.New("timeout")
+	// ErrTxIdMismatch, ErrUnitMismatch and ErrShortFrame match RxErr with
+	// errors.Is.
+	ErrTxIdMismatch = errors.New("transaction ID mismatch")
+	ErrUnitMismatch = errors.New("unit ID mismatch")
+	ErrShortFrame   = errors.New("short frame")
+)
+
+// TimeoutErr is the I/O error when the device doesn't respond in time.
+type TimeoutErr struct {
+	Err error
+}
+
+func (e TimeoutErr) Error() string {
+	return e.Err.Error()
+}
+
+func (e TimeoutErr) Unwrap() error {
+	return e.Err
+}
+
+func (e TimeoutErr) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// timeoutErr wraps err in TimeoutErr when it's a timeout.
+func timeoutErr(err error) error {
+	if isTimeout(err) && !errors.Is(err, ErrTimeout) {
+		return TimeoutErr{err}
+	}
+	return err
+}
+
+// RxErr is BadRxErr that is known why it's invalid, the reason is Err.
+// errors.As still finds the BadRxErr.
+type RxErr struct {
+	Err error
+	Rx  BadRxErr
+}
+
+func (e RxErr) Error() string {
+	return e.Rx.Error() + ": " + e.Err.Error()
+}
+
+func (e RxErr) Unwrap() []error {
+	return []error{e.Err, e.Rx}
+}
+
+// rxErr returns the error of invalid rx as the response of tx.
+func rxErr(tx, rx []byte) error {
+	switch {
+	// nothing after function code
+	case len(rx) < 9:
+		return RxErr{ErrShortFrame, rx}
+	case mbapId(rx) != mbapId(tx):
+		return RxErr{ErrTxIdMismatch, rx}
+	case rx[6] != tx[6]:
+		return RxErr{ErrUnitMismatch, rx}
+	default:
+		return BadRxErr(rx)
+	}
+}
+
+// IsRetryable is true when err may go away by sending the request again:
+// timeout, broken connection, invalid response and ModbusErr.Retryable.
+// Controller dials again on its next Send after the connection broke, but a
+// rejected TLS certificate or role won't change by that.
+func IsRetryable(err error) bool {
+	if err == nil ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		isCertErr(err) {
+		return false
+	}
+	var me ModbusErr
+	if errors.As(err, &me) {
+		return me.Retryable()
+	}
+	return true
+}
+
+// IsPermanent is true when err won't go away by sending the request again,
+// like IllegalDataAddress or cancelled ctx.
+func IsPermanent(err error) bool {
+	return err != nil && !IsRetryable(err)
+}
@@ -1,6 +1,15 @@
 package modbus_test
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
@@ -15,5 +24,77 @@ var _ = DescribeTable("Modbus Err",
 	Entry(nil, IllegalDataAddress, "Illegal Data Address"),
 	Entry(nil, IllegalDataValue, "Illegal Data Value"),
 	Entry(nil, SlaveDeviceFail, "Slave Device Failure"),
-	Entry(nil, ModbusErr(5), "Err: 5"),
+	Entry(nil, Acknowledge, "Acknowledge"),
+	Entry(nil, SlaveDeviceBusy, "Slave Device Busy"),
+	Entry(nil, ModbusErr(7), "Err: 7"),
+	Entry(nil, MemoryParityErr, "Memory Parity Error"),
+	Entry(nil, GatewayPathUnavailable, "Gateway Path Unavailable"),
+	Entry(nil, GatewayTargetFailed, "Gateway Target Failed To Respond"),
+)
+
+var _ = DescribeTable("Modbus Err Retryable",
+	func(e ModbusErr, retry bool) {
+		Expect(e.Retryable()).To(Equal(retry))
+		Expect(IsRetryable(e)).To(Equal(retry))
+		Expect(IsPermanent(e)).To(Equal(!retry))
+	},
+	Entry(nil, IllegalFunction, false),
+	Entry(nil, IllegalDataAddress, false),
+	Entry(nil, IllegalDataValue, false),
+	Entry(nil, SlaveDeviceFail, false),
+	Entry(nil, Acknowledge, false),
+	Entry(nil, SlaveDeviceBusy, true),
+	Entry(nil, MemoryParityErr, true),
+	Entry(nil, GatewayPathUnavailable, false),
+	Entry(nil, GatewayTargetFailed, true),
 )
+
+var _ = Describe("Errors", func() {
+	It("classifies errors", func() {
+		Expect(IsRetryable(nil)).To(BeFalse())
+		Expect(IsPermanent(nil)).To(BeFalse())
+		for _, err := range []error{
+			TimeoutErr{os.ErrDeadlineExceeded},
+			BadRxErr{1, 2},
+			RxErr{ErrUnitMismatch, BadRxErr{1}},
+			io.EOF,
+			DialErr{"127.0.0.1:502", syscall.ECONNREFUSED},
+			fmt.Errorf("wrapped: %w", SlaveDeviceBusy),
+		} {
+			Expect(IsRetryable(err)).To(BeTrue(), "%v", err)
+			Expect(IsPermanent(err)).To(BeFalse(), "%v", err)
+		}
+		for _, err := range []error{
+			context.Canceled,
+			context.DeadlineExceeded,
+			fmt.Errorf("wrapped: %w", IllegalDataAddress),
+			DialErr{"127.0.0.1:802", x509.UnknownAuthorityError{}},
+			DialErr{"127.0.0.1:802", &tls.CertificateVerificationError{
+				Err: x509.CertificateInvalidError{Reason: x509.Expired},
+			}},
+			DialErr{"127.0.0.1:802", RoleErr{"guest", io.EOF}},
+		} {
+			Expect(IsRetryable(err)).To(BeFalse(), "%v", err)
+			Expect(IsPermanent(err)).To(BeTrue(), "%v", err)
+		}
+	})
+
+	It("has TimeoutErr", func() {
+		err := error(TimeoutErr{os.ErrDeadlineExceeded})
+		Expect(err).To(MatchError(ErrTimeout))
+		Expect(err).To(MatchError(os.ErrDeadlineExceeded))
+		Expect(err.Error()).To(Equal(os.ErrDeadlineExceeded.Error()))
+		var te TimeoutErr
+		Expect(errors.As(err, &te)).To(BeTrue())
+	})
+
+	It("has RxErr", func() {
+		err := error(RxErr{ErrShortFrame, BadRxErr{1, 2}})
+		Expect(err).To(MatchError(ErrShortFrame))
+		Expect(err).NotTo(MatchError(ErrUnitMismatch))
+		Expect(err.Error()).To(Equal("invalid response: [01 02]: short frame"))
+		var be BadRxErr
+		Expect(errors.As(err, &be)).To(BeTrue())
+		Expect(be).To(Equal(BadRxErr{1, 2}))
+	})
+})
@@ -0,0 +1,57 @@
+package modbus
+
+import (
+	"errors"
+	"io"
+	"net"
+)
+
+const (
+	// MBAP header: tx id 2, protocol id 2, length 2, unit id 1
+	mbapLen = 7
+	// max ADU over TCP: MBAP header + 253 bytes of PDU
+	maxADULen = mbapLen + 253
+)
+
+// readFull is io.ReadFull that won't spin on a Reader returning 0, nil.
+func readFull(r io.Reader, b []byte) error {
+	for n := 0; n < len(b); {
+		m, err := r.Read(b[n:])
+		n += m
+		if err != nil {
+			if err == io.EOF && n > 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		} else if m == 0 {
+			return io.ErrNoProgress
+		}
+	}
+	return nil
+}
+
+func mbapId(b []byte) uint16 {
+	return (uint16(b[0]) << 8) | uint16(b[1])
+}
+
+func mbapProto(b []byte) uint16 {
+	return (uint16(b[2]) << 8) | uint16(b[3])
+}
+
+func mbapLength(b []byte) uint16 {
+	return (uint16(b[4]) << 8) | uint16(b[5])
+}
+
+// putMBAP puts adu, unit id and PDU of the response of tx, into rx in MBAP
+// layout. It keeps what fit so rx is invalid when adu is too long.
+func putMBAP(rx *[]byte, tx []byte, adu []byte) {
+	r := (*rx)[:cap(*rx)]
+	r[0], r[1], r[2], r[3] = tx[0], tx[1], 0, 0
+	r[4], r[5] = byte(len(adu)>>8), byte(len(adu))
+	*rx = r[:6+copy(r[6:], adu)]
+}
+
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
@@ -3,6 +3,9 @@ package modbus
 type hexs []byte
 
 func (h hexs) Len() int {
+	if len(h) == 0 {
+		return 0
+	}
 	return len(h)*2 + len(h) - 1
 }
 
@@ -32,3 +35,17 @@ func (h hexs) Append2(b []byte) []byte {
 	}
 	return b
 }
+
+// unhex decodes upper or lower case hex digit.
+func unhex(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	default:
+		return 0, false
+	}
+}
@@ -2,12 +2,15 @@ package modbus_test
 
 import (
 	"fmt"
+	"sync"
 
 	. "github.com/bangzek/modbus-tcp"
 )
 
 type Log struct {
 	Msgs []string
+
+	mu sync.Mutex
 }
 
 func NewLog() *Log {
@@ -19,13 +22,19 @@ func NewLog() *Log {
 }
 
 func (l *Log) debugLog(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	l.Msgs = append(l.Msgs, "D:"+fmt.Sprintf(format, v...))
 }
 
 func (l *Log) infoLog(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	l.Msgs = append(l.Msgs, "I:"+fmt.Sprintf(format, v...))
 }
 
 func (l *Log) errorLog(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	l.Msgs = append(l.Msgs, "E:"+fmt.Sprintf(format, v...))
 }
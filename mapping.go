@@ -0,0 +1,492 @@
+package modbus
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Type is the data type of a value in a Table.
+type Type byte
+
+const (
+	BoolType Type = iota + 1
+	Int16Type
+	Uint16Type
+	Int32Type
+	Uint32Type
+	Int64Type
+	Uint64Type
+	Float32Type
+	Float64Type
+	BCD16Type
+	BCD32Type
+	TextType
+)
+
+var typeNames = [...]string{
+	BoolType:    "bool",
+	Int16Type:   "int16",
+	Uint16Type:  "uint16",
+	Int32Type:   "int32",
+	Uint32Type:  "uint32",
+	Int64Type:   "int64",
+	Uint64Type:  "uint64",
+	Float32Type: "float32",
+	Float64Type: "float64",
+	BCD16Type:   "bcd16",
+	BCD32Type:   "bcd32",
+	TextType:    "text",
+}
+
+func (t Type) String() string {
+	if t > 0 && int(t) < len(typeNames) {
+		return typeNames[t]
+	}
+	return fmt.Sprintf("type %d", byte(t))
+}
+
+// Len is the number of registers or bits of the type, 0 for TextType that
+// has its own length.
+func (t Type) Len() int {
+	switch t {
+	case BoolType, Int16Type, Uint16Type, BCD16Type:
+		return 1
+	case Int32Type, Uint32Type, Float32Type, BCD32Type:
+		return 2
+	case Int64Type, Uint64Type, Float64Type:
+		return 4
+	default:
+		return 0
+	}
+}
+
+func (t Type) kind() reflect.Kind {
+	switch t {
+	case BoolType:
+		return reflect.Bool
+	case Int16Type:
+		return reflect.Int16
+	case Uint16Type, BCD16Type:
+		return reflect.Uint16
+	case Int32Type:
+		return reflect.Int32
+	case Uint32Type, BCD32Type:
+		return reflect.Uint32
+	case Int64Type:
+		return reflect.Int64
+	case Uint64Type:
+		return reflect.Uint64
+	case Float32Type:
+		return reflect.Float32
+	case Float64Type:
+		return reflect.Float64
+	case TextType:
+		return reflect.String
+	default:
+		return reflect.Invalid
+	}
+}
+
+func kindType(k reflect.Kind) Type {
+	for t := BoolType; t <= Float64Type; t++ {
+		if t.kind() == k {
+			return t
+		}
+	}
+	return 0
+}
+
+// TagErr is an invalid modbus tag of a struct field.
+type TagErr struct {
+	Field string
+	Tag   string
+	Err   string
+}
+
+func (e TagErr) Error() string {
+	return "invalid modbus tag " + strconv.Quote(e.Tag) + " of " + e.Field +
+		": " + e.Err
+}
+
+// point is a value of typ at span.
+type point struct {
+	span
+	typ   Type
+	order Order
+}
+
+func parseTable(s string) (Table, bool) {
+	for t := CoilTable; t <= IRegTable; t++ {
+		if s == t.String() {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// parseType parses type name, text must have its number of registers like
+// text8.
+func parseType(s string) (Type, int, bool) {
+	if n, ok := strings.CutPrefix(s, "text"); ok {
+		i, err := strconv.Atoi(n)
+		if err != nil || i <= 0 || i > maxReadRegs {
+			return 0, 0, false
+		}
+		return TextType, i, true
+	}
+	for t := BoolType; t < TextType; t++ {
+		if s == t.String() {
+			return t, t.Len(), true
+		}
+	}
+	return 0, 0, false
+}
+
+func parseOrder(s string) (Order, bool) {
+	for o := ABCD; o <= DCBA; o++ {
+		if strings.EqualFold(s, o.String()) {
+			return o, true
+		}
+	}
+	return 0, false
+}
+
+// parseTag parses "table,addr[,type[,order]]", the type is from k when it is
+// empty.
+func parseTag(s string, k reflect.Kind) (point, string) {
+	var p point
+	a := strings.Split(s, ",")
+	if len(a) < 2 || len(a) > 4 {
+		return p, "want table,addr[,type[,order]]"
+	}
+
+	var ok bool
+	if p.table, ok = parseTable(a[0]); !ok {
+		return p, "invalid table: " + a[0]
+	}
+	addr, err := strconv.ParseUint(a[1], 0, 16)
+	if err != nil {
+		return p, "invalid address: " + a[1]
+	}
+	p.addr = uint16(addr)
+
+	if len(a) > 2 && a[2] != "" {
+		if p.typ, p.n, ok = parseType(a[2]); !ok {
+			return p, "invalid type: " + a[2]
+		}
+	} else if p.typ = kindType(k); p.typ == 0 {
+		return p, "no type for " + k.String()
+	} else {
+		p.n = p.typ.Len()
+	}
+	if p.typ.kind() != k {
+		return p, p.typ.String() + " into " + k.String()
+	}
+	if p.table.IsBit() != (p.typ == BoolType) {
+		return p, p.typ.String() + " in " + p.table.String()
+	}
+
+	if len(a) > 3 {
+		if p.order, ok = parseOrder(a[3]); !ok {
+			return p, "invalid order: " + a[3]
+		}
+	}
+	if p.end() > 0x10000 {
+		return p, "address overflow"
+	}
+	return p, ""
+}
+
+type mapField struct {
+	point
+	index int
+	name  string
+	tag   string
+}
+
+// Mapping is the registers and bits of a struct type from the modbus tag of
+// its fields, like `modbus:"hreg,100,float32,cdab"`. The tag is the table
+// (coil, dinput, hreg or ireg), the address, the type and the Order. The type
+// could be left out when the field is bool, sized int, sized uint or float,
+// bcd16 goes into uint16, bcd32 into uint32 and textN of N registers into
+// string. Fields without tag or with "-" are skipped.
+type Mapping struct {
+	typ     reflect.Type
+	fields  []mapField
+	planner Planner
+}
+
+// NewMapping returns the Mapping of v, a struct or pointer to struct. It
+// returns TagErr of the first invalid or overlapping tag.
+func NewMapping(v any) (*Mapping, error) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("modbus mapping of non struct: %T", v)
+	}
+
+	m := &Mapping{typ: t}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		s, ok := f.Tag.Lookup("modbus")
+		if !ok || s == "-" || !f.IsExported() {
+			continue
+		}
+		p, err := parseTag(s, f.Type.Kind())
+		if err != "" {
+			return nil, TagErr{f.Name, s, err}
+		}
+		m.fields = append(m.fields, mapField{
+			point: p,
+			index: i,
+			name:  f.Name,
+			tag:   s,
+		})
+	}
+
+	sort.SliceStable(m.fields, func(i, j int) bool {
+		a, b := m.fields[i], m.fields[j]
+		return a.table < b.table || (a.table == b.table && a.addr < b.addr)
+	})
+	for i := 1; i < len(m.fields); i++ {
+		a, b := &m.fields[i-1], &m.fields[i]
+		if a.table == b.table && int(b.addr) < a.end() {
+			return nil, TagErr{b.name, b.tag, "overlaps " + a.name}
+		}
+	}
+
+	for _, f := range m.fields {
+		m.planner.Add(f.table, f.addr, uint16(f.n))
+	}
+	return m, nil
+}
+
+var mappings sync.Map
+
+// mappingOf returns the cached Mapping of v.
+func mappingOf(v any) (*Mapping, error) {
+	t := reflect.TypeOf(v)
+	if m, ok := mappings.Load(t); ok {
+		return m.(*Mapping), nil
+	}
+	m, err := NewMapping(v)
+	if err != nil {
+		return nil, err
+	}
+	mappings.Store(t, m)
+	return m, nil
+}
+
+// value returns the struct value of v, it must be pointer when addr.
+func (m *Mapping) value(v any, addr bool) reflect.Value {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	} else if addr {
+		panic(fmt.Sprintf("invalid value: %T", v))
+	}
+	if rv.Type() != m.typ {
+		panic(fmt.Sprintf("invalid value: %T", v))
+	}
+	return rv
+}
+
+// ReadPlan returns the Plan to read every field from devAddr, adjacent
+// fields are read by one cmd.
+func (m *Mapping) ReadPlan(devAddr byte) *Plan {
+	return m.planner.Plan(devAddr)
+}
+
+// Decode fills pointer v from Plan of ReadPlan after it is sent.
+func (m *Mapping) Decode(v any, p *Plan) error {
+	rv := m.value(v, true)
+	for _, f := range m.fields {
+		fv := rv.Field(f.index)
+		if f.table.IsBit() {
+			fv.SetBool(p.Bit(f.table, f.addr))
+		} else if !f.decode(p.Regs(f.table, f.addr, uint16(f.n)), 0, fv) {
+			return fmt.Errorf("invalid %s of %s", f.typ, f.name)
+		}
+	}
+	return nil
+}
+
+// decode sets v from register i of r, false on invalid BCD.
+func (p point) decode(r Regs, i int, v reflect.Value) bool {
+	switch p.typ {
+	case Int16Type:
+		v.SetInt(int64(r.Int16(i, p.order)))
+	case Uint16Type:
+		v.SetUint(uint64(r.Uint16(i, p.order)))
+	case Int32Type:
+		v.SetInt(int64(r.Int32(i, p.order)))
+	case Uint32Type:
+		v.SetUint(uint64(r.Uint32(i, p.order)))
+	case Int64Type:
+		v.SetInt(r.Int64(i, p.order))
+	case Uint64Type:
+		v.SetUint(r.Uint64(i, p.order))
+	case Float32Type:
+		v.SetFloat(float64(r.Float32(i, p.order)))
+	case Float64Type:
+		v.SetFloat(r.Float64(i, p.order))
+	case BCD16Type:
+		x, ok := r.BCD16(i, p.order)
+		if !ok {
+			return false
+		}
+		v.SetUint(uint64(x))
+	case BCD32Type:
+		x, ok := r.BCD32(i, p.order)
+		if !ok {
+			return false
+		}
+		v.SetUint(uint64(x))
+	case TextType:
+		v.SetString(r.Text(i, p.n, p.order))
+	}
+	return true
+}
+
+// encode puts v into register i of r, false when v doesn't fit BCD or text.
+func (p point) encode(r Regs, i int, v reflect.Value) bool {
+	switch p.typ {
+	case Int16Type:
+		r.PutInt16(i, int16(v.Int()), p.order)
+	case Uint16Type:
+		r.PutUint16(i, uint16(v.Uint()), p.order)
+	case Int32Type:
+		r.PutInt32(i, int32(v.Int()), p.order)
+	case Uint32Type:
+		r.PutUint32(i, uint32(v.Uint()), p.order)
+	case Int64Type:
+		r.PutInt64(i, v.Int(), p.order)
+	case Uint64Type:
+		r.PutUint64(i, v.Uint(), p.order)
+	case Float32Type:
+		r.PutFloat32(i, float32(v.Float()), p.order)
+	case Float64Type:
+		r.PutFloat64(i, v.Float(), p.order)
+	case BCD16Type:
+		if v.Uint() > 9999 {
+			return false
+		}
+		r.PutBCD16(i, uint16(v.Uint()), p.order)
+	case BCD32Type:
+		if v.Uint() > 99999999 {
+			return false
+		}
+		r.PutBCD32(i, uint32(v.Uint()), p.order)
+	case TextType:
+		if v.Len() > p.n*2 {
+			return false
+		}
+		r.PutText(i, p.n, v.String(), p.order)
+	}
+	return true
+}
+
+// WriteCmds returns the cmds to write every coil and hreg field of v to
+// devAddr, adjacent fields are written by one cmd. Fields of dinput and ireg
+// are read only. It returns error of BCD out of range or text too long.
+func (m *Mapping) WriteCmds(devAddr byte, v any) ([]Cmd, error) {
+	rv := m.value(v, false)
+	var cmds []Cmd
+	for i := 0; i < len(m.fields); {
+		f := m.fields[i]
+		if f.table != CoilTable && f.table != HRegTable {
+			i++
+			continue
+		}
+
+		limit := maxWriteRegs
+		if f.table == CoilTable {
+			limit = maxWriteBits
+		}
+		j, n := i+1, f.n
+		for j < len(m.fields) && m.fields[j].table == f.table &&
+			int(m.fields[j].addr) == int(f.addr)+n &&
+			n+m.fields[j].n <= limit {
+			n += m.fields[j].n
+			j++
+		}
+
+		if f.table == CoilTable {
+			values := make([]bool, n)
+			for _, g := range m.fields[i:j] {
+				values[g.addr-f.addr] = rv.Field(g.index).Bool()
+			}
+			cmds = append(cmds, NewWriteCoilsCmd(devAddr, f.addr, values))
+		} else {
+			cmd := NewWriteRegsCmd(devAddr, f.addr, make([]uint16, n))
+			for _, g := range m.fields[i:j] {
+				fv := rv.Field(g.index)
+				if g.encode(cmd.Regs(), int(g.addr-f.addr), fv) {
+					continue
+				}
+				if g.typ == TextType {
+					return nil, fmt.Errorf("%s too long: %q",
+						g.name, fv.String())
+				}
+				return nil, fmt.Errorf("%s out of range: %d", g.name, fv.Uint())
+			}
+			cmds = append(cmds, cmd)
+		}
+		i = j
+	}
+	return cmds, nil
+}
+
+// Unmarshal reads the fields of pointer to struct v from devAddr by its
+// Mapping.
+func (c *Controller) Unmarshal(devAddr byte, v any) error {
+	return c.UnmarshalContext(context.Background(), devAddr, v)
+}
+
+// UnmarshalContext is Unmarshal that stops when ctx is done.
+func (c *Controller) UnmarshalContext(
+	ctx context.Context, devAddr byte, v any,
+) error {
+	m, err := mappingOf(v)
+	if err != nil {
+		return err
+	}
+	p := m.ReadPlan(devAddr)
+	if err := c.SendPlanContext(ctx, p); err != nil {
+		return err
+	}
+	return m.Decode(v, p)
+}
+
+// Marshal writes the coil and hreg fields of struct v to devAddr by its
+// Mapping.
+func (c *Controller) Marshal(devAddr byte, v any) error {
+	return c.MarshalContext(context.Background(), devAddr, v)
+}
+
+// MarshalContext is Marshal that stops when ctx is done.
+func (c *Controller) MarshalContext(
+	ctx context.Context, devAddr byte, v any,
+) error {
+	m, err := mappingOf(v)
+	if err != nil {
+		return err
+	}
+	cmds, err := m.WriteCmds(devAddr, v)
+	if err != nil {
+		return err
+	}
+	for _, err := range c.SendAllContext(ctx, cmds...) {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package modbus_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bangzek/modbus-tcp"
+)
+
+type Meter struct {
+	Run     bool    `modbus:"coil,0"`
+	Reset   bool    `modbus:"coil,1"`
+	Alarm   bool    `modbus:"dinput,10"`
+	Volt    float32 `modbus:"ireg,0,float32,cdab"`
+	Energy  uint64  `modbus:"ireg,2"`
+	Serial  string  `modbus:"ireg,6,text3,badc"`
+	Setting int16   `modbus:"hreg,100"`
+	Limit   float64 `modbus:"hreg,101,,dcba"`
+	Version uint16  `modbus:"hreg,200,bcd16"`
+	Note    string  `modbus:"-"`
+	Other   int
+}
+
+var _ = Describe("Mapping", func() {
+	var ds *DataStore
+	var u *Unit
+	var srv *Server
+	var con *Controller
+
+	BeforeEach(func() {
+		ResetClock()
+		ds = &DataStore{}
+		u = ds.AddUnit(1)
+		u.Define(CoilTable, 0, 2)
+		u.Define(DInputTable, 10, 1)
+		u.Define(IRegTable, 0, 9)
+		u.Define(HRegTable, 100, 5)
+		u.Define(HRegTable, 200, 1)
+		srv = &Server{Handler: ds}
+		con = &Controller{Dialer: &PipeDialer{Server: srv}}
+	})
+	AfterEach(func() {
+		con.Close()
+		srv.Close()
+	})
+
+	It("has read cmds", func() {
+		m, err := NewMapping(Meter{})
+		Expect(err).To(Succeed())
+		var s []string
+		for _, cmd := range m.ReadPlan(1).Cmds() {
+			s = append(s, cmd.Tx())
+		}
+		Expect(s).To(Equal([]string{
+			"0000 1<-RC  0:2",
+			"0000 1<-RDI 10:1",
+			"0000 1<-RHR 100:5",
+			"0000 1<-RHR 200:1",
+			"0000 1<-RIR 0:9",
+		}))
+	})
+
+	It("unmarshals", func() {
+		Expect(u.SetBit(CoilTable, 1, true)).To(Succeed())
+		Expect(u.SetBit(DInputTable, 10, true)).To(Succeed())
+		for i, v := range []uint16{
+			0, 0x3FC0, 0, 0, 0, 1234, 'E'<<8 | 'S', 0<<8 | 'N', 0,
+		} {
+			Expect(u.SetReg(IRegTable, uint16(i), v)).To(Succeed())
+		}
+		for i, v := range []uint16{0xFFFE, 0, 0, 0, 0xD0BF} {
+			Expect(u.SetReg(HRegTable, uint16(100+i), v)).To(Succeed())
+		}
+		Expect(u.SetReg(HRegTable, 200, 0x0102)).To(Succeed())
+
+		m := Meter{Note: "x", Other: 3}
+		Expect(con.Unmarshal(1, &m)).To(Succeed())
+		Expect(m).To(Equal(Meter{
+			Reset:   true,
+			Alarm:   true,
+			Volt:    1.5,
+			Energy:  1234,
+			Serial:  "SEN",
+			Setting: -2,
+			Limit:   -0.25,
+			Version: 102,
+			Note:    "x",
+			Other:   3,
+		}))
+	})
+
+	It("has invalid BCD", func() {
+		Expect(u.SetReg(HRegTable, 200, 0x00A0)).To(Succeed())
+		var m Meter
+		Expect(con.Unmarshal(1, &m)).
+			To(MatchError("invalid bcd16 of Version"))
+	})
+
+	It("returns error of cmd", func() {
+		var m struct {
+			X uint16 `modbus:"hreg,300"`
+		}
+		Expect(con.Unmarshal(1, &m)).To(MatchError(IllegalDataAddress))
+	})
+
+	It("marshals", func() {
+		m := Meter{
+			Run:     true,
+			Alarm:   true,
+			Volt:    2,
+			Serial:  "SN",
+			Setting: 7,
+			Limit:   -0.25,
+			Version: 9870,
+		}
+		Expect(con.Marshal(1, m)).To(Succeed())
+		Expect(u.Bit(CoilTable, 0)).To(BeTrue())
+		Expect(u.Bit(CoilTable, 1)).To(BeFalse())
+		Expect(u.Bit(DInputTable, 10)).To(BeFalse())
+		Expect(u.Reg(IRegTable, 1)).To(BeZero())
+		var regs []uint16
+		for i := uint16(100); i < 105; i++ {
+			v, err := u.Reg(HRegTable, i)
+			Expect(err).To(Succeed())
+			regs = append(regs, v)
+		}
+		Expect(regs).To(Equal([]uint16{7, 0, 0, 0, 0xD0BF}))
+		Expect(u.Reg(HRegTable, 200)).To(Equal(uint16(0x9870)))
+
+		var n Meter
+		Expect(con.Unmarshal(1, &n)).To(Succeed())
+		Expect(n.Setting).To(Equal(int16(7)))
+		Expect(n.Limit).To(Equal(-0.25))
+		Expect(n.Version).To(Equal(uint16(9870)))
+	})
+
+	It("has write cmds", func() {
+		m, err := NewMapping(&Meter{})
+		Expect(err).To(Succeed())
+		cmds, err := m.WriteCmds(0, &Meter{Run: true, Setting: 1})
+		Expect(err).To(Succeed())
+		var s []string
+		for _, cmd := range cmds {
+			s = append(s, cmd.Tx())
+		}
+		Expect(s).To(Equal([]string{
+			"0000 0<-WC  0:2[1 0]",
+			"0000 0<-WR  100:5[    1     0     0     0     0]",
+			"0000 0<-WR  200:1[    0]",
+		}))
+	})
+
+	It("doesn't marshal what doesn't fit", func() {
+		Expect(con.Marshal(1, Meter{Version: 12345})).
+			To(MatchError("Version out of range: 12345"))
+		var m struct {
+			X uint32 `modbus:"hreg,100,bcd32"`
+			Y string `modbus:"hreg,102,text2"`
+		}
+		m.X = 100000000
+		Expect(con.Marshal(1, m)).
+			To(MatchError("X out of range: 100000000"))
+		m.X, m.Y = 0, "SENSOR"
+		Expect(con.Marshal(1, m)).To(MatchError(`Y too long: "SENSOR"`))
+		Expect(u.Reg(HRegTable, 100)).To(BeZero())
+	})
+
+	It("splits long reads", func() {
+		var m struct {
+			A string `modbus:"hreg,0,text100"`
+			B string `modbus:"hreg,100,text100"`
+			C uint16 `modbus:"hreg,200"`
+		}
+		mp, err := NewMapping(&m)
+		Expect(err).To(Succeed())
+		var s []string
+		for _, cmd := range mp.ReadPlan(1).Cmds() {
+			s = append(s, cmd.Tx())
+		}
+		Expect(s).To(Equal([]string{
+			"0000 1<-RHR 0:100",
+			"0000 1<-RHR 100:101",
+		}))
+	})
+
+	It("panics on invalid value", func() {
+		m, err := NewMapping(Meter{})
+		Expect(err).To(Succeed())
+		Expect(func() {
+			m.Decode(Meter{}, m.ReadPlan(1))
+		}).Should(PanicWith("invalid value: modbus_test.Meter"))
+		Expect(func() {
+			m.WriteCmds(1, 3)
+		}).Should(PanicWith("invalid value: int"))
+	})
+
+	It("can't map non struct", func() {
+		_, err := NewMapping(3)
+		Expect(err).To(MatchError("modbus mapping of non struct: int"))
+		Expect(con.Marshal(1, nil)).
+			To(MatchError("modbus mapping of non struct: <nil>"))
+	})
+
+	DescribeTable("invalid tag",
+		func(v any, s string) {
+			_, err := NewMapping(v)
+			Expect(err).To(MatchError(s))
+			Expect(err).To(BeAssignableToTypeOf(TagErr{}))
+		},
+		Entry("short", struct {
+			A uint16 `modbus:"hreg"`
+		}{}, `invalid modbus tag "hreg" of A: want table,addr[,type[,order]]`),
+		Entry("table", struct {
+			A uint16 `modbus:"reg,1"`
+		}{}, `invalid modbus tag "reg,1" of A: invalid table: reg`),
+		Entry("address", struct {
+			A uint16 `modbus:"hreg,65536"`
+		}{}, `invalid modbus tag "hreg,65536" of A: invalid address: 65536`),
+		Entry("type", struct {
+			A uint16 `modbus:"hreg,1,word"`
+		}{}, `invalid modbus tag "hreg,1,word" of A: invalid type: word`),
+		Entry("text", struct {
+			A string `modbus:"hreg,1,text"`
+		}{}, `invalid modbus tag "hreg,1,text" of A: invalid type: text`),
+		Entry("no type", struct {
+			A int `modbus:"hreg,1"`
+		}{}, `invalid modbus tag "hreg,1" of A: no type for int`),
+		Entry("mismatch", struct {
+			A uint16 `modbus:"hreg,1,float32"`
+		}{}, `invalid modbus tag "hreg,1,float32" of A: float32 into uint16`),
+		Entry("bool in reg", struct {
+			A bool `modbus:"ireg,1"`
+		}{}, `invalid modbus tag "ireg,1" of A: bool in ireg`),
+		Entry("reg in bits", struct {
+			A uint16 `modbus:"coil,1"`
+		}{}, `invalid modbus tag "coil,1" of A: uint16 in coil`),
+		Entry("order", struct {
+			A uint32 `modbus:"hreg,1,,abdc"`
+		}{}, `invalid modbus tag "hreg,1,,abdc" of A: invalid order: abdc`),
+		Entry("overflow", struct {
+			A uint32 `modbus:"hreg,65535"`
+		}{}, `invalid modbus tag "hreg,65535" of A: address overflow`),
+		Entry("overlap", struct {
+			A uint32 `modbus:"hreg,10"`
+			B uint16 `modbus:"ireg,11"`
+			C uint16 `modbus:"hreg,11"`
+		}{}, `invalid modbus tag "hreg,11" of C: overlaps A`),
+	)
+})
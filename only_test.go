@@ -5,3 +5,7 @@ import "github.com/bangzek/clock"
 func SetClock(mock *clock.Mock) {
 	ctime = mock
 }
+
+func ResetClock() {
+	ctime = clock.New()
+}
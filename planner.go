@@ -0,0 +1,232 @@
+package modbus
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// span is count registers or bits at addr of table.
+type span struct {
+	table Table
+	addr  uint16
+	n     int
+}
+
+func (s span) end() int {
+	return int(s.addr) + s.n
+}
+
+func (s span) String() string {
+	return fmt.Sprintf("%s %d:%d", s.table, s.addr, s.n)
+}
+
+// Planner merges the wanted registers and bits into the least read cmds.
+// Points no more than Gap apart are read by one cmd with the unwanted ones
+// between, so only set Gap when the device answers them. A point is read by
+// one cmd, even twice when it overlaps one that can't be merged, unless it's
+// longer than a cmd could read.
+type Planner struct {
+	// Gap is the max unwanted registers to merge two points.
+	Gap int
+	// BitGap is the max unwanted coils or inputs to merge two points.
+	BitGap int
+
+	points []span
+}
+
+// Add wants count registers or bits at addr of t.
+func (p *Planner) Add(t Table, addr uint16, count uint16) {
+	if t < CoilTable || t > IRegTable {
+		panic("invalid " + t.String())
+	}
+	if count == 0 {
+		panic("zero count")
+	}
+	if int(addr)+int(count) > 0x10000 {
+		panic(fmt.Sprintf("address overflow: %d, %d", addr, count))
+	}
+	p.points = append(p.points, span{t, addr, int(count)})
+}
+
+// Plan returns the Plan of every point added so far for devAddr.
+func (p *Planner) Plan(devAddr byte) *Plan {
+	points := slices.Clone(p.points)
+	sort.Slice(points, func(i, j int) bool {
+		a, b := points[i], points[j]
+		return a.table < b.table || (a.table == b.table && a.addr < b.addr)
+	})
+
+	var reads []span
+	for _, x := range points {
+		limit, gap := p.limit(x.table)
+		k := len(reads) - 1
+		if k >= 0 && reads[k].table == x.table {
+			r := &reads[k]
+			// already read, by two cmds only when it is inside a point too
+			// long for one
+			if x.end() <= r.end() {
+				continue
+			}
+			if int(x.addr) <= r.end()+gap && x.end()-int(r.addr) <= limit {
+				r.n = x.end() - int(r.addr)
+				continue
+			}
+		}
+		// read on its own even if it overlaps, to not split it
+		for x.n > 0 {
+			s := span{x.table, x.addr, min(x.n, limit)}
+			reads = append(reads, s)
+			x.addr += uint16(s.n)
+			x.n -= s.n
+		}
+	}
+
+	plan := &Plan{reads: reads}
+	for _, s := range reads {
+		plan.cmds = append(plan.cmds, readCmd(devAddr, s))
+	}
+	return plan
+}
+
+func (p *Planner) limit(t Table) (int, int) {
+	if t.IsBit() {
+		return maxReadBits, p.BitGap
+	}
+	return maxReadRegs, p.Gap
+}
+
+func readCmd(devAddr byte, s span) Cmd {
+	switch s.table {
+	case CoilTable:
+		return NewReadCoilsCmd(devAddr, s.addr, uint16(s.n))
+	case DInputTable:
+		return NewReadDInputsCmd(devAddr, s.addr, uint16(s.n))
+	case HRegTable:
+		return NewReadHRegsCmd(devAddr, s.addr, uint16(s.n))
+	default:
+		return NewReadIRegsCmd(devAddr, s.addr, uint16(s.n))
+	}
+}
+
+// Plan is the read cmds of a Planner. After the cmds are sent, any planned
+// point could be read back regardless of which cmd read it.
+type Plan struct {
+	reads []span
+	cmds  []Cmd
+	errs  []error
+}
+
+func (p *Plan) Cmds() []Cmd {
+	return p.cmds
+}
+
+// find returns the index of the cmds reading count at addr of t, it panics
+// when any of them is not planned.
+func (p *Plan) find(t Table, addr uint16, count int) (int, int) {
+	s := span{t, addr, count}
+	i := sort.Search(len(p.reads), func(i int) bool {
+		r := p.reads[i]
+		return r.table > t || (r.table == t && r.end() > int(addr))
+	})
+	// overlapping reads, the last one having addr reads the most of it
+	for i+1 < len(p.reads) && p.reads[i+1].table == t &&
+		p.reads[i+1].addr <= addr {
+		i++
+	}
+	j := i
+	for end := int(addr); j < len(p.reads); j++ {
+		r := p.reads[j]
+		if r.table != t || int(r.addr) > end {
+			break
+		}
+		end = r.end()
+		if end >= s.end() {
+			return i, j + 1
+		}
+	}
+	panic("not planned: " + s.String())
+}
+
+// Regs returns count registers at addr of t, a copy when they are read by
+// more than one cmd.
+func (p *Plan) Regs(t Table, addr uint16, count uint16) Regs {
+	if t.IsBit() {
+		panic("invalid register table: " + t.String())
+	}
+	i, j := p.find(t, addr, int(count))
+	if j-i == 1 {
+		a := int(addr-p.reads[i].addr) * 2
+		return cmdRegs(p.cmds[i])[a : a+int(count)*2]
+	}
+
+	r := make(Regs, 0, int(count)*2)
+	for k := i; k < j; k++ {
+		b := cmdRegs(p.cmds[k])
+		// after what is already in r, reads could overlap
+		a := (int(addr) + len(r)/2 - int(p.reads[k].addr)) * 2
+		e := min(int(addr)+int(count)-int(p.reads[k].addr), p.reads[k].n) * 2
+		r = append(r, b[a:e]...)
+	}
+	return r
+}
+
+func cmdRegs(cmd Cmd) Regs {
+	switch cmd := cmd.(type) {
+	case *ReadHRegsCmd:
+		return cmd.Regs()
+	default:
+		return cmd.(*ReadIRegsCmd).Regs()
+	}
+}
+
+func (p *Plan) Reg(t Table, addr uint16) uint16 {
+	return p.Regs(t, addr, 1).Uint16(0, ABCD)
+}
+
+func (p *Plan) Bit(t Table, addr uint16) bool {
+	if !t.IsBit() {
+		panic("invalid bit table: " + t.String())
+	}
+	i, _ := p.find(t, addr, 1)
+	a := int(addr - p.reads[i].addr)
+	switch cmd := p.cmds[i].(type) {
+	case *ReadCoilsCmd:
+		return cmd.Coil(a)
+	default:
+		return cmd.(*ReadDInputsCmd).Input(a)
+	}
+}
+
+// Err returns the first error of the cmds reading count at addr of t when
+// sent by SendPlan.
+func (p *Plan) Err(t Table, addr uint16, count uint16) error {
+	i, j := p.find(t, addr, int(count))
+	if p.errs == nil {
+		return nil
+	}
+	for _, err := range p.errs[i:j] {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendPlan sends the cmds of p with SendAll, it returns the first error. Use
+// Plan.Err for the error of a point.
+func (c *Controller) SendPlan(p *Plan) error {
+	return c.SendPlanContext(context.Background(), p)
+}
+
+// SendPlanContext is SendPlan that stops when ctx is done.
+func (c *Controller) SendPlanContext(ctx context.Context, p *Plan) error {
+	p.errs = c.SendAllContext(ctx, p.cmds...)
+	for _, err := range p.errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package modbus_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bangzek/modbus-tcp"
+)
+
+var _ = Describe("Planner", func() {
+	var p *Planner
+
+	BeforeEach(func() {
+		p = &Planner{}
+	})
+
+	Txs := func(plan *Plan) []string {
+		var s []string
+		for _, cmd := range plan.Cmds() {
+			s = append(s, cmd.Tx())
+		}
+		return s
+	}
+
+	It("merges adjacent points", func() {
+		p.Add(HRegTable, 2, 3)
+		p.Add(HRegTable, 0, 2)
+		p.Add(HRegTable, 10, 1)
+		p.Add(HRegTable, 3, 1)
+		Expect(Txs(p.Plan(1))).To(Equal([]string{
+			"0000 1<-RHR 0:5",
+			"0000 1<-RHR 10:1",
+		}))
+	})
+
+	It("merges with gap", func() {
+		p.Gap = 5
+		p.BitGap = 15
+		p.Add(IRegTable, 0, 2)
+		p.Add(IRegTable, 7, 1)
+		p.Add(IRegTable, 14, 1)
+		p.Add(CoilTable, 100, 1)
+		p.Add(CoilTable, 116, 1)
+		p.Add(CoilTable, 133, 1)
+		Expect(Txs(p.Plan(1))).To(Equal([]string{
+			"0000 1<-RC  100:17",
+			"0000 1<-RC  133:1",
+			"0000 1<-RIR 0:8",
+			"0000 1<-RIR 14:1",
+		}))
+	})
+
+	It("splits oversized points", func() {
+		p.Add(HRegTable, 0, 300)
+		p.Add(DInputTable, 1, 4500)
+		Expect(Txs(p.Plan(1))).To(Equal([]string{
+			"0000 1<-RDI 1:2000",
+			"0000 1<-RDI 2001:2000",
+			"0000 1<-RDI 4001:500",
+			"0000 1<-RHR 0:125",
+			"0000 1<-RHR 125:125",
+			"0000 1<-RHR 250:50",
+		}))
+	})
+
+	It("doesn't merge beyond limit", func() {
+		p.Gap = 10
+		p.Add(HRegTable, 0, 100)
+		p.Add(HRegTable, 100, 50)
+		p.Add(HRegTable, 155, 20)
+		Expect(Txs(p.Plan(1))).To(Equal([]string{
+			"0000 1<-RHR 0:100",
+			"0000 1<-RHR 100:75",
+		}))
+	})
+
+	It("merges overlapping points", func() {
+		p.Add(HRegTable, 0, 100)
+		p.Add(HRegTable, 50, 100)
+		p.Add(HRegTable, 60, 10)
+		Expect(Txs(p.Plan(1))).To(Equal([]string{
+			"0000 1<-RHR 0:100",
+			"0000 1<-RHR 50:100",
+		}))
+	})
+
+	It("has no cmds", func() {
+		Expect(p.Plan(1).Cmds()).To(BeEmpty())
+	})
+
+	It("panics on invalid point", func() {
+		Expect(func() {
+			p.Add(Table(0), 0, 1)
+		}).Should(PanicWith("invalid table 0"))
+		Expect(func() {
+			p.Add(HRegTable, 0, 0)
+		}).Should(PanicWith("zero count"))
+		Expect(func() {
+			p.Add(CoilTable, 65535, 2)
+		}).Should(PanicWith("address overflow: 65535, 2"))
+	})
+
+	Describe("Plan", func() {
+		var ds *DataStore
+		var u *Unit
+		var srv *Server
+		var con *Controller
+		var plan *Plan
+
+		BeforeEach(func() {
+			ResetClock()
+			ds = &DataStore{}
+			u = ds.AddUnit(1)
+			u.Define(CoilTable, 0, 10)
+			u.Define(DInputTable, 0, 10)
+			u.Define(HRegTable, 0, 300)
+			u.Define(IRegTable, 0, 10)
+			for i := uint16(0); i < 300; i++ {
+				Expect(u.SetReg(HRegTable, i, i)).To(Succeed())
+			}
+			Expect(u.SetReg(IRegTable, 3, 33)).To(Succeed())
+			Expect(u.SetBit(CoilTable, 9, true)).To(Succeed())
+			Expect(u.SetBit(DInputTable, 1, true)).To(Succeed())
+			srv = &Server{Handler: ds}
+			con = &Controller{Dialer: &PipeDialer{Server: srv}}
+
+			p.Gap = 2
+			p.Add(HRegTable, 0, 200)
+			p.Add(HRegTable, 203, 10)
+			p.Add(IRegTable, 3, 1)
+			p.Add(CoilTable, 9, 1)
+			p.Add(DInputTable, 0, 2)
+			plan = p.Plan(1)
+		})
+		AfterEach(func() {
+			con.Close()
+			srv.Close()
+		})
+
+		It("reads back any point", func() {
+			Expect(Txs(plan)).To(Equal([]string{
+				"0000 1<-RC  9:1",
+				"0000 1<-RDI 0:2",
+				"0000 1<-RHR 0:125",
+				"0000 1<-RHR 125:75",
+				"0000 1<-RHR 203:10",
+				"0000 1<-RIR 3:1",
+			}))
+			Expect(con.SendPlan(plan)).To(Succeed())
+
+			Expect(plan.Reg(HRegTable, 0)).To(Equal(uint16(0)))
+			Expect(plan.Reg(HRegTable, 212)).To(Equal(uint16(212)))
+			Expect(plan.Reg(IRegTable, 3)).To(Equal(uint16(33)))
+			r := plan.Regs(HRegTable, 123, 4)
+			Expect(r).To(Equal(Regs{0, 123, 0, 124, 0, 125, 0, 126}))
+			r = plan.Regs(HRegTable, 0, 200)
+			Expect(r.Len()).To(Equal(200))
+			Expect(r.Uint16(199, ABCD)).To(Equal(uint16(199)))
+			Expect(plan.Regs(HRegTable, 130, 2)).To(Equal(Regs{0, 130, 0, 131}))
+			Expect(plan.Bit(CoilTable, 9)).To(BeTrue())
+			Expect(plan.Bit(DInputTable, 0)).To(BeFalse())
+			Expect(plan.Bit(DInputTable, 1)).To(BeTrue())
+			Expect(plan.Err(HRegTable, 0, 200)).To(Succeed())
+		})
+
+		It("reads back overlapping points", func() {
+			p = &Planner{}
+			p.Add(HRegTable, 0, 100)
+			p.Add(HRegTable, 50, 100)
+			plan = p.Plan(1)
+			Expect(con.SendPlan(plan)).To(Succeed())
+
+			r := plan.Regs(HRegTable, 50, 100)
+			Expect(r.Len()).To(Equal(100))
+			Expect(r.Uint16(0, ABCD)).To(Equal(uint16(50)))
+			Expect(r.Uint16(99, ABCD)).To(Equal(uint16(149)))
+			r = plan.Regs(HRegTable, 0, 150)
+			Expect(r.Len()).To(Equal(150))
+			for i := 0; i < 150; i++ {
+				Expect(r.Uint16(i, ABCD)).To(Equal(uint16(i)))
+			}
+			Expect(plan.Reg(HRegTable, 99)).To(Equal(uint16(99)))
+		})
+
+		It("has Err of point", func() {
+			p.Add(IRegTable, 20, 1)
+			plan = p.Plan(1)
+			Expect(con.SendPlan(plan)).To(MatchError(IllegalDataAddress))
+			Expect(plan.Err(IRegTable, 20, 1)).
+				To(MatchError(IllegalDataAddress))
+			Expect(plan.Err(IRegTable, 3, 1)).To(Succeed())
+			Expect(plan.Reg(IRegTable, 3)).To(Equal(uint16(33)))
+		})
+
+		It("has no Err before sent", func() {
+			Expect(plan.Err(HRegTable, 0, 1)).To(Succeed())
+		})
+
+		It("panics on not planned point", func() {
+			Expect(func() {
+				plan.Reg(HRegTable, 200)
+			}).Should(PanicWith("not planned: hreg 200:1"))
+			Expect(func() {
+				plan.Regs(HRegTable, 210, 5)
+			}).Should(PanicWith("not planned: hreg 210:5"))
+			Expect(func() {
+				plan.Reg(IRegTable, 0)
+			}).Should(PanicWith("not planned: ireg 0:1"))
+			Expect(func() {
+				plan.Bit(CoilTable, 8)
+			}).Should(PanicWith("not planned: coil 8:1"))
+			Expect(func() {
+				plan.Err(DInputTable, 2, 1)
+			}).Should(PanicWith("not planned: dinput 2:1"))
+		})
+
+		It("panics on invalid table", func() {
+			Expect(func() {
+				plan.Regs(CoilTable, 9, 1)
+			}).Should(PanicWith("invalid register table: coil"))
+			Expect(func() {
+				plan.Bit(HRegTable, 0)
+			}).Should(PanicWith("invalid bit table: hreg"))
+		})
+	})
+})
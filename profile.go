@@ -0,0 +1,567 @@
+package modbus
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Access is what could be done to a Tag.
+type Access byte
+
+const (
+	ReadOnly Access = 1 << iota
+	WriteOnly
+	ReadWrite = ReadOnly | WriteOnly
+)
+
+func (a Access) String() string {
+	switch a {
+	case ReadOnly:
+		return "r"
+	case WriteOnly:
+		return "w"
+	case ReadWrite:
+		return "rw"
+	default:
+		return fmt.Sprintf("access %d", byte(a))
+	}
+}
+
+func parseAccess(s string) (Access, bool) {
+	for a := ReadOnly; a <= ReadWrite; a++ {
+		if s == a.String() {
+			return a, true
+		}
+	}
+	return 0, false
+}
+
+// Tag is a named value of a device Profile.
+type Tag struct {
+	Name  string
+	Table Table
+	Addr  uint16
+	Type  Type
+	Order Order
+	// Len is the number of registers of TextType.
+	Len int
+	// Value is the raw value * Scale + Offset, Scale 0 is 1.
+	Scale  float64
+	Offset float64
+	Units  string
+	Access Access
+	// Line is where the tag is in the profile file.
+	Line int
+}
+
+func (t *Tag) point() point {
+	n := t.Type.Len()
+	if t.Type == TextType {
+		n = t.Len
+	}
+	return point{span{t.Table, t.Addr, n}, t.Type, t.Order}
+}
+
+func (t *Tag) scale() float64 {
+	if t.Scale == 0 {
+		return 1
+	}
+	return t.Scale
+}
+
+// Value returns the value of t from p after it is sent. It is bool of coil
+// and dinput, string of TextType and scaled float64 of the rest.
+func (t *Tag) Value(p *Plan) (any, error) {
+	pt := t.point()
+	if t.Table.IsBit() {
+		return p.Bit(t.Table, t.Addr), nil
+	}
+	r := p.Regs(t.Table, t.Addr, uint16(pt.n))
+	if t.Type == TextType {
+		return r.Text(0, pt.n, t.Order), nil
+	}
+
+	var v float64
+	switch t.Type {
+	case Int16Type:
+		v = float64(r.Int16(0, t.Order))
+	case Uint16Type:
+		v = float64(r.Uint16(0, t.Order))
+	case Int32Type:
+		v = float64(r.Int32(0, t.Order))
+	case Uint32Type:
+		v = float64(r.Uint32(0, t.Order))
+	case Int64Type:
+		v = float64(r.Int64(0, t.Order))
+	case Uint64Type:
+		v = float64(r.Uint64(0, t.Order))
+	case Float32Type:
+		v = float64(r.Float32(0, t.Order))
+	case Float64Type:
+		v = r.Float64(0, t.Order)
+	case BCD16Type:
+		x, ok := r.BCD16(0, t.Order)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s of %s", t.Type, t.Name)
+		}
+		v = float64(x)
+	case BCD32Type:
+		x, ok := r.BCD32(0, t.Order)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s of %s", t.Type, t.Name)
+		}
+		v = float64(x)
+	}
+	return v*t.scale() + t.Offset, nil
+}
+
+// WriteCmd returns the cmd to write v to t of devAddr. v is bool of coil,
+// string of TextType and float64 of the rest that is unscaled back.
+func (t *Tag) WriteCmd(devAddr byte, v any) (Cmd, error) {
+	if t.Access&WriteOnly == 0 {
+		return nil, fmt.Errorf("%s is read only", t.Name)
+	}
+
+	pt := t.point()
+	switch v := v.(type) {
+	case bool:
+		if t.Table == CoilTable {
+			return NewWriteCoilCmd(devAddr, t.Addr, v), nil
+		}
+	case string:
+		if t.Type == TextType {
+			if len(v) > pt.n*2 {
+				return nil, fmt.Errorf("%s too long: %q", t.Name, v)
+			}
+			cmd := NewWriteRegsCmd(devAddr, t.Addr, make([]uint16, pt.n))
+			cmd.Regs().PutText(0, pt.n, v, t.Order)
+			return cmd, nil
+		}
+	case float64:
+		if t.Table.IsBit() || t.Type == TextType {
+			break
+		}
+		x := (v - t.Offset) / t.scale()
+		if t.Type != Float32Type && t.Type != Float64Type {
+			x = math.Round(x)
+			if lo, end := t.Type.rangeOf(); !(x >= lo && x < end) {
+				return nil, fmt.Errorf("%s out of range: %g", t.Name, v)
+			}
+		}
+
+		var rv reflect.Value
+		switch t.Type.kind() {
+		case reflect.Int16, reflect.Int32, reflect.Int64:
+			rv = reflect.ValueOf(int64(x))
+		case reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			rv = reflect.ValueOf(uint64(x))
+		default:
+			rv = reflect.ValueOf(x)
+		}
+		cmd := NewWriteRegsCmd(devAddr, t.Addr, make([]uint16, pt.n))
+		pt.encode(cmd.Regs(), 0, rv)
+		return cmd, nil
+	}
+	return nil, fmt.Errorf("invalid %s value: %T", t.Name, v)
+}
+
+// rangeOf returns the min and the end past max value of integer type, the
+// end of 64 bits is exact in float64 while their max isn't.
+func (t Type) rangeOf() (float64, float64) {
+	switch t {
+	case Int16Type:
+		return math.MinInt16, math.MaxInt16 + 1
+	case Uint16Type:
+		return 0, math.MaxUint16 + 1
+	case Int32Type:
+		return math.MinInt32, math.MaxInt32 + 1
+	case Uint32Type:
+		return 0, math.MaxUint32 + 1
+	case Int64Type:
+		return math.MinInt64, 0x1p63
+	case BCD16Type:
+		return 0, 10000
+	case BCD32Type:
+		return 0, 100000000
+	default:
+		return 0, 0x1p64
+	}
+}
+
+// ProfileErr is an invalid device profile, Line is 0 when it is unknown.
+type ProfileErr struct {
+	File string
+	Line int
+	Tag  string
+	Err  string
+}
+
+func (e ProfileErr) Error() string {
+	s := "invalid profile"
+	if e.File != "" {
+		s += " " + e.File
+	}
+	if e.Line > 0 {
+		s += " line " + strconv.Itoa(e.Line)
+	}
+	if e.Tag != "" {
+		s += " tag " + e.Tag
+	}
+	return s + ": " + e.Err
+}
+
+// Profile is the register map of a device. The profile file is YAML, or JSON
+// since it is YAML too, like:
+//
+//	name: meter
+//	gap: 2
+//	tags:
+//	  - name: voltage
+//	    table: ireg
+//	    address: 0
+//	    type: float32
+//	    order: cdab
+//	    units: V
+//	  - name: serial
+//	    table: ireg
+//	    address: 10
+//	    type: text
+//	    length: 8
+//	  - name: limit
+//	    table: hreg
+//	    address: 100
+//	    type: int16
+//	    scale: 0.1
+//	    access: rw
+//
+// Table is coil, dinput, hreg or ireg. Type is the Mapping type but text has
+// length, it is bool for coil and dinput. Order defaults to abcd and access
+// to rw of coil and hreg or r of dinput and ireg.
+type Profile struct {
+	Name string
+	// Gap and BitGap are of the Planner.
+	Gap    int
+	BitGap int
+	Tags   []Tag
+}
+
+// LoadProfile reads and parses the profile file name.
+func LoadProfile(name string) (*Profile, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	p, err := ParseProfile(b)
+	if e, ok := err.(ProfileErr); ok {
+		e.File = name
+		return nil, e
+	}
+	return p, err
+}
+
+// ParseProfile parses profile b, it returns ProfileErr of the first problem.
+func ParseProfile(b []byte) (*Profile, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, yamlErr(err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, ProfileErr{Err: "empty"}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, ProfileErr{Line: root.Line, Err: "want mapping"}
+	}
+
+	p := &Profile{}
+	seen := make(map[string]bool)
+	for i := 0; i < len(root.Content); i += 2 {
+		k, v := root.Content[i], root.Content[i+1]
+		if seen[k.Value] {
+			return nil, ProfileErr{
+				Line: k.Line,
+				Err:  "duplicate field: " + k.Value,
+			}
+		}
+		seen[k.Value] = true
+		var err error
+		switch k.Value {
+		case "name":
+			err = v.Decode(&p.Name)
+		case "gap":
+			err = v.Decode(&p.Gap)
+		case "bit_gap":
+			err = v.Decode(&p.BitGap)
+		case "tags":
+			if v.Kind != yaml.SequenceNode {
+				return nil, ProfileErr{Line: v.Line, Err: "tags want list"}
+			}
+			for _, n := range v.Content {
+				t, err := parseProfileTag(n)
+				if err != nil {
+					return nil, err
+				}
+				p.Tags = append(p.Tags, t)
+			}
+			continue
+		default:
+			return nil, ProfileErr{
+				Line: k.Line,
+				Err:  "unknown field: " + k.Value,
+			}
+		}
+		if err != nil || (k.Value != "name" &&
+			(v.Tag != "!!int" || p.Gap < 0 || p.BitGap < 0)) {
+			return nil, ProfileErr{
+				Line: v.Line,
+				Err:  "invalid " + k.Value + ": " + v.Value,
+			}
+		}
+	}
+	if len(p.Tags) == 0 {
+		return nil, ProfileErr{Line: root.Line, Err: "no tags"}
+	}
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// yamlErr turns "yaml: line 3: reason" into ProfileErr.
+func yamlErr(err error) error {
+	s := strings.TrimPrefix(err.Error(), "yaml: ")
+	if l, ok := strings.CutPrefix(s, "line "); ok {
+		if n, r, ok := strings.Cut(l, ": "); ok {
+			if i, err := strconv.Atoi(n); err == nil {
+				return ProfileErr{Line: i, Err: r}
+			}
+		}
+	}
+	return ProfileErr{Err: s}
+}
+
+func parseProfileTag(n *yaml.Node) (Tag, error) {
+	t := Tag{Line: n.Line, Scale: 1}
+	if n.Kind != yaml.MappingNode {
+		return t, ProfileErr{Line: n.Line, Err: "tag want mapping"}
+	}
+	fields := make(map[string]*yaml.Node)
+	var dup *yaml.Node
+	for i := 0; i < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		if fields[k.Value] != nil {
+			if dup == nil {
+				dup = k
+			}
+			continue
+		}
+		if k.Value == "name" {
+			t.Name = v.Value
+		}
+		fields[k.Value] = v
+	}
+	fail := func(v *yaml.Node, s string) (Tag, error) {
+		return t, ProfileErr{Line: v.Line, Tag: t.Name, Err: s}
+	}
+	if t.Name == "" {
+		return fail(n, "missing name")
+	}
+	if dup != nil {
+		return fail(dup, "duplicate field: "+dup.Value)
+	}
+	for i := 0; i < len(n.Content); i += 2 {
+		k := n.Content[i]
+		switch k.Value {
+		case "name", "table", "address", "type", "order", "length", "scale",
+			"offset", "units", "access":
+		default:
+			return fail(k, "unknown field: "+k.Value)
+		}
+	}
+
+	var ok bool
+	v := fields["table"]
+	if v == nil {
+		return fail(n, "missing table")
+	}
+	if t.Table, ok = parseTable(v.Value); !ok {
+		return fail(v, "invalid table: "+v.Value)
+	}
+
+	if v = fields["address"]; v == nil {
+		return fail(n, "missing address")
+	}
+	addr, err := strconv.ParseUint(v.Value, 0, 16)
+	if err != nil || v.Tag != "!!int" {
+		return fail(v, "invalid address: "+v.Value)
+	}
+	t.Addr = uint16(addr)
+
+	if v = fields["type"]; v == nil && t.Table.IsBit() {
+		t.Type = BoolType
+	} else if v == nil {
+		return fail(n, "missing type")
+	} else if v.Value == "text" {
+		t.Type = TextType
+	} else if t.Type, _, ok = parseType(v.Value); !ok || t.Type == TextType {
+		// text has its length field, not textN
+		return fail(v, "invalid type: "+v.Value)
+	}
+	if t.Table.IsBit() != (t.Type == BoolType) {
+		return fail(v, t.Type.String()+" in "+t.Table.String())
+	}
+
+	if v = fields["length"]; t.Type == TextType {
+		if v == nil {
+			return fail(n, "missing length")
+		}
+		if err := v.Decode(&t.Len); err != nil || v.Tag != "!!int" ||
+			t.Len <= 0 || t.Len > maxReadRegs {
+			return fail(v, "invalid length: "+v.Value)
+		}
+	} else if v != nil {
+		return fail(v, "length of "+t.Type.String())
+	}
+
+	if v = fields["order"]; v != nil {
+		if t.Order, ok = parseOrder(v.Value); !ok {
+			return fail(v, "invalid order: "+v.Value)
+		}
+	}
+	for _, k := range []string{"scale", "offset"} {
+		if v = fields[k]; v == nil {
+			continue
+		}
+		x := &t.Scale
+		if k == "offset" {
+			x = &t.Offset
+		}
+		if err := v.Decode(x); err != nil || (v.Tag != "!!int" &&
+			v.Tag != "!!float") || (k == "scale" && *x == 0) {
+			return fail(v, "invalid "+k+": "+v.Value)
+		}
+		if t.Table.IsBit() || t.Type == TextType {
+			return fail(v, k+" of "+t.Type.String())
+		}
+	}
+	if v = fields["units"]; v != nil {
+		t.Units = v.Value
+	}
+
+	t.Access = ReadOnly
+	if t.Table == CoilTable || t.Table == HRegTable {
+		t.Access = ReadWrite
+	}
+	if v = fields["access"]; v != nil {
+		a, ok := parseAccess(v.Value)
+		if !ok {
+			return fail(v, "invalid access: "+v.Value)
+		}
+		if a&WriteOnly != 0 && t.Access == ReadOnly {
+			return fail(v, t.Table.String()+" is read only")
+		}
+		t.Access = a
+	}
+
+	if t.point().end() > 0x10000 {
+		return fail(n, "address overflow")
+	}
+	return t, nil
+}
+
+// check returns ProfileErr of duplicate name or overlapping tags.
+func (p *Profile) check() error {
+	names := make(map[string]bool, len(p.Tags))
+	tags := make([]*Tag, len(p.Tags))
+	for i := range p.Tags {
+		t := &p.Tags[i]
+		if names[t.Name] {
+			return ProfileErr{Line: t.Line, Tag: t.Name, Err: "duplicate name"}
+		}
+		names[t.Name] = true
+		tags[i] = t
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		a, b := tags[i], tags[j]
+		return a.Table < b.Table || (a.Table == b.Table && a.Addr < b.Addr)
+	})
+	for i := 1; i < len(tags); i++ {
+		a, b := tags[i-1], tags[i]
+		if a.Table == b.Table && int(b.Addr) < a.point().end() {
+			return ProfileErr{
+				Line: b.Line,
+				Tag:  b.Name,
+				Err:  "overlaps " + a.Name,
+			}
+		}
+	}
+	return nil
+}
+
+// Tag returns the tag of name or nil.
+func (p *Profile) Tag(name string) *Tag {
+	for i := range p.Tags {
+		if p.Tags[i].Name == name {
+			return &p.Tags[i]
+		}
+	}
+	return nil
+}
+
+// Plan returns the Plan to read every readable tag from devAddr.
+func (p *Profile) Plan(devAddr byte) *Plan {
+	pl := Planner{Gap: p.Gap, BitGap: p.BitGap}
+	for i := range p.Tags {
+		t := &p.Tags[i]
+		if t.Access&ReadOnly != 0 {
+			pl.Add(t.Table, t.Addr, uint16(t.point().n))
+		}
+	}
+	return pl.Plan(devAddr)
+}
+
+// Values returns the Value of every readable tag by name from pl of Plan
+// after it is sent.
+func (p *Profile) Values(pl *Plan) (map[string]any, error) {
+	m := make(map[string]any, len(p.Tags))
+	for i := range p.Tags {
+		t := &p.Tags[i]
+		if t.Access&ReadOnly == 0 {
+			continue
+		}
+		v, err := t.Value(pl)
+		if err != nil {
+			return nil, err
+		}
+		m[t.Name] = v
+	}
+	return m, nil
+}
+
+// ReadProfile reads every readable tag of p from devAddr and returns their
+// Value by name.
+func (c *Controller) ReadProfile(
+	devAddr byte, p *Profile,
+) (map[string]any, error) {
+	return c.ReadProfileContext(context.Background(), devAddr, p)
+}
+
+// ReadProfileContext is ReadProfile that stops when ctx is done.
+func (c *Controller) ReadProfileContext(
+	ctx context.Context, devAddr byte, p *Profile,
+) (map[string]any, error) {
+	pl := p.Plan(devAddr)
+	if err := c.SendPlanContext(ctx, pl); err != nil {
+		return nil, err
+	}
+	return p.Values(pl)
+}
@@ -0,0 +1,368 @@
+package modbus_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bangzek/modbus-tcp"
+)
+
+const meterYAML = `
+name: meter
+gap: 2
+tags:
+  - name: run
+    table: coil
+    address: 0
+  - name: alarm
+    table: dinput
+    address: 10
+    access: r
+  - name: voltage
+    table: ireg
+    address: 0
+    type: float32
+    order: cdab
+    units: V
+  - name: energy
+    table: ireg
+    address: 4
+    type: uint32
+    scale: 0.1
+    units: kWh
+  - name: serial
+    table: ireg
+    address: 10
+    type: text
+    length: 3
+  - name: limit
+    table: hreg
+    address: 100
+    type: int16
+    scale: 0.5
+    offset: -10
+  - name: version
+    table: hreg
+    address: 0x200
+    type: bcd16
+    access: r
+  - name: reset
+    table: coil
+    address: 5
+    access: w
+`
+
+const meterJSON = `{
+	"name": "meter",
+	"bit_gap": 8,
+	"tags": [
+		{"name": "run", "table": "coil", "address": 0},
+		{"name": "limit", "table": "hreg", "address": 100, "type": "int16",
+		 "scale": 0.5, "offset": -10}
+	]
+}`
+
+var _ = Describe("Profile", func() {
+	It("parses YAML", func() {
+		p, err := ParseProfile([]byte(meterYAML))
+		Expect(err).To(Succeed())
+		Expect(p.Name).To(Equal("meter"))
+		Expect(p.Gap).To(Equal(2))
+		Expect(p.Tags).To(HaveLen(8))
+		Expect(p.Tags[0]).To(Equal(Tag{
+			Name:   "run",
+			Table:  CoilTable,
+			Type:   BoolType,
+			Scale:  1,
+			Access: ReadWrite,
+			Line:   5,
+		}))
+		Expect(p.Tag("voltage")).To(Equal(&Tag{
+			Name:   "voltage",
+			Table:  IRegTable,
+			Type:   Float32Type,
+			Order:  CDAB,
+			Scale:  1,
+			Units:  "V",
+			Access: ReadOnly,
+			Line:   12,
+		}))
+		Expect(*p.Tag("serial")).To(HaveField("Len", 3))
+		Expect(*p.Tag("version")).To(HaveField("Addr", uint16(0x200)))
+		Expect(*p.Tag("reset")).To(HaveField("Access", WriteOnly))
+		Expect(p.Tag("none")).To(BeNil())
+	})
+
+	It("parses JSON", func() {
+		p, err := ParseProfile([]byte(meterJSON))
+		Expect(err).To(Succeed())
+		Expect(p.BitGap).To(Equal(8))
+		Expect(p.Tags[1]).To(Equal(Tag{
+			Name:   "limit",
+			Table:  HRegTable,
+			Addr:   100,
+			Type:   Int16Type,
+			Scale:  0.5,
+			Offset: -10,
+			Access: ReadWrite,
+			Line:   6,
+		}))
+	})
+
+	It("loads file", func() {
+		dir := GinkgoT().TempDir()
+		name := filepath.Join(dir, "meter.yaml")
+		Expect(os.WriteFile(name, []byte(meterYAML), 0o600)).To(Succeed())
+		p, err := LoadProfile(name)
+		Expect(err).To(Succeed())
+		Expect(p.Tags).To(HaveLen(8))
+
+		Expect(os.WriteFile(name, []byte("name: x\ntags: 3\n"), 0o600)).
+			To(Succeed())
+		_, err = LoadProfile(name)
+		Expect(err).To(MatchError(
+			"invalid profile " + name + " line 2: tags want list"))
+
+		_, err = LoadProfile(filepath.Join(dir, "none.yaml"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	DescribeTable("invalid",
+		func(s string, x string) {
+			_, err := ParseProfile([]byte(s))
+			Expect(err).To(MatchError("invalid profile" + x))
+			Expect(err).To(BeAssignableToTypeOf(ProfileErr{}))
+		},
+		Entry("syntax", "name: [x\n",
+			" line 1: did not find expected ',' or ']'"),
+		Entry("empty", "", ": empty"),
+		Entry("list", "- 1\n", " line 1: want mapping"),
+		Entry("field", "name: x\nfoo: 1\n", " line 2: unknown field: foo"),
+		Entry("gap", "gap: x\n", " line 1: invalid gap: x"),
+		Entry("negative gap", "gap: -1\n", " line 1: invalid gap: -1"),
+		Entry("no tags", "name: x\n", " line 1: no tags"),
+		Entry("duplicate field", "name: x\nname: y\n",
+			" line 2: duplicate field: name"),
+		Entry("tag", "tags:\n  - 1\n", " line 2: tag want mapping"),
+		Entry("name", "tags:\n  - table: coil\n", " line 2: missing name"),
+		Entry("tag field", "tags:\n  - name: a\n    tabel: coil\n",
+			" line 3 tag a: unknown field: tabel"),
+		Entry("duplicate tag field", "tags:\n  - name: a\n    table: coil\n"+
+			"    address: 1\n    address: 2\n",
+			" line 5 tag a: duplicate field: address"),
+		Entry("missing table", "tags:\n  - name: a\n",
+			" line 2 tag a: missing table"),
+		Entry("table", "tags:\n  - name: a\n    table: reg\n",
+			" line 3 tag a: invalid table: reg"),
+		Entry("missing address", "tags:\n  - name: a\n    table: coil\n",
+			" line 2 tag a: missing address"),
+		Entry("address",
+			"tags:\n  - name: a\n    table: coil\n    address: 65536\n",
+			" line 4 tag a: invalid address: 65536"),
+		Entry("missing type",
+			"tags:\n  - name: a\n    table: hreg\n    address: 1\n",
+			" line 2 tag a: missing type"),
+		Entry("type",
+			"tags:\n  - {name: a, table: hreg, address: 1, type: word}\n",
+			" line 2 tag a: invalid type: word"),
+		Entry("text with length in type",
+			"tags:\n  - {name: a, table: hreg, address: 1, type: text8}\n",
+			" line 2 tag a: invalid type: text8"),
+		Entry("bool in reg",
+			"tags:\n  - {name: a, table: hreg, address: 1, type: bool}\n",
+			" line 2 tag a: bool in hreg"),
+		Entry("reg in bits",
+			"tags:\n  - {name: a, table: coil, address: 1, type: int16}\n",
+			" line 2 tag a: int16 in coil"),
+		Entry("missing length",
+			"tags:\n  - {name: a, table: hreg, address: 1, type: text}\n",
+			" line 2 tag a: missing length"),
+		Entry("length", "tags:\n  - {name: a, table: hreg, address: 1, "+
+			"type: text, length: 126}\n",
+			" line 2 tag a: invalid length: 126"),
+		Entry("length of number", "tags:\n  - {name: a, table: hreg, "+
+			"address: 1, type: int16, length: 2}\n",
+			" line 2 tag a: length of int16"),
+		Entry("order", "tags:\n  - {name: a, table: hreg, address: 1, "+
+			"type: int32, order: abdc}\n",
+			" line 2 tag a: invalid order: abdc"),
+		Entry("scale", "tags:\n  - {name: a, table: hreg, address: 1, "+
+			"type: int32, scale: 0}\n",
+			" line 2 tag a: invalid scale: 0"),
+		Entry("offset", "tags:\n  - {name: a, table: hreg, address: 1, "+
+			"type: int32, offset: x}\n",
+			" line 2 tag a: invalid offset: x"),
+		Entry("scale of bool",
+			"tags:\n  - {name: a, table: coil, address: 1, scale: 2}\n",
+			" line 2 tag a: scale of bool"),
+		Entry("access",
+			"tags:\n  - {name: a, table: coil, address: 1, access: x}\n",
+			" line 2 tag a: invalid access: x"),
+		Entry("read only",
+			"tags:\n  - {name: a, table: dinput, address: 1, access: rw}\n",
+			" line 2 tag a: dinput is read only"),
+		Entry("overflow",
+			"tags:\n  - {name: a, table: ireg, address: 65535, type: int32}\n",
+			" line 2 tag a: address overflow"),
+		Entry("duplicate", "tags:\n"+
+			"  - {name: a, table: coil, address: 1}\n"+
+			"  - {name: a, table: coil, address: 2}\n",
+			" line 3 tag a: duplicate name"),
+		Entry("overlap", "tags:\n"+
+			"  - {name: a, table: hreg, address: 1, type: float64}\n"+
+			"  - {name: b, table: ireg, address: 2, type: int16}\n"+
+			"  - {name: c, table: hreg, address: 4, type: int16}\n",
+			" line 4 tag c: overlaps a"),
+	)
+
+	Describe("Controller", func() {
+		var p *Profile
+		var u *Unit
+		var srv *Server
+		var con *Controller
+
+		BeforeEach(func() {
+			var err error
+			p, err = ParseProfile([]byte(meterYAML))
+			Expect(err).To(Succeed())
+
+			ResetClock()
+			ds := &DataStore{}
+			u = ds.AddUnit(1)
+			u.Define(CoilTable, 0, 6)
+			u.Define(DInputTable, 10, 1)
+			u.Define(IRegTable, 0, 13)
+			u.Define(HRegTable, 100, 1)
+			u.Define(HRegTable, 0x200, 1)
+			srv = &Server{Handler: ds}
+			con = &Controller{Dialer: &PipeDialer{Server: srv}}
+		})
+		AfterEach(func() {
+			con.Close()
+			srv.Close()
+		})
+
+		It("has Plan", func() {
+			var s []string
+			for _, cmd := range p.Plan(1).Cmds() {
+				s = append(s, cmd.Tx())
+			}
+			Expect(s).To(Equal([]string{
+				"0000 1<-RC  0:1",
+				"0000 1<-RDI 10:1",
+				"0000 1<-RHR 100:1",
+				"0000 1<-RHR 512:1",
+				"0000 1<-RIR 0:6",
+				"0000 1<-RIR 10:3",
+			}))
+		})
+
+		It("reads values", func() {
+			Expect(u.SetBit(CoilTable, 0, true)).To(Succeed())
+			for i, v := range []uint16{
+				0, 0x4366, 0, 0, 0, 12345, 0, 0, 0, 0, 'S'<<8 | 'N', '1' << 8,
+			} {
+				Expect(u.SetReg(IRegTable, uint16(i), v)).To(Succeed())
+			}
+			Expect(u.SetReg(HRegTable, 100, 0xFFFC)).To(Succeed())
+			Expect(u.SetReg(HRegTable, 0x200, 0x0102)).To(Succeed())
+
+			m, err := con.ReadProfile(1, p)
+			Expect(err).To(Succeed())
+			Expect(m).To(Equal(map[string]any{
+				"run":     true,
+				"alarm":   false,
+				"voltage": 230.0,
+				"energy":  1234.5,
+				"serial":  "SN1",
+				"limit":   -12.0,
+				"version": 102.0,
+			}))
+		})
+
+		It("has invalid BCD", func() {
+			Expect(u.SetReg(HRegTable, 0x200, 0x00F0)).To(Succeed())
+			_, err := con.ReadProfile(1, p)
+			Expect(err).To(MatchError("invalid bcd16 of version"))
+		})
+
+		It("returns error of cmd", func() {
+			p.Tags = append(p.Tags, Tag{
+				Name:   "x",
+				Table:  IRegTable,
+				Addr:   300,
+				Type:   Int16Type,
+				Access: ReadOnly,
+			})
+			_, err := con.ReadProfile(1, p)
+			Expect(err).To(MatchError(IllegalDataAddress))
+		})
+
+		It("writes values", func() {
+			cmd, err := p.Tag("limit").WriteCmd(1, 15.0)
+			Expect(err).To(Succeed())
+			Expect(cmd.Tx()).To(Equal("0000 1<-WR  100:1[   50]"))
+			Expect(con.Send(cmd)).To(Succeed())
+			Expect(u.Reg(HRegTable, 100)).To(Equal(uint16(50)))
+
+			cmd, err = p.Tag("reset").WriteCmd(1, true)
+			Expect(err).To(Succeed())
+			Expect(con.Send(cmd)).To(Succeed())
+			Expect(u.Bit(CoilTable, 5)).To(BeTrue())
+
+			t := Tag{Name: "sn", Table: HRegTable, Type: TextType, Len: 2,
+				Access: ReadWrite}
+			cmd, err = t.WriteCmd(1, "AB")
+			Expect(err).To(Succeed())
+			Expect(cmd.Tx()).To(Equal("0000 1<-WR  0:2[16706     0]"))
+
+			t = Tag{Name: "f", Table: HRegTable, Type: Float32Type,
+				Order: CDAB, Scale: 2, Access: ReadWrite}
+			cmd, err = t.WriteCmd(1, 3.0)
+			Expect(err).To(Succeed())
+			Expect(cmd.Tx()).To(Equal("0000 1<-WR  0:2[    0 16320]"))
+		})
+
+		It("can't write invalid values", func() {
+			_, err := p.Tag("voltage").WriteCmd(1, 1.0)
+			Expect(err).To(MatchError("voltage is read only"))
+			_, err = p.Tag("limit").WriteCmd(1, 20000.0)
+			Expect(err).To(MatchError("limit out of range: 20000"))
+			_, err = p.Tag("limit").WriteCmd(1, 1)
+			Expect(err).To(MatchError("invalid limit value: int"))
+			_, err = p.Tag("run").WriteCmd(1, 1.0)
+			Expect(err).To(MatchError("invalid run value: float64"))
+
+			t := Tag{Name: "sn", Table: HRegTable, Type: TextType, Len: 1,
+				Access: ReadWrite}
+			_, err = t.WriteCmd(1, "ABC")
+			Expect(err).To(MatchError(`sn too long: "ABC"`))
+			_, err = t.WriteCmd(1, 1.0)
+			Expect(err).To(MatchError("invalid sn value: float64"))
+
+			t = Tag{Name: "v", Table: HRegTable, Type: BCD16Type,
+				Access: ReadWrite}
+			_, err = t.WriteCmd(1, 10000.0)
+			Expect(err).To(MatchError("v out of range: 10000"))
+
+			t = Tag{Name: "i", Table: HRegTable, Type: Int64Type,
+				Access: ReadWrite}
+			_, err = t.WriteCmd(1, 0x1p63)
+			Expect(err).To(MatchError("i out of range: 9.223372036854776e+18"))
+			cmd, err := t.WriteCmd(1, -0x1p63)
+			Expect(err).To(Succeed())
+			Expect(cmd.Tx()).
+				To(Equal("0000 1<-WR  0:4[32768     0     0     0]"))
+
+			t = Tag{Name: "u", Table: HRegTable, Type: Uint64Type,
+				Access: ReadWrite}
+			_, err = t.WriteCmd(1, 0x1p64)
+			Expect(err).To(MatchError("u out of range: 1.8446744073709552e+19"))
+			_, err = t.WriteCmd(1, -1.0)
+			Expect(err).To(MatchError("u out of range: -1"))
+		})
+	})
+})
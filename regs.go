@@ -0,0 +1,276 @@
+package modbus
+
+import (
+	"fmt"
+	"math"
+)
+
+// Order is the byte order of value longer than a register, A is the most
+// significant byte. Modbus itself is ABCD, CDAB swaps the registers and BADC
+// swaps the bytes of each register. 64 bits value follows the same pattern,
+// CDAB of ABCDEFGH is GHEFCDAB.
+type Order byte
+
+const (
+	ABCD Order = iota
+	CDAB
+	BADC
+	DCBA
+)
+
+func (o Order) String() string {
+	switch o {
+	case ABCD:
+		return "ABCD"
+	case CDAB:
+		return "CDAB"
+	case BADC:
+		return "BADC"
+	case DCBA:
+		return "DCBA"
+	default:
+		return fmt.Sprintf("Order(%d)", o)
+	}
+}
+
+// index returns where the j-th most significant byte of n registers value
+// is.
+func (o Order) index(j, n int) int {
+	w, k := j/2, j%2
+	if o == CDAB || o == DCBA {
+		w = n - 1 - w
+	}
+	if o == BADC || o == DCBA {
+		k = 1 - k
+	}
+	return w*2 + k
+}
+
+// Regs is registers in Modbus byte order like ReadHRegsCmd.Bytes, i is the
+// register index of the value.
+type Regs []byte
+
+// Len is the number of registers.
+func (r Regs) Len() int {
+	return len(r) / 2
+}
+
+func (r Regs) value(i, n int, o Order) []byte {
+	if o > DCBA {
+		panic(fmt.Sprintf("invalid order: %d", o))
+	}
+	if i < 0 || i+n > r.Len() {
+		panic(fmt.Sprintf("invalid i: %d", i))
+	}
+	return r[i*2 : (i+n)*2]
+}
+
+func (r Regs) uint(i, n int, o Order) uint64 {
+	b := r.value(i, n, o)
+	var v uint64
+	for j := range b {
+		v = v<<8 | uint64(b[o.index(j, n)])
+	}
+	return v
+}
+
+func (r Regs) put(i, n int, o Order, v uint64) {
+	b := r.value(i, n, o)
+	for j := len(b) - 1; j >= 0; j-- {
+		b[o.index(j, n)] = byte(v)
+		v >>= 8
+	}
+}
+
+func (r Regs) Uint16(i int, o Order) uint16 {
+	return uint16(r.uint(i, 1, o))
+}
+
+func (r Regs) Int16(i int, o Order) int16 {
+	return int16(r.uint(i, 1, o))
+}
+
+func (r Regs) Uint32(i int, o Order) uint32 {
+	return uint32(r.uint(i, 2, o))
+}
+
+func (r Regs) Int32(i int, o Order) int32 {
+	return int32(r.uint(i, 2, o))
+}
+
+func (r Regs) Uint64(i int, o Order) uint64 {
+	return r.uint(i, 4, o)
+}
+
+func (r Regs) Int64(i int, o Order) int64 {
+	return int64(r.uint(i, 4, o))
+}
+
+func (r Regs) Float32(i int, o Order) float32 {
+	return math.Float32frombits(uint32(r.uint(i, 2, o)))
+}
+
+func (r Regs) Float64(i int, o Order) float64 {
+	return math.Float64frombits(r.uint(i, 4, o))
+}
+
+func (r Regs) PutUint16(i int, v uint16, o Order) {
+	r.put(i, 1, o, uint64(v))
+}
+
+func (r Regs) PutInt16(i int, v int16, o Order) {
+	r.put(i, 1, o, uint64(uint16(v)))
+}
+
+func (r Regs) PutUint32(i int, v uint32, o Order) {
+	r.put(i, 2, o, uint64(v))
+}
+
+func (r Regs) PutInt32(i int, v int32, o Order) {
+	r.put(i, 2, o, uint64(uint32(v)))
+}
+
+func (r Regs) PutUint64(i int, v uint64, o Order) {
+	r.put(i, 4, o, v)
+}
+
+func (r Regs) PutInt64(i int, v int64, o Order) {
+	r.put(i, 4, o, uint64(v))
+}
+
+func (r Regs) PutFloat32(i int, v float32, o Order) {
+	r.put(i, 2, o, uint64(math.Float32bits(v)))
+}
+
+func (r Regs) PutFloat64(i int, v float64, o Order) {
+	r.put(i, 4, o, math.Float64bits(v))
+}
+
+// Text returns n registers as string of 2 chars per register, cut at the
+// first NUL and trailing spaces trimmed. o only swaps what 2n bytes value
+// would swap, mostly it is ABCD or BADC.
+func (r Regs) Text(i, n int, o Order) string {
+	b := r.value(i, n, o)
+	s := make([]byte, 0, len(b))
+	for j := range b {
+		c := b[o.index(j, n)]
+		if c == 0 {
+			break
+		}
+		s = append(s, c)
+	}
+	for len(s) > 0 && s[len(s)-1] == ' ' {
+		s = s[:len(s)-1]
+	}
+	return string(s)
+}
+
+// PutText writes s into n registers padded with NUL.
+func (r Regs) PutText(i, n int, s string, o Order) {
+	b := r.value(i, n, o)
+	if len(s) > len(b) {
+		panic("text too long")
+	}
+	for j := range b {
+		var c byte
+		if j < len(s) {
+			c = s[j]
+		}
+		b[o.index(j, n)] = c
+	}
+}
+
+// BCD16 returns register i as 4 BCD digits, false if any digit is invalid.
+func (r Regs) BCD16(i int, o Order) (uint16, bool) {
+	v, ok := fromBCD(r.uint(i, 1, o), 4)
+	return uint16(v), ok
+}
+
+// BCD32 returns registers i and i+1 as 8 BCD digits, false if any digit is
+// invalid.
+func (r Regs) BCD32(i int, o Order) (uint32, bool) {
+	v, ok := fromBCD(r.uint(i, 2, o), 8)
+	return uint32(v), ok
+}
+
+func (r Regs) PutBCD16(i int, v uint16, o Order) {
+	if v > 9999 {
+		panic(fmt.Sprintf("invalid BCD: %d", v))
+	}
+	r.put(i, 1, o, toBCD(uint64(v)))
+}
+
+func (r Regs) PutBCD32(i int, v uint32, o Order) {
+	if v > 99999999 {
+		panic(fmt.Sprintf("invalid BCD: %d", v))
+	}
+	r.put(i, 2, o, toBCD(uint64(v)))
+}
+
+func fromBCD(b uint64, n int) (uint64, bool) {
+	var v uint64
+	for j := n - 1; j >= 0; j-- {
+		d := b >> (j * 4) & 0xF
+		if d > 9 {
+			return 0, false
+		}
+		v = v*10 + d
+	}
+	return v, true
+}
+
+func toBCD(v uint64) uint64 {
+	var b uint64
+	for j := 0; v > 0; j += 4 {
+		b |= v % 10 << j
+		v /= 10
+	}
+	return b
+}
+
+// Field is a named bit field of a register, Shift is the position of its
+// least significant bit.
+type Field struct {
+	Name  string
+	Shift uint8
+	Width uint8
+}
+
+func (f Field) mask() uint16 {
+	if f.Width == 0 || int(f.Shift)+int(f.Width) > 16 {
+		panic("invalid field: " + f.Name)
+	}
+	return uint16(1<<f.Width-1) << f.Shift
+}
+
+// Get returns the field of register value v.
+func (f Field) Get(v uint16) uint16 {
+	return v & f.mask() >> f.Shift
+}
+
+// Set returns register value v with the field replaced by x.
+func (f Field) Set(v, x uint16) uint16 {
+	m := f.mask()
+	if x > m>>f.Shift {
+		panic(fmt.Sprintf("invalid %s: %d", f.Name, x))
+	}
+	return v&^m | x<<f.Shift
+}
+
+func (r Regs) Field(i int, f Field) uint16 {
+	return f.Get(r.Uint16(i, ABCD))
+}
+
+// Fields returns the named fields of register i.
+func (r Regs) Fields(i int, fs []Field) map[string]uint16 {
+	v := r.Uint16(i, ABCD)
+	m := make(map[string]uint16, len(fs))
+	for _, f := range fs {
+		m[f.Name] = f.Get(v)
+	}
+	return m
+}
+
+func (r Regs) PutField(i int, f Field, x uint16) {
+	r.PutUint16(i, f.Set(r.Uint16(i, ABCD), x), ABCD)
+}
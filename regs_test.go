@@ -0,0 +1,280 @@
+package modbus_test
+
+import (
+	"math"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bangzek/modbus-tcp"
+)
+
+var _ = DescribeTable("Order",
+	func(o Order, s string) {
+		Expect(o.String()).To(Equal(s))
+	},
+	Entry(nil, ABCD, "ABCD"),
+	Entry(nil, CDAB, "CDAB"),
+	Entry(nil, BADC, "BADC"),
+	Entry(nil, DCBA, "DCBA"),
+	Entry(nil, Order(4), "Order(4)"),
+)
+
+var _ = Describe("Regs", func() {
+	DescribeTable("16 bits",
+		func(o Order, b []byte) {
+			r := Regs{0xFF, 0xFF, 0xFF, 0xFF}
+			r.PutUint16(1, 0xFE01, o)
+			Expect(r).To(Equal(Regs(b)))
+			Expect(r.Uint16(1, o)).To(Equal(uint16(0xFE01)))
+			Expect(r.Int16(1, o)).To(Equal(int16(-511)))
+			r.PutInt16(0, -2, o)
+			Expect(r.Int16(0, o)).To(Equal(int16(-2)))
+		},
+		Entry("ABCD", ABCD, []byte{0xFF, 0xFF, 0xFE, 0x01}),
+		Entry("CDAB", CDAB, []byte{0xFF, 0xFF, 0xFE, 0x01}),
+		Entry("BADC", BADC, []byte{0xFF, 0xFF, 0x01, 0xFE}),
+		Entry("DCBA", DCBA, []byte{0xFF, 0xFF, 0x01, 0xFE}),
+	)
+
+	DescribeTable("32 bits",
+		func(o Order, b []byte) {
+			r := make(Regs, 4)
+			r.PutUint32(0, 0x01020304, o)
+			Expect(r).To(Equal(Regs(b)))
+			Expect(r.Uint32(0, o)).To(Equal(uint32(0x01020304)))
+			Expect(r.Int32(0, o)).To(Equal(int32(0x01020304)))
+			r.PutInt32(0, -3, o)
+			Expect(r.Int32(0, o)).To(Equal(int32(-3)))
+			r.PutFloat32(0, 1.5, o)
+			Expect(r.Float32(0, o)).To(Equal(float32(1.5)))
+		},
+		Entry("ABCD", ABCD, []byte{1, 2, 3, 4}),
+		Entry("CDAB", CDAB, []byte{3, 4, 1, 2}),
+		Entry("BADC", BADC, []byte{2, 1, 4, 3}),
+		Entry("DCBA", DCBA, []byte{4, 3, 2, 1}),
+	)
+
+	DescribeTable("float32",
+		func(o Order, b []byte) {
+			Expect(Regs(b).Float32(0, o)).To(Equal(float32(1.5)))
+		},
+		Entry("ABCD", ABCD, []byte{0x3F, 0xC0, 0, 0}),
+		Entry("CDAB", CDAB, []byte{0, 0, 0x3F, 0xC0}),
+		Entry("BADC", BADC, []byte{0xC0, 0x3F, 0, 0}),
+		Entry("DCBA", DCBA, []byte{0, 0, 0xC0, 0x3F}),
+	)
+
+	DescribeTable("64 bits",
+		func(o Order, b []byte) {
+			r := make(Regs, 10)
+			r.PutUint64(1, 0x0102030405060708, o)
+			Expect(r[2:]).To(Equal(Regs(b)))
+			Expect(r.Uint64(1, o)).To(Equal(uint64(0x0102030405060708)))
+			Expect(r.Int64(1, o)).To(Equal(int64(0x0102030405060708)))
+			r.PutInt64(1, -4, o)
+			Expect(r.Int64(1, o)).To(Equal(int64(-4)))
+			r.PutFloat64(1, -0.25, o)
+			Expect(r.Float64(1, o)).To(Equal(-0.25))
+		},
+		Entry("ABCD", ABCD, []byte{1, 2, 3, 4, 5, 6, 7, 8}),
+		Entry("CDAB", CDAB, []byte{7, 8, 5, 6, 3, 4, 1, 2}),
+		Entry("BADC", BADC, []byte{2, 1, 4, 3, 6, 5, 8, 7}),
+		Entry("DCBA", DCBA, []byte{8, 7, 6, 5, 4, 3, 2, 1}),
+	)
+
+	DescribeTable("Text",
+		func(o Order, b []byte, x string) {
+			Expect(Regs(b).Text(0, 3, o)).To(Equal(x))
+			r := make(Regs, 6)
+			r.PutText(0, 3, x, o)
+			Expect(r.Text(0, 3, o)).To(Equal(x))
+		},
+		Entry("ABCD", ABCD, []byte("SN-01\x00"), "SN-01"),
+		Entry("ABCD full", ABCD, []byte("SN-012"), "SN-012"),
+		Entry("ABCD spaces", ABCD, []byte("AB    "), "AB"),
+		Entry("ABCD NUL", ABCD, []byte("A\x00B\x00\x00\x00"), "A"),
+		Entry("BADC", BADC, []byte("NS0-\x001"), "SN-01"),
+		Entry("empty", ABCD, []byte{0, 0, 0, 0, 0, 0}, ""),
+	)
+
+	It("pads Text", func() {
+		r := Regs("xxxxxxxx")
+		r.PutText(1, 2, "ABC", BADC)
+		Expect(r).To(Equal(Regs("xxBA\x00Cxx")))
+	})
+	It("can't write long Text", func() {
+		Expect(func() {
+			make(Regs, 4).PutText(0, 2, "ABCDE", ABCD)
+		}).Should(PanicWith("text too long"))
+	})
+
+	DescribeTable("BCD",
+		func(o Order, b []byte, x uint32) {
+			v, ok := Regs(b).BCD32(0, o)
+			Expect(ok).To(BeTrue())
+			Expect(v).To(Equal(x))
+			r := make(Regs, 4)
+			r.PutBCD32(0, x, o)
+			Expect(r).To(Equal(Regs(b)))
+		},
+		Entry("ABCD", ABCD, []byte{0x12, 0x34, 0x56, 0x78}, uint32(12345678)),
+		Entry("CDAB", CDAB, []byte{0x56, 0x78, 0x12, 0x34}, uint32(12345678)),
+		Entry("zero", ABCD, []byte{0, 0, 0, 0}, uint32(0)),
+		Entry("max", DCBA, []byte{0x99, 0x99, 0x99, 0x99}, uint32(99999999)),
+	)
+
+	It("has BCD16", func() {
+		r := Regs{0x01, 0x02, 0x1A, 0x00}
+		v, ok := r.BCD16(0, ABCD)
+		Expect(ok).To(BeTrue())
+		Expect(v).To(Equal(uint16(102)))
+		v, ok = r.BCD16(0, BADC)
+		Expect(ok).To(BeTrue())
+		Expect(v).To(Equal(uint16(201)))
+		_, ok = r.BCD16(1, ABCD)
+		Expect(ok).To(BeFalse())
+		r.PutBCD16(1, 9870, ABCD)
+		Expect(r[2:]).To(Equal(Regs{0x98, 0x70}))
+	})
+	It("has invalid BCD32", func() {
+		_, ok := Regs{0, 0, 0, 0xF0}.BCD32(0, ABCD)
+		Expect(ok).To(BeFalse())
+	})
+	It("can't write big BCD", func() {
+		Expect(func() {
+			make(Regs, 2).PutBCD16(0, 10000, ABCD)
+		}).Should(PanicWith("invalid BCD: 10000"))
+		Expect(func() {
+			make(Regs, 4).PutBCD32(0, 100000000, ABCD)
+		}).Should(PanicWith("invalid BCD: 100000000"))
+	})
+
+	Describe("Field", func() {
+		run := Field{"run", 0, 1}
+		mode := Field{"mode", 4, 3}
+		code := Field{"code", 8, 8}
+
+		It("gets", func() {
+			Expect(run.Get(0x1235)).To(Equal(uint16(1)))
+			Expect(mode.Get(0x12F5)).To(Equal(uint16(7)))
+			Expect(code.Get(0x12F5)).To(Equal(uint16(0x12)))
+		})
+		It("sets", func() {
+			Expect(run.Set(0x1235, 0)).To(Equal(uint16(0x1234)))
+			Expect(mode.Set(0x12F5, 2)).To(Equal(uint16(0x12A5)))
+			Expect(code.Set(0x12F5, 0xAB)).To(Equal(uint16(0xABF5)))
+		})
+		It("has Regs Fields", func() {
+			r := Regs{0, 0, 0x12, 0x35}
+			Expect(r.Field(1, mode)).To(Equal(uint16(3)))
+			Expect(r.Fields(1, []Field{run, mode, code})).To(Equal(
+				map[string]uint16{"run": 1, "mode": 3, "code": 0x12}))
+			r.PutField(1, code, 0xFF)
+			r.PutField(0, run, 1)
+			Expect(r).To(Equal(Regs{0, 1, 0xFF, 0x35}))
+		})
+		It("can't set too big", func() {
+			Expect(func() {
+				mode.Set(0, 8)
+			}).Should(PanicWith("invalid mode: 8"))
+		})
+		It("can't be empty", func() {
+			Expect(func() {
+				Field{"x", 3, 0}.Get(0)
+			}).Should(PanicWith("invalid field: x"))
+		})
+		It("can't be beyond register", func() {
+			Expect(func() {
+				Field{"y", 12, 5}.Get(0)
+			}).Should(PanicWith("invalid field: y"))
+		})
+	})
+
+	It("has Len", func() {
+		Expect(make(Regs, 6).Len()).To(Equal(3))
+	})
+	It("can't read beyond", func() {
+		Expect(func() {
+			make(Regs, 6).Uint32(2, ABCD)
+		}).Should(PanicWith("invalid i: 2"))
+	})
+	It("can't write negative", func() {
+		Expect(func() {
+			make(Regs, 6).PutUint16(-1, 0, ABCD)
+		}).Should(PanicWith("invalid i: -1"))
+	})
+	It("can't use invalid order", func() {
+		Expect(func() {
+			make(Regs, 6).Float32(0, Order(4))
+		}).Should(PanicWith("invalid order: 4"))
+	})
+})
+
+var _ = Describe("Typed Regs", func() {
+	rx := []byte{0, 0, 0, 0, 0, 17, 1, 3, 14,
+		0xFF, 0xFE, 0, 0, 0x3F, 0xC0,
+		0, 0, 0, 0, 0, 0, 0x01, 0x23}
+
+	It("decodes ReadHRegsCmd", func() {
+		cmd := NewReadHRegsCmd(1, 0, 7)
+		r := cmd.RxBytes()
+		*r = append((*r)[:0], rx...)
+		Expect(cmd.IsValidRx()).To(BeTrue())
+		Expect(cmd.Regs().Len()).To(Equal(7))
+		Expect(cmd.Uint16(0, ABCD)).To(Equal(uint16(0xFFFE)))
+		Expect(cmd.Int16(0, ABCD)).To(Equal(int16(-2)))
+		Expect(cmd.Uint16(0, BADC)).To(Equal(uint16(0xFEFF)))
+		Expect(cmd.Float32(1, CDAB)).To(Equal(float32(1.5)))
+		Expect(cmd.Uint32(1, ABCD)).To(Equal(uint32(0x3FC0)))
+		Expect(cmd.Int32(0, ABCD)).To(Equal(int32(-131072)))
+		Expect(cmd.Uint64(3, ABCD)).To(Equal(uint64(0x0123)))
+		Expect(cmd.Int64(3, DCBA)).To(Equal(int64(0x2301) << 48))
+		Expect(cmd.Float64(3, ABCD)).To(Equal(math.Float64frombits(0x0123)))
+	})
+
+	It("decodes ReadIRegsCmd", func() {
+		cmd := NewReadIRegsCmd(1, 0, 7)
+		r := cmd.RxBytes()
+		*r = append((*r)[:0], rx...)
+		(*r)[7] = 4
+		Expect(cmd.IsValidRx()).To(BeTrue())
+		Expect(cmd.Regs().Len()).To(Equal(7))
+		Expect(cmd.Uint16(0, ABCD)).To(Equal(uint16(0xFFFE)))
+		Expect(cmd.Int16(0, ABCD)).To(Equal(int16(-2)))
+		Expect(cmd.Float32(1, CDAB)).To(Equal(float32(1.5)))
+		Expect(cmd.Uint32(1, BADC)).To(Equal(uint32(0xC03F)))
+		Expect(cmd.Int32(0, CDAB)).To(Equal(int32(0xFFFE)))
+		Expect(cmd.Uint64(3, ABCD)).To(Equal(uint64(0x0123)))
+		Expect(cmd.Int64(3, ABCD)).To(Equal(int64(0x0123)))
+		Expect(cmd.Float64(3, ABCD)).To(Equal(math.Float64frombits(0x0123)))
+	})
+
+	It("encodes WriteRegsCmd", func() {
+		cmd := NewWriteRegsCmd(1, 0, make([]uint16, 13))
+		cmd.SetUint16(0, 0xFFFE, BADC)
+		cmd.SetInt16(1, -2, ABCD)
+		cmd.SetFloat32(2, 1.5, CDAB)
+		cmd.SetUint32(4, 0x01020304, DCBA)
+		cmd.SetInt32(6, -2, CDAB)
+		cmd.SetUint64(8, 0x0102030405060708, CDAB)
+		cmd.SetInt64(8, -1, ABCD)
+		cmd.SetFloat64(9, 0, ABCD)
+		Expect(cmd.Bytes()).To(Equal([]byte{
+			0xFE, 0xFF, 0xFF, 0xFE, 0, 0, 0x3F, 0xC0,
+			4, 3, 2, 1, 0xFF, 0xFE, 0xFF, 0xFF,
+			0xFF, 0xFF, 0, 0, 0, 0, 0, 0, 0, 0,
+		}))
+		Expect(cmd.Regs().Float32(2, CDAB)).To(Equal(float32(1.5)))
+	})
+	It("encodes with ModifyBytes", func() {
+		cmd := NewWriteRegsCmd(1, 0, make([]uint16, 4))
+		cmd.ModifyBytes(func(b []byte) {
+			Regs(b).PutText(0, 2, "V1", ABCD)
+			Regs(b).PutBCD16(2, 1203, ABCD)
+			Regs(b).PutField(3, Field{"mode", 4, 4}, 9)
+		})
+		Expect(cmd.Bytes()).
+			To(Equal([]byte{'V', '1', 0, 0, 0x12, 0x03, 0, 0x90}))
+	})
+})
@@ -0,0 +1,146 @@
+package modbus
+
+import (
+	"io"
+	"time"
+)
+
+const (
+	SILENCE = 20 * time.Millisecond
+
+	// RTU frame: unit id 1, PDU 253, CRC 2
+	maxRTULen = 256
+)
+
+// RTUFraming sends Cmd as RTU frame (unit id, PDU, CRC16) over the connection,
+// it's used by most serial to Ethernet converter. The response length is
+// known from its function code or RawCmd.SetRxLen, unknown one ends after
+// Silence without any byte.
+type RTUFraming struct {
+	Silence time.Duration
+
+	buf [maxRTULen]byte
+}
+
+func (f *RTUFraming) WriteFrame(conn Conn, tx []byte) error {
+	b := appendCRC(append(f.buf[:0], tx[6:]...))
+	if n, err := conn.Write(b); err != nil {
+		return err
+	} else if n != len(b) {
+		return io.ErrShortWrite
+	}
+	return nil
+}
+
+func (f *RTUFraming) ReadFrame(conn Conn, tx []byte, rx *[]byte) error {
+	b := f.buf[:2]
+	if err := readFull(conn, b); err != nil {
+		return err
+	}
+
+	n := rtuLen(b[1])
+	if b[1] == 8 {
+		// diagnostics response is as long as its request
+		n = len(tx) - 6 + 2
+	} else if n == 0 && cap(*rx) > 9 && cap(*rx) < maxADULen {
+		// unknown function code with known response length like RawCmd,
+		// 9 is the room of exception too so it's unknown
+		n = cap(*rx) - 6 + 2
+	}
+	if n < 0 {
+		b = f.buf[:2-n]
+		if err := readFull(conn, b[2:]); err != nil {
+			return err
+		}
+		c := 0
+		for _, x := range b[2:] {
+			c = c<<8 | int(x)
+		}
+		n = len(b) + c + 2
+		if n > maxRTULen {
+			return BadRxErr(append([]byte(nil), b...))
+		}
+	}
+	if n > 0 {
+		if err := readFull(conn, f.buf[len(b):n]); err != nil {
+			return err
+		}
+		b = f.buf[:n]
+	} else {
+		var err error
+		if b, err = f.readSilence(conn); err != nil {
+			return err
+		}
+	}
+
+	if len(b) < 4 || crc16(b[:len(b)-2]) !=
+		uint16(b[len(b)-1])<<8|uint16(b[len(b)-2]) {
+		return BadRxErr(append([]byte(nil), b...))
+	}
+	putMBAP(rx, tx, b[:len(b)-2])
+	return nil
+}
+
+// readSilence reads after function code until Silence without any byte.
+func (f *RTUFraming) readSilence(conn Conn) ([]byte, error) {
+	s := f.Silence
+	if s <= 0 {
+		s = SILENCE
+	}
+	n := 2
+	for n < len(f.buf) {
+		if err := conn.SetReadDeadline(ctime.Now().Add(s)); err != nil {
+			return nil, err
+		}
+		m, err := conn.Read(f.buf[n:])
+		n += m
+		if isTimeout(err) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return f.buf[:n], nil
+}
+
+// rtuLen returns the frame length of function code fc, negative is the size
+// of byte count field following it and zero means unknown.
+func rtuLen(fc byte) int {
+	if fc&0x80 != 0 {
+		return 5
+	}
+	switch fc {
+	case 7:
+		return 5
+	case 5, 6, 11, 15, 16:
+		return 8
+	case 22:
+		return 10
+	case 1, 2, 3, 4, 12, 17, 20, 21, 23:
+		return -1
+	case 24:
+		return -2
+	default:
+		return 0
+	}
+}
+
+func appendCRC(b []byte) []byte {
+	x := crc16(b)
+	return append(b, byte(x), byte(x>>8))
+}
+
+func crc16(b []byte) uint16 {
+	x := uint16(0xFFFF)
+	for _, c := range b {
+		x ^= uint16(c)
+		for i := 0; i < 8; i++ {
+			if x&1 != 0 {
+				x = x>>1 ^ 0xA001
+			} else {
+				x >>= 1
+			}
+		}
+	}
+	return x
+}
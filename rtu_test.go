@@ -0,0 +1,203 @@
+package modbus_test
+
+import (
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/bangzek/clock"
+	. "github.com/bangzek/modbus-tcp"
+)
+
+var _ = Describe("RTUFraming", func() {
+	var t time.Time
+	var mc *clock.Mock
+	BeforeEach(func() {
+		t = time.Date(2024, time.March, 2, 10, 11, 12, 0, time.UTC)
+		mc = new(clock.Mock)
+		mc.NowScripts = []time.Duration{0, 0, 0, 0}
+		SetClock(mc)
+		mc.Start(t)
+		NewLog()
+	})
+	AfterEach(func() {
+		mc.Stop()
+	})
+
+	send := func(cmd Cmd, conn *MockConn) error {
+		con := &Controller{
+			Dialer: &MockDialer{
+				Dials: []DialScript{
+					{conn, TIMEOUT, 0, 7, nil},
+				},
+			},
+			Framing: &RTUFraming{},
+		}
+		return con.Send(cmd)
+	}
+
+	It("reads registers", func() {
+		cmd := NewReadHRegsCmd(1, 0, 2)
+		conn := &MockConn{
+			Writes: []WriteScript{
+				{8, nil},
+			},
+			Reads: []ReadScript{
+				{[]byte{0x01, 0x03, 0x04, 0x00, 0x01, 0x00, 0x02, 0x2A, 0x32},
+					nil},
+			},
+		}
+		Expect(send(cmd, conn)).To(Succeed())
+		Expect(cmd.Reg(0)).To(Equal(uint16(1)))
+		Expect(cmd.Reg(1)).To(Equal(uint16(2)))
+		Expect(cmd.Rx()).To(Equal("0007 1->RHR 2[    1     2]"))
+		Expect(conn.Calls).To(Equal([]string{
+			"SWD 2024-03-02T10:11:15.001Z",
+			"WRITE [01 03 00 00 00 02 C4 0B]",
+			"SRD 2024-03-02T10:11:15.002Z",
+			"READ",
+			"READ",
+			"READ",
+		}))
+	})
+
+	It("writes register", func() {
+		cmd := NewWriteRegCmd(1, 5, 0x1234)
+		conn := &MockConn{
+			Writes: []WriteScript{
+				{8, nil},
+			},
+			Reads: []ReadScript{
+				{[]byte{0x01, 0x06, 0x00, 0x05, 0x12, 0x34, 0x94, 0xBC}, nil},
+			},
+		}
+		Expect(send(cmd, conn)).To(Succeed())
+		Expect(conn.Calls).To(ContainElement("WRITE [01 06 00 05 12 34 94 BC]"))
+	})
+
+	It("reads diagnostics as long as request", func() {
+		cmd := NewQueryDataCmd(1, []byte{0xA5, 0x37, 0x42})
+		conn := &MockConn{
+			Writes: []WriteScript{
+				{9, nil},
+			},
+			Reads: []ReadScript{
+				{[]byte{0x01, 0x08, 0x00, 0x00, 0xA5, 0x37, 0x42, 0x8C, 0xAA},
+					nil},
+			},
+		}
+		Expect(send(cmd, conn)).To(Succeed())
+		Expect(cmd.RxData()).To(Equal([]byte{0xA5, 0x37, 0x42}))
+	})
+
+	It("reads exception", func() {
+		cmd := NewReadHRegsCmd(1, 0, 2)
+		conn := &MockConn{
+			Writes: []WriteScript{
+				{8, nil},
+			},
+			Reads: []ReadScript{
+				{[]byte{0x01, 0x83, 0x02, 0xC0, 0xF1}, nil},
+			},
+		}
+		Expect(send(cmd, conn)).To(MatchError(IllegalDataAddress))
+	})
+
+	It("rejects bad CRC", func() {
+		cmd := NewReadHRegsCmd(1, 0, 2)
+		conn := &MockConn{
+			Writes: []WriteScript{
+				{8, nil},
+			},
+			Reads: []ReadScript{
+				{[]byte{0x01, 0x03, 0x04, 0x00, 0x01, 0x00, 0x02, 0x2A, 0x33},
+					nil},
+			},
+		}
+		Expect(send(cmd, conn)).To(MatchError(
+			"invalid response: [01 03 04 00 01 00 02 2A 33]"))
+		Expect(conn.Calls).To(ContainElement("CLOSE"))
+	})
+
+	It("ends unknown function after silence", func() {
+		f := &RTUFraming{Silence: time.Second}
+		tx := []byte{0, 5, 0, 0, 0, 5, 0x01, 0x2B, 0x0E, 0x01, 0x01}
+		rx := make([]byte, 0, 260)
+		conn := &MockConn{
+			Reads: []ReadScript{
+				{[]byte{0x01, 0x2B, 0x0E, 0x01, 0x01, 0x00}, nil},
+				{[]byte{0x00, 0x01, 0x00, 0x03, 0x41, 0x42, 0x43, 0x2D, 0x63},
+					nil},
+				{nil, os.ErrDeadlineExceeded},
+			},
+		}
+		Expect(f.WriteFrame(conn, tx)).To(MatchError("short write"))
+		Expect(f.ReadFrame(conn, tx, &rx)).To(Succeed())
+		Expect(rx).To(Equal([]byte{0, 5, 0, 0, 0, 13, 0x01, 0x2B, 0x0E,
+			0x01, 0x01, 0x00, 0x00, 0x01, 0x00, 0x03, 0x41, 0x42, 0x43}))
+		Expect(conn.Calls).To(Equal([]string{
+			"WRITE [01 2B 0E 01 01 B1 B7]",
+			"READ",
+			"SRD 2024-03-02T10:11:13.001Z",
+			"READ",
+			"SRD 2024-03-02T10:11:13.002Z",
+			"READ",
+			"SRD 2024-03-02T10:11:13.003Z",
+			"READ",
+		}))
+	})
+
+	It("reads known length of unknown function", func() {
+		cmd := NewRawCmd(1, 65, []byte{0xAA})
+		cmd.SetRxLen(2)
+		conn := &MockConn{
+			Writes: []WriteScript{
+				{5, nil},
+			},
+			Reads: []ReadScript{
+				{[]byte{0x01, 0x41, 0x12, 0x34, 0x5C, 0xBB}, nil},
+			},
+		}
+		Expect(send(cmd, conn)).To(Succeed())
+		Expect(cmd.RxData()).To(Equal([]byte{0x12, 0x34}))
+		Expect(conn.Calls).To(Equal([]string{
+			"SWD 2024-03-02T10:11:15.001Z",
+			"WRITE [01 41 AA 90 2F]",
+			"SRD 2024-03-02T10:11:15.002Z",
+			"READ",
+			"READ",
+		}))
+	})
+
+	It("reads exception of no data function", func() {
+		cmd := NewRawCmd(1, 65, nil)
+		cmd.SetRxLen(0)
+		conn := &MockConn{
+			Writes: []WriteScript{
+				{4, nil},
+			},
+			Reads: []ReadScript{
+				{[]byte{0x01, 0xC1, 0x01, 0xB0, 0x50}, nil},
+			},
+		}
+		Expect(send(cmd, conn)).To(MatchError(IllegalFunction))
+	})
+
+	It("ends no data function after silence", func() {
+		cmd := NewRawCmd(1, 65, nil)
+		cmd.SetRxLen(0)
+		conn := &MockConn{
+			Writes: []WriteScript{
+				{4, nil},
+			},
+			Reads: []ReadScript{
+				{[]byte{0x01, 0x41, 0xC0, 0x10}, nil},
+				{nil, os.ErrDeadlineExceeded},
+			},
+		}
+		Expect(send(cmd, conn)).To(Succeed())
+		Expect(cmd.RxData()).To(BeEmpty())
+	})
+})
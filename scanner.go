@@ -1,17 +1,26 @@
 package modbus
 
 import (
+	"context"
 	"sync"
 )
 
 type CmdReq struct {
 	Cmd Cmd
 	Err chan<- error
+	// Ctx is used to send Cmd when the Controller is ContextController,
+	// nil means the Scanner context.
+	Ctx context.Context
 }
 
 func NewCmdReq(cmd Cmd) (CmdReq, <-chan error) {
 	ch := make(chan error)
-	return CmdReq{cmd, ch}, ch
+	return CmdReq{Cmd: cmd, Err: ch}, ch
+}
+
+func NewCmdReqContext(ctx context.Context, cmd Cmd) (CmdReq, <-chan error) {
+	ch := make(chan error)
+	return CmdReq{Cmd: cmd, Err: ch, Ctx: ctx}, ch
 }
 
 type IController interface {
@@ -19,6 +28,11 @@ type IController interface {
 	Send(Cmd) error
 }
 
+type ContextController interface {
+	IController
+	SendContext(context.Context, Cmd) error
+}
+
 type SubScanner interface {
 	Run(stop <-chan struct{}) <-chan CmdReq
 }
@@ -27,7 +41,15 @@ type Scanner struct {
 	Controller IController
 	Subs       []SubScanner
 
-	ch <-chan CmdReq
+	ch  <-chan CmdReq
+	ctx context.Context
+}
+
+// RunContext is Run that stops when ctx is done, ctx is also used to send
+// CmdReq without Ctx.
+func (s *Scanner) RunContext(ctx context.Context) {
+	s.ctx = ctx
+	s.Run(ctx.Done())
 }
 
 func (s *Scanner) Run(stop <-chan struct{}) {
@@ -62,7 +84,16 @@ func (s *Scanner) run() {
 	defer logPanic()
 	defer s.Controller.Close()
 
+	cc, _ := s.Controller.(ContextController)
 	for req := range s.ch {
-		req.Err <- s.Controller.Send(req.Cmd)
+		ctx := req.Ctx
+		if ctx == nil {
+			ctx = s.ctx
+		}
+		if cc != nil && ctx != nil {
+			req.Err <- cc.SendContext(ctx, req.Cmd)
+		} else {
+			req.Err <- s.Controller.Send(req.Cmd)
+		}
 	}
 }
@@ -0,0 +1,75 @@
+package modbus_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bangzek/modbus-tcp"
+)
+
+var _ = Describe("Scanner", func() {
+	It("sends with request or scanner ctx", func() {
+		type key struct{}
+		ctx, cancel := context.WithCancel(
+			context.WithValue(context.Background(), key{}, "scanner"))
+		rctx := context.WithValue(context.Background(), key{}, "request")
+		con := &MockContextController{
+			Key:    key{},
+			Closed: make(chan struct{}),
+		}
+		sub := &MockSub{}
+		s := &Scanner{Controller: con, Subs: []SubScanner{sub}}
+		s.RunContext(ctx)
+
+		req, ch := NewCmdReq(NewReadCoilsCmd(1, 0, 1))
+		sub.Ch <- req
+		Expect(<-ch).To(Succeed())
+		req, ch = NewCmdReqContext(rctx, NewReadCoilsCmd(1, 0, 1))
+		sub.Ch <- req
+		Expect(<-ch).To(Succeed())
+		cancel()
+		Eventually(sub.Stopped).Should(BeClosed())
+		close(sub.Ch)
+		Eventually(con.Closed).Should(BeClosed())
+		Expect(con.Calls).To(Equal([]string{"scanner", "request"}))
+	})
+})
+
+type MockSub struct {
+	Ch      chan CmdReq
+	Stopped chan struct{}
+}
+
+func (m *MockSub) Run(stop <-chan struct{}) <-chan CmdReq {
+	m.Ch = make(chan CmdReq)
+	m.Stopped = make(chan struct{})
+	go func() {
+		<-stop
+		close(m.Stopped)
+	}()
+	return m.Ch
+}
+
+type MockContextController struct {
+	Key    any
+	Calls  []string
+	Closed chan struct{}
+}
+
+func (m *MockContextController) Close() {
+	close(m.Closed)
+}
+
+func (m *MockContextController) Send(cmd Cmd) error {
+	m.Calls = append(m.Calls, "background")
+	return nil
+}
+
+func (m *MockContextController) SendContext(
+	ctx context.Context, cmd Cmd,
+) error {
+	m.Calls = append(m.Calls, ctx.Value(m.Key).(string))
+	return nil
+}
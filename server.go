@@ -0,0 +1,405 @@
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+var ErrServerClosed = errors.New("server closed")
+
+// Handler serves requests received by Server. Returning a ModbusErr sends
+// that exception back, any other error is sent as SlaveDeviceFail. Reads
+// returning less than count values are sent as SlaveDeviceFail too.
+// Handler is called from multiple goroutines, one per connection.
+type Handler interface {
+	ReadCoils(devAddr byte, addr uint16, count uint16) ([]bool, error)
+	ReadDInputs(devAddr byte, addr uint16, count uint16) ([]bool, error)
+	ReadHRegs(devAddr byte, addr uint16, count uint16) ([]uint16, error)
+	ReadIRegs(devAddr byte, addr uint16, count uint16) ([]uint16, error)
+	WriteCoil(devAddr byte, addr uint16, val bool) error
+	WriteReg(devAddr byte, addr uint16, val uint16) error
+	WriteCoils(devAddr byte, addr uint16, values []bool) error
+	WriteRegs(devAddr byte, addr uint16, values []uint16) error
+}
+
+type Server struct {
+	Handler Handler
+	// Idle timeout of a connection, zero means no timeout.
+	Timeout time.Duration
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[Conn]struct{}
+	closed    bool
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	if addr == "" {
+		addr = ":502"
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+func (s *Server) Serve(l net.Listener) error {
+	if s.Handler == nil {
+		panic("nil Server.Handler")
+	}
+	if !s.track(l, nil) {
+		l.Close()
+		return ErrServerClosed
+	}
+	defer s.untrack(l, nil)
+
+	log("Serving %s", l.Addr())
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(WAIT)
+				continue
+			}
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn serves requests from conn until it is closed or broken.
+func (s *Server) ServeConn(conn Conn) {
+	defer logPanic()
+	if !s.track(nil, conn) {
+		conn.Close()
+		return
+	}
+	defer s.untrack(nil, conn)
+	defer conn.Close()
+
+	var rx [maxADULen]byte
+	var tx [maxADULen]byte
+	for {
+		if s.Timeout > 0 {
+			if err := conn.SetReadDeadline(
+				ctime.Now().Add(s.Timeout),
+			); err != nil {
+				return
+			}
+		} else if err := conn.SetReadDeadline(time.Time{}); err != nil {
+			return
+		}
+
+		req := rx[:mbapLen]
+		if err := readFull(conn, req); err != nil {
+			return
+		}
+		l := mbapLength(req)
+		if mbapProto(req) != 0 || l < 2 || l > maxADULen-6 {
+			debugLog("bad request: % X", req)
+			return
+		}
+		req = rx[:6+l]
+		if err := readFull(conn, req[mbapLen:]); err != nil {
+			return
+		}
+		debugLog("rx: % X", req)
+
+		res := s.handle(req, tx[:0])
+		if len(res) == 0 {
+			continue
+		}
+		if s.Timeout > 0 {
+			if err := conn.SetWriteDeadline(
+				ctime.Now().Add(s.Timeout),
+			); err != nil {
+				return
+			}
+		}
+		debugLog("tx: % X", res)
+		if _, err := conn.Write(res); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	var err error
+	for l := range s.listeners {
+		if e := l.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	return err
+}
+
+func (s *Server) track(l net.Listener, c Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	if l != nil {
+		if s.listeners == nil {
+			s.listeners = make(map[net.Listener]struct{})
+		}
+		s.listeners[l] = struct{}{}
+	}
+	if c != nil {
+		if s.conns == nil {
+			s.conns = make(map[Conn]struct{})
+		}
+		s.conns[c] = struct{}{}
+	}
+	return true
+}
+
+func (s *Server) untrack(l net.Listener, c Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if l != nil {
+		delete(s.listeners, l)
+	}
+	if c != nil {
+		delete(s.conns, c)
+	}
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// handle appends the response of req to res, returns empty for broadcast.
+func (s *Server) handle(req []byte, res []byte) []byte {
+	res = append(res, req[:8]...)
+	var err error
+	switch req[7] {
+	case 1:
+		c := ReadCoilsCmd{cmd{tx: req}}
+		if err = readBitsErr(req); err == nil {
+			var v []bool
+			v, err = s.Handler.ReadCoils(
+				c.DevAddr(), c.Addr(), uint16(c.Count()))
+			if err == nil {
+				res, err = appendBits(res, v, c.Count())
+			}
+		}
+	case 2:
+		c := ReadDInputsCmd{cmd{tx: req}}
+		if err = readBitsErr(req); err == nil {
+			var v []bool
+			v, err = s.Handler.ReadDInputs(
+				c.DevAddr(), c.Addr(), uint16(c.Count()))
+			if err == nil {
+				res, err = appendBits(res, v, c.Count())
+			}
+		}
+	case 3:
+		c := ReadHRegsCmd{cmd{tx: req}}
+		if err = readRegsErr(req); err == nil {
+			var v []uint16
+			v, err = s.Handler.ReadHRegs(
+				c.DevAddr(), c.Addr(), uint16(c.Count()))
+			if err == nil {
+				res, err = appendRegs(res, v, c.Count())
+			}
+		}
+	case 4:
+		c := ReadIRegsCmd{cmd{tx: req}}
+		if err = readRegsErr(req); err == nil {
+			var v []uint16
+			v, err = s.Handler.ReadIRegs(
+				c.DevAddr(), c.Addr(), uint16(c.Count()))
+			if err == nil {
+				res, err = appendRegs(res, v, c.Count())
+			}
+		}
+	case 5:
+		c := WriteCoilCmd{cmd{tx: req}}
+		if len(req) != 12 || req[11] != 0 ||
+			(req[10] != 0 && req[10] != 0xFF) {
+			err = IllegalDataValue
+		} else if err = s.Handler.WriteCoil(
+			c.DevAddr(), c.Addr(), c.Coil(),
+		); err == nil {
+			res = append(res, req[8:]...)
+		}
+	case 6:
+		c := WriteRegCmd{cmd{tx: req}}
+		if len(req) != 12 {
+			err = IllegalDataValue
+		} else if err = s.Handler.WriteReg(
+			c.DevAddr(), c.Addr(), c.Reg(),
+		); err == nil {
+			res = append(res, req[8:]...)
+		}
+	case 15:
+		c := WriteCoilsCmd{cmd{tx: req}}
+		if err = writeCoilsErr(req); err == nil {
+			v := make([]bool, c.Count())
+			for i := range v {
+				v[i] = c.Coil(i)
+			}
+			if err = s.Handler.WriteCoils(
+				c.DevAddr(), c.Addr(), v,
+			); err == nil {
+				res = append(res, req[8:12]...)
+			}
+		}
+	case 16:
+		c := WriteRegsCmd{cmd{tx: req}}
+		if err = writeRegsErr(req); err == nil {
+			v := make([]uint16, c.Count())
+			for i := range v {
+				v[i] = c.Reg(i)
+			}
+			if err = s.Handler.WriteRegs(
+				c.DevAddr(), c.Addr(), v,
+			); err == nil {
+				res = append(res, req[8:12]...)
+			}
+		}
+	default:
+		err = IllegalFunction
+	}
+
+	if req[6] == 0 {
+		// broadcast never get response
+		return res[:0]
+	}
+	if err != nil {
+		var me ModbusErr
+		if !errors.As(err, &me) {
+			errorLog("handling % X: %s", req, err)
+			me = SlaveDeviceFail
+		}
+		res = append(res[:8], byte(me))
+		res[7] |= 0x80
+	}
+	res[4] = byte((len(res) - 6) >> 8)
+	res[5] = byte(len(res) - 6)
+	return res
+}
+
+func readBitsErr(req []byte) error {
+	if req[6] == 0 {
+		return IllegalFunction
+	} else if len(req) != 12 {
+		return IllegalDataValue
+	}
+	c := ReadCoilsCmd{cmd{tx: req}}
+	n := uint16(c.Count())
+	if n == 0 || n > maxReadBits {
+		return IllegalDataValue
+	}
+	if c.Addr()+n-1 < c.Addr() {
+		return IllegalDataAddress
+	}
+	return nil
+}
+
+func readRegsErr(req []byte) error {
+	if req[6] == 0 {
+		return IllegalFunction
+	} else if len(req) != 12 || req[10] != 0 {
+		return IllegalDataValue
+	}
+	c := ReadHRegsCmd{cmd{tx: req}}
+	n := uint16(c.Count())
+	if n == 0 || n > maxReadRegs {
+		return IllegalDataValue
+	}
+	if c.Addr()+n-1 < c.Addr() {
+		return IllegalDataAddress
+	}
+	return nil
+}
+
+func writeCoilsErr(req []byte) error {
+	if len(req) < 14 {
+		return IllegalDataValue
+	}
+	c := WriteCoilsCmd{cmd{tx: req}}
+	n := uint16(c.Count())
+	if n == 0 || n > maxWriteBits ||
+		c.ByteCount() != int((n+7)/8) || len(req) != 13+c.ByteCount() {
+		return IllegalDataValue
+	}
+	if c.Addr()+n-1 < c.Addr() {
+		return IllegalDataAddress
+	}
+	return nil
+}
+
+func writeRegsErr(req []byte) error {
+	if len(req) < 15 || req[10] != 0 {
+		return IllegalDataValue
+	}
+	c := WriteRegsCmd{cmd{tx: req}}
+	n := uint16(c.Count())
+	if n == 0 || n > maxWriteRegs ||
+		c.ByteCount() != int(n*2) || len(req) != 13+c.ByteCount() {
+		return IllegalDataValue
+	}
+	if c.Addr()+n-1 < c.Addr() {
+		return IllegalDataAddress
+	}
+	return nil
+}
+
+// shortErr is the error of Handler returning less than n values, the rest
+// must not be made up.
+func shortErr(l, n int) error {
+	if l < n {
+		return fmt.Errorf("handler returns %d of %d values", l, n)
+	}
+	return nil
+}
+
+func appendBits(b []byte, v []bool, n int) ([]byte, error) {
+	if err := shortErr(len(v), n); err != nil {
+		return b, err
+	}
+	l := (n + 7) / 8
+	b = append(b, byte(l))
+	i := len(b)
+	for j := 0; j < l; j++ {
+		b = append(b, 0)
+	}
+	for j := 0; j < n; j++ {
+		if v[j] {
+			b[i+j/8] |= 1 << (j % 8)
+		}
+	}
+	return b, nil
+}
+
+func appendRegs(b []byte, v []uint16, n int) ([]byte, error) {
+	if err := shortErr(len(v), n); err != nil {
+		return b, err
+	}
+	b = append(b, byte(n*2))
+	for _, x := range v[:n] {
+		b = append(b, byte(x>>8), byte(x))
+	}
+	return b, nil
+}
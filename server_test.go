@@ -0,0 +1,255 @@
+package modbus_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bangzek/modbus-tcp"
+)
+
+var _ = Describe("Server", func() {
+	var handler *MockHandler
+	var srv *Server
+	var con *Controller
+	BeforeEach(func() {
+		ResetClock()
+		handler = &MockHandler{}
+		srv = &Server{Handler: handler}
+		con = &Controller{Dialer: &PipeDialer{Server: srv}}
+	})
+	AfterEach(func() {
+		con.Close()
+		srv.Close()
+	})
+
+	It("reads coils", func() {
+		handler.Bits = []bool{true, false, true, true, false, false, false,
+			false, true}
+		cmd := NewReadCoilsCmd(3, 2, 9)
+		Expect(con.Send(cmd)).To(Succeed())
+		Expect(cmd.Bytes()).To(Equal([]byte{0b1101, 1}))
+		Expect(handler.Calls).To(Equal([]string{"RC 3 2 9"}))
+	})
+
+	It("reads discrete inputs", func() {
+		handler.Bits = []bool{false, true}
+		cmd := NewReadDInputsCmd(4, 7, 2)
+		Expect(con.Send(cmd)).To(Succeed())
+		Expect(cmd.Input(0)).To(BeFalse())
+		Expect(cmd.Input(1)).To(BeTrue())
+		Expect(handler.Calls).To(Equal([]string{"RDI 4 7 2"}))
+	})
+
+	It("reads holding registers", func() {
+		handler.Regs = []uint16{1, 258, 65535}
+		cmd := NewReadHRegsCmd(1, 100, 3)
+		Expect(con.Send(cmd)).To(Succeed())
+		Expect(cmd.Bytes()).To(Equal([]byte{0, 1, 1, 2, 0xFF, 0xFF}))
+		Expect(handler.Calls).To(Equal([]string{"RHR 1 100 3"}))
+	})
+
+	It("reads input registers", func() {
+		handler.Regs = []uint16{1234}
+		cmd := NewReadIRegsCmd(1, 0, 1)
+		Expect(con.Send(cmd)).To(Succeed())
+		Expect(cmd.Reg(0)).To(Equal(uint16(1234)))
+		Expect(handler.Calls).To(Equal([]string{"RIR 1 0 1"}))
+	})
+
+	It("writes", func() {
+		Expect(con.Send(NewWriteCoilCmd(1, 5, true))).To(Succeed())
+		Expect(con.Send(NewWriteRegCmd(1, 6, 4321))).To(Succeed())
+		Expect(con.Send(NewWriteCoilsCmd(1, 7, []bool{true, false, true}))).
+			To(Succeed())
+		Expect(con.Send(NewWriteRegsCmd(1, 8, []uint16{1, 2}))).To(Succeed())
+		Expect(handler.Calls).To(Equal([]string{
+			"W1C 1 5 true",
+			"W1R 1 6 4321",
+			"WC 1 7 [true false true]",
+			"WR 1 8 [1 2]",
+		}))
+	})
+
+	It("doesn't respond to broadcast", func() {
+		Expect(con.Send(NewWriteRegCmd(0, 6, 4321))).To(Succeed())
+		Expect(con.Send(NewWriteRegCmd(1, 6, 1234))).To(Succeed())
+		Expect(handler.Calls).To(Equal([]string{
+			"W1R 0 6 4321",
+			"W1R 1 6 1234",
+		}))
+	})
+
+	It("sends handler's ModbusErr", func() {
+		handler.Err = IllegalDataAddress
+		cmd := NewReadHRegsCmd(1, 100, 3)
+		Expect(con.Send(cmd)).To(MatchError(IllegalDataAddress))
+	})
+
+	It("doesn't make up missing values", func() {
+		handler.Regs = []uint16{1, 2}
+		log := NewLog()
+		Expect(con.Send(NewReadHRegsCmd(1, 100, 3))).
+			To(MatchError(SlaveDeviceFail))
+		Expect(log.Msgs).To(ContainElement(
+			"E:handling 00 01 00 00 00 06 01 03 00 64 00 03: " +
+				"handler returns 2 of 3 values"))
+		handler.Bits = []bool{true}
+		Expect(con.Send(NewReadDInputsCmd(1, 0, 2))).
+			To(MatchError(SlaveDeviceFail))
+	})
+
+	It("sends other err as SlaveDeviceFail", func() {
+		handler.Err = errors.New("boom")
+		log := NewLog()
+		cmd := NewWriteRegCmd(1, 100, 3)
+		Expect(con.Send(cmd)).To(MatchError(SlaveDeviceFail))
+		Expect(log.Msgs).To(ContainElement(
+			"E:handling 00 01 00 00 00 06 01 06 00 64 00 03: boom"))
+	})
+
+	DescribeTable("invalid request",
+		func(req, res []byte) {
+			c1, c2 := net.Pipe()
+			go srv.ServeConn(c2)
+			defer c1.Close()
+			c1.SetDeadline(time.Now().Add(TIMEOUT))
+			_, err := c1.Write(req)
+			Expect(err).To(Succeed())
+			b := make([]byte, len(res))
+			_, err = c1.Read(b)
+			Expect(err).To(Succeed())
+			Expect(b).To(Equal(res))
+			Expect(handler.Calls).To(BeEmpty())
+		},
+		Entry("unknown function",
+			[]byte{0, 1, 0, 0, 0, 2, 1, 99},
+			[]byte{0, 1, 0, 0, 0, 3, 1, 0xE3, 1}),
+		Entry("zero count",
+			[]byte{0, 1, 0, 0, 0, 6, 1, 3, 0, 0, 0, 0},
+			[]byte{0, 1, 0, 0, 0, 3, 1, 0x83, 3}),
+		Entry("too many coils",
+			[]byte{0, 1, 0, 0, 0, 6, 1, 1, 0, 0, 0x07, 0xD1},
+			[]byte{0, 1, 0, 0, 0, 3, 1, 0x81, 3}),
+		Entry("too many regs",
+			[]byte{0, 1, 0, 0, 0, 6, 1, 4, 0, 0, 0, 126},
+			[]byte{0, 1, 0, 0, 0, 3, 1, 0x84, 3}),
+		Entry("address overflow",
+			[]byte{0, 1, 0, 0, 0, 6, 1, 3, 0xFF, 0xFF, 0, 2},
+			[]byte{0, 1, 0, 0, 0, 3, 1, 0x83, 2}),
+		Entry("bad coil value",
+			[]byte{0, 1, 0, 0, 0, 6, 1, 5, 0, 0, 0x12, 0},
+			[]byte{0, 1, 0, 0, 0, 3, 1, 0x85, 3}),
+		Entry("bad coils byte count",
+			[]byte{0, 1, 0, 0, 0, 8, 1, 15, 0, 0, 0, 9, 1, 0xFF},
+			[]byte{0, 1, 0, 0, 0, 3, 1, 0x8F, 3}),
+		Entry("bad regs byte count",
+			[]byte{0, 1, 0, 0, 0, 10, 1, 16, 0, 0, 0, 1, 3, 0, 0, 0},
+			[]byte{0, 1, 0, 0, 0, 3, 1, 0x90, 3}),
+	)
+
+	It("closes on bad protocol id", func() {
+		c1, c2 := net.Pipe()
+		go srv.ServeConn(c2)
+		defer c1.Close()
+		c1.SetDeadline(time.Now().Add(TIMEOUT))
+		// pipe Write fails too since the rest never get read
+		c1.Write([]byte{0, 1, 0, 1, 0, 6, 1, 3, 0, 0, 0, 1})
+		_, err := c1.Read(make([]byte, 16))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("serves a listener until closed", func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).To(Succeed())
+		done := make(chan error)
+		go func() {
+			done <- srv.Serve(l)
+		}()
+		port := l.Addr().(*net.TCPAddr).Port
+		c := &Controller{Dialer: &Dialer{Host: "127.0.0.1", Port: port}}
+		defer c.Close()
+		handler.Regs = []uint16{7}
+		cmd := NewReadHRegsCmd(1, 0, 1)
+		Expect(c.Send(cmd)).To(Succeed())
+		Expect(cmd.Reg(0)).To(Equal(uint16(7)))
+		Expect(srv.Close()).To(Succeed())
+		Eventually(done).Should(Receive(MatchError(ErrServerClosed)))
+	})
+})
+
+type PipeDialer struct {
+	Server *Server
+}
+
+func (d *PipeDialer) Dial(
+	repeat bool,
+) (Conn, time.Duration, time.Duration, uint16, error) {
+	c1, c2 := net.Pipe()
+	go d.Server.ServeConn(c2)
+	return c1, TIMEOUT, 0, 1, nil
+}
+
+type MockHandler struct {
+	Bits []bool
+	Regs []uint16
+	Err  error
+
+	Calls []string
+}
+
+func (m *MockHandler) ReadCoils(
+	devAddr byte, addr uint16, count uint16,
+) ([]bool, error) {
+	m.Calls = append(m.Calls, fmt.Sprintf("RC %d %d %d", devAddr, addr, count))
+	return m.Bits, m.Err
+}
+
+func (m *MockHandler) ReadDInputs(
+	devAddr byte, addr uint16, count uint16,
+) ([]bool, error) {
+	m.Calls = append(m.Calls, fmt.Sprintf("RDI %d %d %d", devAddr, addr, count))
+	return m.Bits, m.Err
+}
+
+func (m *MockHandler) ReadHRegs(
+	devAddr byte, addr uint16, count uint16,
+) ([]uint16, error) {
+	m.Calls = append(m.Calls, fmt.Sprintf("RHR %d %d %d", devAddr, addr, count))
+	return m.Regs, m.Err
+}
+
+func (m *MockHandler) ReadIRegs(
+	devAddr byte, addr uint16, count uint16,
+) ([]uint16, error) {
+	m.Calls = append(m.Calls, fmt.Sprintf("RIR %d %d %d", devAddr, addr, count))
+	return m.Regs, m.Err
+}
+
+func (m *MockHandler) WriteCoil(devAddr byte, addr uint16, val bool) error {
+	m.Calls = append(m.Calls, fmt.Sprintf("W1C %d %d %t", devAddr, addr, val))
+	return m.Err
+}
+
+func (m *MockHandler) WriteReg(devAddr byte, addr uint16, val uint16) error {
+	m.Calls = append(m.Calls, fmt.Sprintf("W1R %d %d %d", devAddr, addr, val))
+	return m.Err
+}
+
+func (m *MockHandler) WriteCoils(
+	devAddr byte, addr uint16, values []bool,
+) error {
+	m.Calls = append(m.Calls, fmt.Sprintf("WC %d %d %v", devAddr, addr, values))
+	return m.Err
+}
+
+func (m *MockHandler) WriteRegs(
+	devAddr byte, addr uint16, values []uint16,
+) error {
+	m.Calls = append(m.Calls, fmt.Sprintf("WR %d %d %v", devAddr, addr, values))
+	return m.Err
+}
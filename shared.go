@@ -0,0 +1,83 @@
+package modbus
+
+import (
+	"context"
+	"sync"
+)
+
+// SharedController is a Controller that can be used by many goroutines at
+// once. Requests share one connection and are sent one at a time in the
+// order they arrive. When the connection drops, the request in flight get
+// the error and the next one dials again.
+type SharedController struct {
+	Dialer ConnDialer
+	// Window is the max outstanding transactions of SendAll, see Controller.
+	Window int
+	// Framing is nil for MBAP, see Controller.
+	Framing Framing
+
+	once sync.Once
+	sem  chan struct{}
+	con  Controller
+}
+
+// Close closes the connection after the request in flight is done.
+func (s *SharedController) Close() {
+	s.lock(context.Background())
+	defer s.unlock()
+	s.con.Close()
+}
+
+func (s *SharedController) Send(cmd Cmd) error {
+	return s.SendContext(context.Background(), cmd)
+}
+
+// SendContext is Send that stops waiting its turn or sending when ctx is done.
+func (s *SharedController) SendContext(ctx context.Context, cmd Cmd) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.unlock()
+	return s.con.SendContext(ctx, cmd)
+}
+
+// SendAll sends cmds without interleaving with other goroutines.
+func (s *SharedController) SendAll(cmds ...Cmd) []error {
+	return s.SendAllContext(context.Background(), cmds...)
+}
+
+// SendAllContext is SendAll that stops when ctx is done.
+func (s *SharedController) SendAllContext(
+	ctx context.Context, cmds ...Cmd,
+) []error {
+	if err := s.lock(ctx); err != nil {
+		errs := make([]error, len(cmds))
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	defer s.unlock()
+	return s.con.SendAllContext(ctx, cmds...)
+}
+
+// lock waits for our turn, goroutines blocked on a channel are woken up in
+// FIFO order so it's fair unlike sync.Mutex.
+func (s *SharedController) lock(ctx context.Context) error {
+	s.once.Do(func() {
+		s.sem = make(chan struct{}, 1)
+	})
+	select {
+	case s.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	s.con.Dialer = s.Dialer
+	s.con.Window = s.Window
+	s.con.Framing = s.Framing
+	return nil
+}
+
+func (s *SharedController) unlock() {
+	<-s.sem
+}
@@ -0,0 +1,177 @@
+package modbus_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bangzek/modbus-tcp"
+)
+
+var _ = Describe("SharedController", func() {
+	var ds *DataStore
+	var srv *Server
+	BeforeEach(func() {
+		ResetClock()
+		NewLog()
+		ds = &DataStore{}
+		u := ds.AddUnit(1)
+		u.Define(HRegTable, 0, 10)
+		srv = &Server{Handler: ds}
+	})
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	It("is safe for many goroutines", func() {
+		con := &SharedController{Dialer: &PipeDialer{Server: srv}}
+		defer con.Close()
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 100)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i uint16) {
+				defer wg.Done()
+				for j := uint16(0); j < 10; j++ {
+					if err := con.Send(
+						NewWriteRegCmd(1, i, i*100+j),
+					); err != nil {
+						errs <- err
+						return
+					}
+					cmd := NewReadHRegsCmd(1, i, 1)
+					if err := con.Send(cmd); err != nil {
+						errs <- err
+						return
+					}
+					if cmd.Reg(0) != i*100+j {
+						errs <- BadRxErr(*cmd.RxBytes())
+						return
+					}
+				}
+			}(uint16(i))
+		}
+		wg.Wait()
+		close(errs)
+		Expect(errs).To(BeEmpty())
+	})
+
+	It("sends in arrival order", func() {
+		var order []int
+		var mu sync.Mutex
+		unblock := make(chan struct{})
+		u := ds.Unit(1)
+		u.OnWrite = func(t Table, addr uint16, count uint16) {
+			if addr == 0 {
+				<-unblock
+			}
+			mu.Lock()
+			order = append(order, int(addr))
+			mu.Unlock()
+		}
+		con := &SharedController{Dialer: &PipeDialer{Server: srv}}
+		defer con.Close()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(i uint16) {
+				defer wg.Done()
+				con.Send(NewWriteRegCmd(1, i, 1))
+			}(uint16(i))
+			// let it queue before the next one
+			time.Sleep(20 * time.Millisecond)
+		}
+		close(unblock)
+		wg.Wait()
+		Expect(order).To(Equal([]int{0, 1, 2, 3, 4}))
+	})
+
+	It("stops waiting when ctx is done", func() {
+		unblock := make(chan struct{})
+		ds.Unit(1).OnWrite = func(t Table, addr uint16, count uint16) {
+			<-unblock
+		}
+		con := &SharedController{Dialer: &PipeDialer{Server: srv}}
+		defer con.Close()
+
+		done := make(chan error)
+		go func() {
+			done <- con.Send(NewWriteRegCmd(1, 0, 1))
+		}()
+		time.Sleep(20 * time.Millisecond)
+		ctx, cancel := context.WithTimeout(
+			context.Background(), 20*time.Millisecond)
+		defer cancel()
+		Expect(con.SendContext(ctx, NewReadHRegsCmd(1, 0, 1))).
+			To(MatchError(context.DeadlineExceeded))
+		Expect(con.SendAllContext(ctx, NewReadHRegsCmd(1, 0, 1))).
+			To(Equal([]error{context.DeadlineExceeded}))
+		close(unblock)
+		Expect(<-done).To(Succeed())
+	})
+
+	It("sends with Framing", func() {
+		conn := &MockConn{
+			Writes: []WriteScript{
+				{8, nil},
+			},
+			Reads: []ReadScript{
+				{[]byte{1, 3, 4, 0, 1, 0, 2, 0x2A, 0x32}, nil},
+			},
+		}
+		con := &SharedController{
+			Dialer: &MockDialer{
+				Dials: []DialScript{
+					{conn, TIMEOUT, 0, 1, nil},
+				},
+			},
+			Framing: &RTUFraming{},
+		}
+		cmd := NewReadHRegsCmd(1, 0, 2)
+		Expect(con.Send(cmd)).To(Succeed())
+		Expect(cmd.Reg(1)).To(Equal(uint16(2)))
+		Expect(conn.Calls).To(ContainElement("WRITE [01 03 00 00 00 02 C4 0B]"))
+	})
+
+	It("redials for the next request when connection dropped", func() {
+		c1, c2 := net.Pipe()
+		c3, c4 := net.Pipe()
+		go func() {
+			io.ReadFull(c2, make([]byte, 12))
+			c2.Close()
+		}()
+		go srv.ServeConn(c4)
+		dialer := &MockDialer{
+			Dials: []DialScript{
+				{c1, TIMEOUT, 0, 1, nil},
+				{c3, TIMEOUT, 0, 1, nil},
+			},
+		}
+		con := &SharedController{Dialer: dialer}
+		defer con.Close()
+
+		var wg sync.WaitGroup
+		var err1, err2 error
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			err1 = con.Send(NewReadHRegsCmd(1, 0, 1))
+		}()
+		time.Sleep(20 * time.Millisecond)
+		go func() {
+			defer wg.Done()
+			err2 = con.Send(NewReadHRegsCmd(1, 0, 1))
+		}()
+		wg.Wait()
+		Expect(err1).To(HaveOccurred())
+		Expect(err2).To(Succeed())
+		Expect(dialer.Calls).To(Equal([]bool{false, false}))
+	})
+})
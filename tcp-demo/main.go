@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"log"
-	"math"
 	"os"
 
 	"github.com/bangzek/modbus-tcp"
@@ -45,9 +44,6 @@ func demoVegaScan(con *modbus.Controller) {
 	}
 
 	for i := 0; i < 60; i++ {
-		f := math.Float32frombits(
-			uint32(floats.Reg(i*2+1))<<16 |
-				uint32(floats.Reg(i*2)))
-		fmt.Printf("%d %g\n", i, f)
+		fmt.Printf("%d %g\n", i, floats.Float32(i*2, modbus.CDAB))
 	}
 }
@@ -0,0 +1,135 @@
+package modbus
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+)
+
+const TLS_PORT = 802
+
+// RoleOID is the X.509 extension of Modbus/TCP Security holding the role of
+// the certificate owner as UTF8String.
+var RoleOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 50316, 802, 1}
+
+// TLSDialer dials Modbus/TCP Security device.
+type TLSDialer struct {
+	Host    string
+	Port    int
+	Timeout time.Duration
+	Wait    time.Duration
+	// Config should have Certificates for the client certificate and RootCAs
+	// of the device certificate. ServerName defaults to Host.
+	Config *tls.Config
+	// VerifyRole is called after handshake with the role of the device,
+	// returning error closes the connection.
+	VerifyRole func(role string, state tls.ConnectionState) error
+}
+
+func (p *TLSDialer) Dial(
+	repeat bool,
+) (Conn, time.Duration, time.Duration, uint16, error) {
+	return p.DialContext(context.Background(), repeat)
+}
+
+func (p *TLSDialer) DialContext(
+	ctx context.Context, repeat bool,
+) (Conn, time.Duration, time.Duration, uint16, error) {
+	if p.Host == "" {
+		panic("empty TLSDialer.Host")
+	}
+	if p.Port <= 0 {
+		p.Port = TLS_PORT
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = TIMEOUT
+	}
+	if p.Wait <= 0 {
+		p.Wait = WAIT
+	}
+
+	a := net.JoinHostPort(p.Host, strconv.Itoa(p.Port))
+	if repeat {
+		debugLog("Dialing %s", a)
+	} else {
+		log("Dialing %s", a)
+	}
+	cfg := p.Config.Clone()
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = p.Host
+	}
+	d := tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: p.Timeout},
+		Config:    cfg,
+	}
+	conn, err := d.DialContext(ctx, "tcp", a)
+	if err != nil {
+		return nil, p.Timeout, p.Wait, 0, DialErr{a, err}
+	}
+
+	if p.VerifyRole != nil {
+		state := conn.(*tls.Conn).ConnectionState()
+		role, err := Role(state.PeerCertificates[0])
+		if err == nil {
+			err = p.VerifyRole(role, state)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, p.Timeout, p.Wait, 0, DialErr{a, RoleErr{role, err}}
+		}
+	}
+	log("%s opened", a)
+	return conn, p.Timeout, p.Wait, randTxId(conn), nil
+}
+
+// RoleErr is the error of VerifyRole or reading the role, Err is why.
+type RoleErr struct {
+	Role string
+	Err  error
+}
+
+func (e RoleErr) Error() string {
+	return e.Err.Error()
+}
+
+func (e RoleErr) Unwrap() error {
+	return e.Err
+}
+
+// isCertErr is true when err is the device certificate or its role being
+// rejected, dialing again gets the same certificate.
+func isCertErr(err error) bool {
+	var (
+		re RoleErr
+		ve *tls.CertificateVerificationError
+		ue x509.UnknownAuthorityError
+		he x509.HostnameError
+		ie x509.CertificateInvalidError
+	)
+	return errors.As(err, &re) || errors.As(err, &ve) ||
+		errors.As(err, &ue) || errors.As(err, &he) || errors.As(err, &ie)
+}
+
+// Role returns the RoleOID extension of cert, empty when it has none.
+func Role(cert *x509.Certificate) (string, error) {
+	for _, e := range cert.Extensions {
+		if e.Id.Equal(RoleOID) {
+			var role string
+			if _, err := asn1.UnmarshalWithParams(
+				e.Value, &role, "utf8",
+			); err != nil {
+				return "", err
+			}
+			return role, nil
+		}
+	}
+	return "", nil
+}
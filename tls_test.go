@@ -0,0 +1,169 @@
+package modbus_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bangzek/modbus-tcp"
+)
+
+var _ = Describe("TLSDialer", func() {
+	var ca *x509.Certificate
+	var caKey *ecdsa.PrivateKey
+	var pool *x509.CertPool
+	var srv *Server
+	var l net.Listener
+	var port int
+	BeforeEach(func() {
+		ResetClock()
+		NewLog()
+		ca, caKey = newCert("ca", "", nil, nil)
+		pool = x509.NewCertPool()
+		pool.AddCert(ca)
+
+		cert, key := newCert("device", "operator", ca, caKey)
+		var err error
+		l, err = tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+			Certificates: []tls.Certificate{{
+				Certificate: [][]byte{cert.Raw},
+				PrivateKey:  key,
+			}},
+			ClientAuth: tls.RequireAndVerifyClientCert,
+			ClientCAs:  pool,
+		})
+		Expect(err).To(Succeed())
+		port = l.Addr().(*net.TCPAddr).Port
+
+		ds := &DataStore{}
+		ds.AddUnit(1).Define(HRegTable, 0, 1)
+		ds.Unit(1).SetReg(HRegTable, 0, 802)
+		srv = &Server{Handler: ds}
+		go srv.Serve(l)
+	})
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	clientConfig := func() *tls.Config {
+		cert, key := newCert("client", "", ca, caKey)
+		return &tls.Config{
+			Certificates: []tls.Certificate{{
+				Certificate: [][]byte{cert.Raw},
+				PrivateKey:  key,
+			}},
+			RootCAs: pool,
+		}
+	}
+
+	It("sends with client certificate", func() {
+		var role string
+		con := &Controller{Dialer: &TLSDialer{
+			Host:   "127.0.0.1",
+			Port:   port,
+			Config: clientConfig(),
+			VerifyRole: func(r string, _ tls.ConnectionState) error {
+				role = r
+				return nil
+			},
+		}}
+		defer con.Close()
+		cmd := NewReadHRegsCmd(1, 0, 1)
+		Expect(con.Send(cmd)).To(Succeed())
+		Expect(cmd.Reg(0)).To(Equal(uint16(802)))
+		Expect(role).To(Equal("operator"))
+	})
+
+	It("closes when role is rejected", func() {
+		err := errors.New("not allowed")
+		d := &TLSDialer{
+			Host:   "127.0.0.1",
+			Port:   port,
+			Config: clientConfig(),
+			VerifyRole: func(string, tls.ConnectionState) error {
+				return err
+			},
+		}
+		_, _, _, _, e := d.Dial(false)
+		Expect(e).To(MatchError(err))
+		Expect(e).To(BeAssignableToTypeOf(DialErr{}))
+		Expect(e).To(MatchError(RoleErr{"operator", err}))
+		Expect(IsPermanent(e)).To(BeTrue())
+	})
+
+	It("fails without client certificate", func() {
+		con := &Controller{Dialer: &TLSDialer{
+			Host:   "127.0.0.1",
+			Port:   port,
+			Config: &tls.Config{RootCAs: pool},
+		}}
+		defer con.Close()
+		Expect(con.Send(NewReadHRegsCmd(1, 0, 1))).To(HaveOccurred())
+	})
+
+	It("fails with unknown device", func() {
+		d := &TLSDialer{
+			Host:   "127.0.0.1",
+			Port:   port,
+			Config: &tls.Config{},
+		}
+		_, _, _, _, err := d.Dial(false)
+		var ue x509.UnknownAuthorityError
+		Expect(errors.As(err, &ue)).To(BeTrue())
+		Expect(IsPermanent(err)).To(BeTrue())
+	})
+
+	It("defaults to port 802", func() {
+		d := &TLSDialer{Host: "127.0.0.1", Timeout: time.Millisecond}
+		d.Dial(false)
+		Expect(d.Port).To(Equal(802))
+	})
+})
+
+func newCert(
+	cn string, role string,
+	parent *x509.Certificate, parentKey *ecdsa.PrivateKey,
+) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).To(Succeed())
+	t := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageServerAuth,
+			x509.ExtKeyUsageClientAuth,
+		},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	if role != "" {
+		v, err := asn1.MarshalWithParams(role, "utf8")
+		Expect(err).To(Succeed())
+		t.ExtraExtensions = []pkix.Extension{{Id: RoleOID, Value: v}}
+	}
+	if parent == nil {
+		t.IsCA = true
+		t.BasicConstraintsValid = true
+		t.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = t, key
+	}
+	der, err := x509.CreateCertificate(
+		rand.Reader, t, parent, &key.PublicKey, parentKey)
+	Expect(err).To(Succeed())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).To(Succeed())
+	return cert, key
+}
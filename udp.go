@@ -0,0 +1,98 @@
+package modbus
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// UDP_RETRIES is the Retries of UDPFraming that Controller uses by default.
+const UDP_RETRIES = 2
+
+// UDPDialer dials Modbus/UDP device, Controller uses UDPFraming with
+// UDP_RETRIES on it unless Framing is set.
+type UDPDialer struct {
+	Host    string
+	Port    int
+	Timeout time.Duration
+	Wait    time.Duration
+}
+
+func (p *UDPDialer) Dial(
+	repeat bool,
+) (Conn, time.Duration, time.Duration, uint16, error) {
+	return p.DialContext(context.Background(), repeat)
+}
+
+func (p *UDPDialer) DialContext(
+	ctx context.Context, repeat bool,
+) (Conn, time.Duration, time.Duration, uint16, error) {
+	if p.Host == "" {
+		panic("empty UDPDialer.Host")
+	}
+	if p.Port <= 0 {
+		p.Port = PORT
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = TIMEOUT
+	}
+	if p.Wait <= 0 {
+		p.Wait = WAIT
+	}
+
+	a := net.JoinHostPort(p.Host, strconv.Itoa(p.Port))
+	if repeat {
+		debugLog("Dialing %s", a)
+	} else {
+		log("Dialing %s", a)
+	}
+	d := net.Dialer{Timeout: p.Timeout}
+	conn, err := d.DialContext(ctx, "udp", a)
+	if err != nil {
+		return nil, p.Timeout, p.Wait, 0, DialErr{a, err}
+	}
+	log("%s opened", a)
+	return conn, p.Timeout, p.Wait, randTxId(conn), nil
+}
+
+// UDPFraming sends Cmd as MBAP datagram, each datagram is a complete ADU.
+// Response of other TxId, late or duplicated, is discarded. When no response
+// within the timeout, the same tx is sent again up to Retries times.
+type UDPFraming struct {
+	Retries int
+
+	buf [maxADULen]byte
+}
+
+func (f *UDPFraming) WriteFrame(conn Conn, tx []byte) error {
+	if n, err := conn.Write(tx); err != nil {
+		return err
+	} else if n != len(tx) {
+		return io.ErrShortWrite
+	}
+	return nil
+}
+
+func (f *UDPFraming) ReadFrame(conn Conn, tx []byte, rx *[]byte) error {
+	for {
+		n, err := conn.Read(f.buf[:])
+		if err != nil {
+			return err
+		}
+		b := f.buf[:n]
+		if n < 8 || mbapProto(b) != 0 || int(mbapLength(b)) != n-6 ||
+			mbapId(b) != mbapId(tx) {
+			debugLog("stale: % X", b)
+			continue
+		}
+		r := (*rx)[:cap(*rx)]
+		*rx = r[:copy(r, b)]
+		return nil
+	}
+}
+
+func (f *UDPFraming) retries() int {
+	return f.Retries
+}
@@ -0,0 +1,167 @@
+package modbus_test
+
+import (
+	"net"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/bangzek/clock"
+	. "github.com/bangzek/modbus-tcp"
+)
+
+var _ = Describe("UDPFraming", func() {
+	var mc *clock.Mock
+	BeforeEach(func() {
+		mc = new(clock.Mock)
+		mc.NowScripts = []time.Duration{0, 0, 0, 0, 0, 0}
+		SetClock(mc)
+		mc.Start(time.Date(2024, time.March, 2, 10, 11, 12, 0, time.UTC))
+	})
+	AfterEach(func() {
+		mc.Stop()
+	})
+
+	It("retries by TxId and discards stale", func() {
+		cmd := NewReadHRegsCmd(1, 0, 1)
+		conn := &MockConn{
+			Writes: []WriteScript{
+				{12, nil},
+				{12, nil},
+			},
+			Reads: []ReadScript{
+				{[]byte{0, 6, 0, 0, 0, 5, 1, 3, 2, 0, 1}, nil},
+				{nil, os.ErrDeadlineExceeded},
+				{[]byte{0, 7, 0, 0, 0, 5, 1, 3, 2, 0, 42}, nil},
+			},
+		}
+		con := &Controller{
+			Dialer: &MockDialer{
+				Dials: []DialScript{
+					{conn, TIMEOUT, 0, 7, nil},
+				},
+			},
+			Framing: &UDPFraming{Retries: 2},
+		}
+		log := NewLog()
+		Expect(con.Send(cmd)).To(Succeed())
+		Expect(cmd.Reg(0)).To(Equal(uint16(42)))
+		Expect(conn.Calls).To(Equal([]string{
+			"SWD 2024-03-02T10:11:15.001Z",
+			"WRITE [00 07 00 00 00 06 01 03 00 00 00 01]",
+			"SRD 2024-03-02T10:11:15.002Z",
+			"READ",
+			"READ",
+			"SWD 2024-03-02T10:11:15.003Z",
+			"WRITE [00 07 00 00 00 06 01 03 00 00 00 01]",
+			"SRD 2024-03-02T10:11:15.004Z",
+			"READ",
+		}))
+		Expect(log.Msgs).To(Equal([]string{
+			"D:tx: 00 07 00 00 00 06 01 03 00 00 00 01",
+			"D:TX: 0007 1<-RHR 0:1",
+			"D:stale: 00 06 00 00 00 05 01 03 02 00 01",
+			"D:retry 1: 00 07 00 00 00 06 01 03 00 00 00 01",
+			"D:rx: 00 07 00 00 00 05 01 03 02 00 2A",
+			"D:RX: 0007 1->RHR 1[   42]",
+		}))
+	})
+
+	It("gives up after Retries", func() {
+		conn := &MockConn{
+			Writes: []WriteScript{
+				{12, nil},
+				{12, nil},
+			},
+			Reads: []ReadScript{
+				{nil, os.ErrDeadlineExceeded},
+				{nil, os.ErrDeadlineExceeded},
+			},
+		}
+		con := &Controller{
+			Dialer: &MockDialer{
+				Dials: []DialScript{
+					{conn, TIMEOUT, 0, 7, nil},
+				},
+			},
+			Framing: &UDPFraming{Retries: 1},
+		}
+		NewLog()
+		Expect(con.Send(NewReadHRegsCmd(1, 0, 1))).
+			To(MatchError(os.ErrDeadlineExceeded))
+		Expect(conn.Calls).To(HaveLen(9))
+		Expect(conn.Calls[8]).To(Equal("CLOSE"))
+	})
+
+	It("talks over UDP", func() {
+		ResetClock()
+		NewLog()
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).To(Succeed())
+		defer pc.Close()
+		go func() {
+			b := make([]byte, 260)
+			// drop the first request
+			pc.ReadFrom(b)
+			_, a, err := pc.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			res := []byte{b[0], b[1], 0, 0, 0, 5, 1, 3, 2, 3, 0x22}
+			pc.WriteTo(res, a) // duplicated
+			pc.WriteTo(res, a)
+		}()
+		con := &Controller{
+			Dialer: &UDPDialer{
+				Host:    "127.0.0.1",
+				Port:    pc.LocalAddr().(*net.UDPAddr).Port,
+				Timeout: 100 * time.Millisecond,
+			},
+			Framing: &UDPFraming{Retries: 3},
+		}
+		defer con.Close()
+		cmd := NewReadHRegsCmd(1, 0, 1)
+		Expect(con.Send(cmd)).To(Succeed())
+		Expect(cmd.Reg(0)).To(Equal(uint16(802)))
+	})
+
+	It("defaults to UDPFraming", func() {
+		ResetClock()
+		NewLog()
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).To(Succeed())
+		defer pc.Close()
+		go func() {
+			b := make([]byte, 260)
+			// drop the first request, it's sent again
+			pc.ReadFrom(b)
+			for {
+				_, a, err := pc.ReadFrom(b)
+				if err != nil {
+					return
+				}
+				res := []byte{b[0], b[1], 0, 0, 0, 5, 1, 3, 2, 0, b[9]}
+				pc.WriteTo(res, a)
+			}
+		}()
+		con := &Controller{
+			Dialer: &UDPDialer{
+				Host:    "127.0.0.1",
+				Port:    pc.LocalAddr().(*net.UDPAddr).Port,
+				Timeout: 100 * time.Millisecond,
+			},
+			Window: 2,
+		}
+		defer con.Close()
+		cmd := NewReadHRegsCmd(1, 7, 1)
+		Expect(con.Send(cmd)).To(Succeed())
+		Expect(cmd.Reg(0)).To(Equal(uint16(7)))
+
+		cmds := []Cmd{NewReadHRegsCmd(1, 1, 1), NewReadHRegsCmd(1, 2, 1)}
+		Expect(con.SendAll(cmds...)).To(Equal([]error{nil, nil}))
+		Expect(cmds[0].(*ReadHRegsCmd).Reg(0)).To(Equal(uint16(1)))
+		Expect(cmds[1].(*ReadHRegsCmd).Reg(0)).To(Equal(uint16(2)))
+	})
+})